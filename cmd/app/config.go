@@ -2,8 +2,12 @@ package main
 
 import (
 	"log/slog"
+	"time"
 
 	"github.com/travisbale/go-template/internal/app"
+	"github.com/travisbale/go-template/internal/db/postgres"
+	"github.com/travisbale/go-template/internal/jobs"
+	"github.com/urfave/cli/v2"
 )
 
 // Config holds all configuration for the application
@@ -12,7 +16,24 @@ type Config struct {
 	Debug bool
 
 	// Database
-	DatabaseURL string
+	DatabaseURL            string
+	DatabaseConnectTimeout time.Duration
+
+	// Migrations
+	MigrateOnStart        bool
+	MigrationWaitTimeout  time.Duration
+	DatabaseReplicaURLs   cli.StringSlice
+	DatabaseReplicaMaxLag time.Duration
+
+	// Database connection pool
+	DatabaseMaxConns          int
+	DatabaseMinConns          int
+	DatabaseMaxConnLifetime   time.Duration
+	DatabaseMaxConnIdleTime   time.Duration
+	DatabaseHealthCheckPeriod time.Duration
+	DatabaseStatementTimeout  time.Duration
+	DatabaseSearchPath        string
+	DatabaseCustomTypes       cli.StringSlice
 
 	// Server addresses
 	HTTPAddress string
@@ -21,6 +42,17 @@ type Config struct {
 	// JWT configuration
 	JWTPublicKeyPath string
 
+	// Pagination
+	PageTokenSecret string
+
+	// Browser clients
+	CORSOrigins cli.StringSlice
+
+	// Background jobs
+	RunWorkers         bool
+	WorkerConcurrency  int
+	WorkerDrainTimeout time.Duration
+
 	// Environment
 	Environment string
 }
@@ -31,11 +63,35 @@ var config = &Config{}
 // ToAppConfig converts the CLI config to an app.Config
 func (c *Config) ToAppConfig() *app.Config {
 	return &app.Config{
-		DatabaseURL:      c.DatabaseURL,
+		DatabaseURL:            c.DatabaseURL,
+		DatabaseConnectTimeout: c.DatabaseConnectTimeout,
+		MigrateOnStart:         c.MigrateOnStart,
+		MigrationWaitTimeout:   c.MigrationWaitTimeout,
+		DatabaseReplicaURLs:    c.DatabaseReplicaURLs.Value(),
+		DatabaseReplicaMaxLag:  c.DatabaseReplicaMaxLag,
+		DatabasePool: postgres.PoolConfig{
+			MaxConns:          int32(c.DatabaseMaxConns),
+			MinConns:          int32(c.DatabaseMinConns),
+			MaxConnLifetime:   c.DatabaseMaxConnLifetime,
+			MaxConnIdleTime:   c.DatabaseMaxConnIdleTime,
+			HealthCheckPeriod: c.DatabaseHealthCheckPeriod,
+			ApplicationName:   "app/" + Version,
+			StatementTimeout:  c.DatabaseStatementTimeout,
+			SearchPath:        c.DatabaseSearchPath,
+			CustomTypes:       c.DatabaseCustomTypes.Value(),
+		},
 		HTTPAddress:      c.HTTPAddress,
 		GRPCAddress:      c.GRPCAddress,
 		JWTPublicKeyPath: c.JWTPublicKeyPath,
-		Environment:      c.Environment,
-		Logger:           slog.Default(),
+		PageTokenSecret:  c.PageTokenSecret,
+		CORSOrigins:      c.CORSOrigins.Value(),
+		RunWorkers:       c.RunWorkers,
+		Worker: jobs.Config{
+			Concurrency:  c.WorkerConcurrency,
+			DrainTimeout: c.WorkerDrainTimeout,
+		},
+		Environment: c.Environment,
+		Version:     Version,
+		Logger:      slog.Default(),
 	}
 }
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/travisbale/go-template/internal/db/postgres"
+	"github.com/urfave/cli/v2"
+)
+
+var dbCmd = &cli.Command{
+	Name:  "db",
+	Usage: "Inspect the database",
+	Subcommands: []*cli.Command{
+		{
+			Name:  "verify-rls",
+			Usage: "Check that every table with a tenant_id column enforces Row Level Security",
+			Action: func(c *cli.Context) error {
+				db, err := postgres.NewDB(c.Context, config.DatabaseURL, slog.Default())
+				if err != nil {
+					return fmt.Errorf("failed to connect to database: %w", err)
+				}
+				defer db.Close()
+
+				report, err := db.VerifyRLS(c.Context)
+				if err != nil {
+					return err
+				}
+
+				for _, table := range report.Tables {
+					if problems := table.Problems(); len(problems) > 0 {
+						fmt.Printf("FAIL  %s.%s: %s\n", table.Schema, table.Name, strings.Join(problems, ", "))
+					} else {
+						fmt.Printf("ok    %s.%s\n", table.Schema, table.Name)
+					}
+				}
+				if report.BypassRLS {
+					fmt.Println("FAIL  the connected role is a superuser or has BYPASSRLS, so policies do not apply to it")
+				}
+
+				if !report.OK() {
+					return fmt.Errorf("row level security is not enforced")
+				}
+				fmt.Printf("All %d tenant tables enforce row level security\n", len(report.Tables))
+				return nil
+			},
+		},
+	},
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"time"
+
 	"github.com/urfave/cli/v2"
 )
 
@@ -24,6 +26,114 @@ var (
 		Destination: &config.DatabaseURL,
 	}
 
+	// DatabaseConnectTimeoutFlag defines how long to wait for the database at startup
+	DatabaseConnectTimeoutFlag = &cli.DurationFlag{
+		Name:        "database-connect-timeout",
+		Usage:       "How long to retry connecting to the database at startup (0 fails on the first attempt)",
+		Value:       time.Minute,
+		EnvVars:     []string{"DATABASE_CONNECT_TIMEOUT"},
+		Destination: &config.DatabaseConnectTimeout,
+	}
+
+	// MigrateOnStartFlag defines whether the server applies migrations before serving
+	MigrateOnStartFlag = &cli.BoolFlag{
+		Name:        "migrate-on-start",
+		Usage:       "Apply pending migrations at startup",
+		DefaultText: "true unless the environment is production",
+		EnvVars:     []string{"MIGRATE_ON_START"},
+		Destination: &config.MigrateOnStart,
+	}
+
+	// MigrationWaitTimeoutFlag defines how long to wait for another instance or job to migrate
+	MigrationWaitTimeoutFlag = &cli.DurationFlag{
+		Name:        "migration-wait-timeout",
+		Usage:       "How long to wait for the schema to reach this binary's version when not migrating on start",
+		Value:       5 * time.Minute,
+		EnvVars:     []string{"MIGRATION_WAIT_TIMEOUT"},
+		Destination: &config.MigrationWaitTimeout,
+	}
+
+	// DatabaseReplicaURLFlag defines read replica connection URLs
+	DatabaseReplicaURLFlag = &cli.StringSliceFlag{
+		Name:        "database-replica-url",
+		Usage:       "PostgreSQL read replica connection URL (repeatable)",
+		EnvVars:     []string{"DATABASE_REPLICA_URLS"},
+		Destination: &config.DatabaseReplicaURLs,
+	}
+
+	// DatabaseReplicaMaxLagFlag defines the replication lag above which a replica stops serving reads
+	DatabaseReplicaMaxLagFlag = &cli.DurationFlag{
+		Name:        "database-replica-max-lag",
+		Usage:       "Maximum replication lag before a replica is excluded from reads",
+		Value:       10 * time.Second,
+		EnvVars:     []string{"DATABASE_REPLICA_MAX_LAG"},
+		Destination: &config.DatabaseReplicaMaxLag,
+	}
+
+	// DatabaseMaxConnsFlag defines the maximum size of the connection pool
+	DatabaseMaxConnsFlag = &cli.IntFlag{
+		Name:        "database-max-conns",
+		Usage:       "Maximum number of pooled connections (default 25, or pool_max_conns from the URL)",
+		EnvVars:     []string{"DATABASE_MAX_CONNS"},
+		Destination: &config.DatabaseMaxConns,
+	}
+
+	// DatabaseMinConnsFlag defines the number of idle connections kept open
+	DatabaseMinConnsFlag = &cli.IntFlag{
+		Name:        "database-min-conns",
+		Usage:       "Minimum number of pooled connections (default 5, or pool_min_conns from the URL)",
+		EnvVars:     []string{"DATABASE_MIN_CONNS"},
+		Destination: &config.DatabaseMinConns,
+	}
+
+	// DatabaseMaxConnLifetimeFlag defines how long a connection is reused before it is replaced
+	DatabaseMaxConnLifetimeFlag = &cli.DurationFlag{
+		Name:        "database-max-conn-lifetime",
+		Usage:       "Maximum lifetime of a pooled connection (default 1h)",
+		EnvVars:     []string{"DATABASE_MAX_CONN_LIFETIME"},
+		Destination: &config.DatabaseMaxConnLifetime,
+	}
+
+	// DatabaseMaxConnIdleTimeFlag defines how long an idle connection is kept open
+	DatabaseMaxConnIdleTimeFlag = &cli.DurationFlag{
+		Name:        "database-max-conn-idle-time",
+		Usage:       "Maximum idle time of a pooled connection (default 30m)",
+		EnvVars:     []string{"DATABASE_MAX_CONN_IDLE_TIME"},
+		Destination: &config.DatabaseMaxConnIdleTime,
+	}
+
+	// DatabaseHealthCheckPeriodFlag defines how often idle connections are checked
+	DatabaseHealthCheckPeriodFlag = &cli.DurationFlag{
+		Name:        "database-health-check-period",
+		Usage:       "Interval between health checks of idle connections (default 1m)",
+		EnvVars:     []string{"DATABASE_HEALTH_CHECK_PERIOD"},
+		Destination: &config.DatabaseHealthCheckPeriod,
+	}
+
+	// DatabaseStatementTimeoutFlag defines the server-side statement timeout
+	DatabaseStatementTimeoutFlag = &cli.DurationFlag{
+		Name:        "database-statement-timeout",
+		Usage:       "Abort statements that run longer than this (disabled if unset)",
+		EnvVars:     []string{"DATABASE_STATEMENT_TIMEOUT"},
+		Destination: &config.DatabaseStatementTimeout,
+	}
+
+	// DatabaseSearchPathFlag defines the schema search path of every connection
+	DatabaseSearchPathFlag = &cli.StringFlag{
+		Name:        "database-search-path",
+		Usage:       "Schema search path for every connection, e.g. \"app, public\"",
+		EnvVars:     []string{"DATABASE_SEARCH_PATH"},
+		Destination: &config.DatabaseSearchPath,
+	}
+
+	// DatabaseCustomTypeFlag defines custom types to register on every connection
+	DatabaseCustomTypeFlag = &cli.StringSliceFlag{
+		Name:        "database-custom-type",
+		Usage:       "Enum, domain or composite type to register on every connection (repeatable)",
+		EnvVars:     []string{"DATABASE_CUSTOM_TYPES"},
+		Destination: &config.DatabaseCustomTypes,
+	}
+
 	// HTTPAddressFlag defines the HTTP server listen address
 	HTTPAddressFlag = &cli.StringFlag{
 		Name:        "http-address",
@@ -54,6 +164,49 @@ var (
 		Destination: &config.JWTPublicKeyPath,
 	}
 
+	// PageTokenSecretFlag defines the key used to sign list page tokens
+	PageTokenSecretFlag = &cli.StringFlag{
+		Name:        "page-token-secret",
+		Usage:       "Secret used to sign pagination tokens (random per process if unset)",
+		EnvVars:     []string{"PAGE_TOKEN_SECRET"},
+		Destination: &config.PageTokenSecret,
+	}
+
+	// CORSOriginsFlag defines the origins allowed to call the API from a browser
+	CORSOriginsFlag = &cli.StringSliceFlag{
+		Name:        "cors-origin",
+		Usage:       "Origin allowed to call the API from a browser, or * for any (repeatable)",
+		EnvVars:     []string{"CORS_ORIGINS"},
+		Destination: &config.CORSOrigins,
+	}
+
+	// RunWorkersFlag defines whether the server also processes background jobs
+	RunWorkersFlag = &cli.BoolFlag{
+		Name:        "run-workers",
+		Usage:       "Process background jobs in the server process (disable when running app worker separately)",
+		Value:       true,
+		EnvVars:     []string{"RUN_WORKERS"},
+		Destination: &config.RunWorkers,
+	}
+
+	// WorkerConcurrencyFlag defines how many jobs a process runs at once
+	WorkerConcurrencyFlag = &cli.IntFlag{
+		Name:        "worker-concurrency",
+		Usage:       "Maximum number of background jobs to run at once",
+		Value:       10,
+		EnvVars:     []string{"WORKER_CONCURRENCY"},
+		Destination: &config.WorkerConcurrency,
+	}
+
+	// WorkerDrainTimeoutFlag defines how long running jobs may finish during shutdown
+	WorkerDrainTimeoutFlag = &cli.DurationFlag{
+		Name:        "worker-drain-timeout",
+		Usage:       "How long running jobs may finish during shutdown before they are cancelled and retried",
+		Value:       30 * time.Second,
+		EnvVars:     []string{"WORKER_DRAIN_TIMEOUT"},
+		Destination: &config.WorkerDrainTimeout,
+	}
+
 	// EnvironmentFlag defines the deployment environment
 	EnvironmentFlag = &cli.StringFlag{
 		Name:        "environment",
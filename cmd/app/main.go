@@ -31,7 +31,10 @@ func main() {
 		},
 		Commands: []*cli.Command{
 			startCmd,
+			workerCmd,
 			migrateCmd,
+			dbCmd,
+			openapiCmd,
 			versionCmd,
 		},
 	}
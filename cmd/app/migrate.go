@@ -1,8 +1,16 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 
+	"github.com/golang-migrate/migrate/v4/source"
 	"github.com/travisbale/go-template/internal/db/postgres"
 	"github.com/urfave/cli/v2"
 )
@@ -13,23 +21,128 @@ var migrateCmd = &cli.Command{
 	Subcommands: []*cli.Command{
 		{
 			Name:  "up",
-			Usage: "Apply all pending migrations",
+			Usage: "Apply pending migrations",
+			Flags: []cli.Flag{
+				&cli.IntFlag{
+					Name:  "steps",
+					Usage: "Number of migrations to apply (all if unset)",
+				},
+				&cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "Print the SQL of the pending migrations instead of applying them",
+				},
+			},
 			Action: func(c *cli.Context) error {
-				return postgres.MigrateUp(config.DatabaseURL)
+				if c.Bool("dry-run") {
+					if c.IsSet("steps") {
+						return fmt.Errorf("--dry-run cannot be combined with --steps, use migrate plan --to")
+					}
+					plan, err := migrator().PlanUp(c.Context)
+					if err != nil {
+						return err
+					}
+					printPlan(plan)
+					return nil
+				}
+				if c.IsSet("steps") {
+					steps := c.Int("steps")
+					if steps < 1 {
+						return fmt.Errorf("--steps must be at least 1")
+					}
+					_, err := migrator().Steps(c.Context, steps)
+					return err
+				}
+				_, err := migrator().Up(c.Context)
+				return err
 			},
 		},
 		{
 			Name:  "down",
 			Usage: "Rollback the last migration",
+			Flags: []cli.Flag{
+				&cli.IntFlag{
+					Name:  "steps",
+					Usage: "Number of migrations to roll back",
+					Value: 1,
+				},
+				&cli.BoolFlag{
+					Name:  "all",
+					Usage: "Roll back every applied migration",
+				},
+				&cli.BoolFlag{
+					Name:    "yes",
+					Aliases: []string{"y"},
+					Usage:   "Skip the confirmation prompt for --all",
+				},
+			},
 			Action: func(c *cli.Context) error {
-				return postgres.MigrateDown(config.DatabaseURL)
+				if c.Bool("all") {
+					if !c.Bool("yes") && !confirm("This will roll back every migration and drop all data. Continue?") {
+						return fmt.Errorf("aborted")
+					}
+					_, err := migrator().DownAll(c.Context)
+					return err
+				}
+				if steps := c.Int("steps"); steps != 1 {
+					if steps < 1 {
+						return fmt.Errorf("--steps must be at least 1")
+					}
+					_, err := migrator().Steps(c.Context, -steps)
+					return err
+				}
+				_, err := migrator().Down(c.Context)
+				return err
+			},
+		},
+		{
+			Name:      "goto",
+			Usage:     "Migrate up or down to a specific version",
+			ArgsUsage: "VERSION",
+			Action: func(c *cli.Context) error {
+				version, err := strconv.ParseUint(c.Args().First(), 10, 64)
+				if err != nil || c.NArg() != 1 {
+					return fmt.Errorf("usage: migrate goto VERSION")
+				}
+				_, err = migrator().Goto(c.Context, uint(version))
+				return err
+			},
+		},
+		{
+			Name:      "force",
+			Usage:     "Set the version and clear the dirty flag without running migrations",
+			ArgsUsage: "VERSION",
+			Action: func(c *cli.Context) error {
+				version, err := strconv.Atoi(c.Args().First())
+				if err != nil || c.NArg() != 1 || version < -1 {
+					return fmt.Errorf("usage: migrate force VERSION (-1 for no version)")
+				}
+				return migrator().Force(c.Context, version)
+			},
+		},
+		{
+			Name:  "plan",
+			Usage: "Print the SQL that would run to migrate up or down to a version",
+			Flags: []cli.Flag{
+				&cli.UintFlag{
+					Name:     "to",
+					Usage:    "Target version (0 rolls back everything)",
+					Required: true,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				plan, err := migrator().Plan(c.Context, c.Uint("to"))
+				if err != nil {
+					return err
+				}
+				printPlan(plan)
+				return nil
 			},
 		},
 		{
 			Name:  "version",
 			Usage: "Show current migration version",
 			Action: func(c *cli.Context) error {
-				version, dirty, err := postgres.MigrateVersion(config.DatabaseURL)
+				version, dirty, err := migrator().Version(c.Context)
 				if err != nil {
 					return err
 				}
@@ -38,8 +151,146 @@ var migrateCmd = &cli.Command{
 				} else {
 					fmt.Printf("Current version: %d\n", version)
 				}
+
+				dataMigrations, err := migrator().DataMigrations(c.Context)
+				if err != nil {
+					return err
+				}
+				if len(dataMigrations) > 0 {
+					fmt.Println("Data migrations:")
+				}
+				for _, migration := range dataMigrations {
+					state := "pending"
+					switch {
+					case migration.Completed:
+						state = fmt.Sprintf("completed in %d batches", migration.Batches)
+					case migration.Batches > 0:
+						state = fmt.Sprintf("in progress, %d batches done, resumes after %q", migration.Batches, migration.Cursor)
+					}
+					fmt.Printf("  %03d_%s: %s\n", migration.Version, migration.Name, state)
+				}
+				return nil
+			},
+		},
+		{
+			Name:  "status",
+			Usage: "List embedded migrations and whether they are applied",
+			Action: func(c *cli.Context) error {
+				migrations, err := migrator().Status(c.Context)
+				if err != nil {
+					return err
+				}
+				for _, migration := range migrations {
+					state := "pending"
+					switch {
+					case migration.Dirty:
+						state = "dirty"
+					case migration.Applied:
+						state = "applied"
+					}
+					kind := "sql"
+					if migration.Data {
+						kind = "go"
+					}
+					fmt.Printf("%03d  %-8s %-3s %s\n", migration.Version, state, kind, migration.Name)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "create",
+			Usage:     "Create a new numbered up/down migration pair",
+			ArgsUsage: "NAME",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "dir",
+					Usage: "Migrations directory",
+					Value: filepath.Join("internal", "db", "postgres", "migrations"),
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if c.NArg() != 1 {
+					return fmt.Errorf("usage: migrate create NAME")
+				}
+				paths, err := createMigration(c.String("dir"), c.Args().First(), postgres.DataMigrationVersions())
+				if err != nil {
+					return err
+				}
+				for _, path := range paths {
+					fmt.Println("Created", path)
+				}
 				return nil
 			},
 		},
 	},
 }
+
+// migrator creates a migrator for the configured database that logs to the default logger
+func migrator() *postgres.Migrator {
+	return postgres.NewMigrator(config.DatabaseURL, slog.Default())
+}
+
+// printPlan writes the SQL of each planned migration to stdout in execution order
+func printPlan(plan []postgres.PlannedMigration) {
+	if len(plan) == 0 {
+		fmt.Println("-- No migrations to run")
+		return
+	}
+	for _, migration := range plan {
+		fmt.Printf("-- %03d_%s.%s.sql\n", migration.Version, migration.Name, migration.Direction)
+		fmt.Println(strings.TrimSpace(migration.SQL))
+		fmt.Println()
+	}
+}
+
+// migrationNameSanitizer replaces anything that is not safe in a file name
+var migrationNameSanitizer = regexp.MustCompile(`[^a-z0-9]+`)
+
+// createMigration writes empty up and down files numbered after the highest
+// existing migration in dir or registered data migration version
+func createMigration(dir, name string, dataVersions []uint) ([]string, error) {
+	name = strings.Trim(migrationNameSanitizer.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return nil, fmt.Errorf("migration name must contain letters or digits")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations directory: %w", err)
+	}
+
+	var latest uint
+	for _, version := range dataVersions {
+		latest = max(latest, version)
+	}
+	for _, entry := range entries {
+		if migration, err := source.Parse(entry.Name()); err == nil {
+			latest = max(latest, migration.Version)
+		}
+	}
+
+	var paths []string
+	for _, direction := range []source.Direction{source.Up, source.Down} {
+		path := filepath.Join(dir, fmt.Sprintf("%03d_%s.%s.sql", latest+1, name, direction))
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create migration: %w", err)
+		}
+		if err := file.Close(); err != nil {
+			return nil, fmt.Errorf("failed to create migration: %w", err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// confirm asks a yes/no question on stdin, defaulting to no
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
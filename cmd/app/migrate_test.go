@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+func TestCreateMigrationNumbering(t *testing.T) {
+	tests := []struct {
+		name         string
+		existing     []string
+		dataVersions []uint
+		want         []string
+	}{
+		{"empty", nil, nil, []string{"001_add_widgets.up.sql", "001_add_widgets.down.sql"}},
+		{"after sql", []string{"001_init.up.sql", "001_init.down.sql"}, nil, []string{"002_add_widgets.up.sql", "002_add_widgets.down.sql"}},
+		{"after data", []string{"001_init.up.sql", "001_init.down.sql"}, []uint{2}, []string{"003_add_widgets.up.sql", "003_add_widgets.down.sql"}},
+		{"data below sql", []string{"003_init.up.sql"}, []uint{2}, []string{"004_add_widgets.up.sql", "004_add_widgets.down.sql"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range tt.existing {
+				if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+					t.Fatalf("failed to write %s: %v", name, err)
+				}
+			}
+
+			paths, err := createMigration(dir, "Add widgets", tt.dataVersions)
+			if err != nil {
+				t.Fatalf("createMigration failed: %v", err)
+			}
+
+			var got []string
+			for _, path := range paths {
+				got = append(got, filepath.Base(path))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMigrateStepsValidation(t *testing.T) {
+	tests := [][]string{
+		{"migrate", "up", "--steps", "0"},
+		{"migrate", "up", "--steps", "-2"},
+		{"migrate", "down", "--steps", "0"},
+		{"migrate", "down", "--steps", "-2"},
+	}
+
+	for _, args := range tests {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			app := &cli.App{Commands: []*cli.Command{migrateCmd}}
+			err := app.Run(append([]string{"app"}, args...))
+			if err == nil || err.Error() != "--steps must be at least 1" {
+				t.Errorf("error = %v, want --steps must be at least 1", err)
+			}
+		})
+	}
+}
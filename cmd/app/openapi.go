@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/travisbale/go-template/internal/api/http"
+	"github.com/travisbale/go-template/internal/api/http/openapi"
+	"github.com/urfave/cli/v2"
+)
+
+var openapiCmd = &cli.Command{
+	Name:  "openapi",
+	Usage: "Write the OpenAPI document for the HTTP API",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "File to write the document to (- for stdout)",
+			Value:   "openapi.json",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		// Routes are registered without connecting to any dependencies
+		server := http.NewServer(&http.Config{Version: Version})
+
+		output := c.String("output")
+		if output == "-" {
+			return openapi.WriteJSON(os.Stdout, server.OpenAPI())
+		}
+
+		file, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer file.Close() //nolint:errcheck
+
+		if err := openapi.WriteJSON(file, server.OpenAPI()); err != nil {
+			return fmt.Errorf("failed to write OpenAPI document: %w", err)
+		}
+
+		fmt.Printf("OpenAPI document written to %s\n", output)
+		return nil
+	},
+}
@@ -6,7 +6,6 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/travisbale/go-template/internal/app"
 	"github.com/urfave/cli/v2"
@@ -17,17 +16,38 @@ var startCmd = &cli.Command{
 	Name:  "start",
 	Usage: "Start the HTTP API and gRPC service",
 	Flags: []cli.Flag{
+		DatabaseConnectTimeoutFlag,
+		MigrateOnStartFlag,
+		MigrationWaitTimeoutFlag,
+		DatabaseReplicaURLFlag,
+		DatabaseReplicaMaxLagFlag,
+		DatabaseMaxConnsFlag,
+		DatabaseMinConnsFlag,
+		DatabaseMaxConnLifetimeFlag,
+		DatabaseMaxConnIdleTimeFlag,
+		DatabaseHealthCheckPeriodFlag,
+		DatabaseStatementTimeoutFlag,
+		DatabaseSearchPathFlag,
+		DatabaseCustomTypeFlag,
 		HTTPAddressFlag,
 		GRPCAddressFlag,
 		JWTPublicKeyFlag,
+		PageTokenSecretFlag,
+		CORSOriginsFlag,
+		RunWorkersFlag,
+		WorkerConcurrencyFlag,
+		WorkerDrainTimeoutFlag,
 		EnvironmentFlag,
 	},
 	Action: func(c *cli.Context) error {
+		setMigrateOnStartDefault(c)
+
 		// Convert CLI config to app config
 		appConfig := config.ToAppConfig()
 
-		// Create server with our API handlers
-		server, err := app.NewServer(c.Context, appConfig)
+		// Create server with our API handlers. It connects to the database
+		// once it is listening, so health probes are answered meanwhile.
+		server, err := app.NewServer(appConfig)
 		if err != nil {
 			return err
 		}
@@ -51,7 +71,8 @@ var startCmd = &cli.Command{
 			<-ctx.Done()
 			slog.Info("Shutting down gracefully")
 
-			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			// Allow in-process workers to drain before the database is closed
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), appConfig.ShutdownTimeout())
 			defer cancel()
 
 			return server.Shutdown(shutdownCtx)
@@ -64,3 +85,12 @@ var startCmd = &cli.Command{
 		return nil
 	},
 }
+
+// setMigrateOnStartDefault applies the environment-dependent default of
+// --migrate-on-start: production deployments run migrations as a separate
+// step unless told otherwise
+func setMigrateOnStartDefault(c *cli.Context) {
+	if !c.IsSet(MigrateOnStartFlag.Name) {
+		config.MigrateOnStart = config.Environment != "production"
+	}
+}
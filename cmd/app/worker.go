@@ -0,0 +1,46 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/travisbale/go-template/internal/app"
+	"github.com/urfave/cli/v2"
+)
+
+var workerCmd = &cli.Command{
+	Name:  "worker",
+	Usage: "Process background jobs without serving requests",
+	Flags: []cli.Flag{
+		DatabaseConnectTimeoutFlag,
+		MigrateOnStartFlag,
+		MigrationWaitTimeoutFlag,
+		DatabaseMaxConnsFlag,
+		DatabaseMinConnsFlag,
+		DatabaseMaxConnLifetimeFlag,
+		DatabaseMaxConnIdleTimeFlag,
+		DatabaseHealthCheckPeriodFlag,
+		DatabaseStatementTimeoutFlag,
+		DatabaseSearchPathFlag,
+		DatabaseCustomTypeFlag,
+		WorkerConcurrencyFlag,
+		WorkerDrainTimeoutFlag,
+		EnvironmentFlag,
+	},
+	Action: func(c *cli.Context) error {
+		setMigrateOnStartDefault(c)
+
+		ctx, cancel := signal.NotifyContext(c.Context, os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+
+		worker, err := app.NewWorker(ctx, config.ToAppConfig())
+		if err != nil {
+			return err
+		}
+
+		slog.Info("Processing background jobs", "concurrency", config.WorkerConcurrency)
+		return worker.Run(ctx)
+	},
+}
@@ -0,0 +1,103 @@
+package grpc
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/travisbale/go-template/internal/audit"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// auditUnaryInterceptor records a fallback audit entry for successful
+// mutating calls whose handler recorded nothing, like the HTTP
+// AuditMiddleware. The actor and request ID come from the context, where the
+// HTTP middleware in front of the Connect and gRPC-Web handler puts them.
+func auditUnaryInterceptor(log *audit.Log) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !mutates(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		ctx, recorded := audit.Track(ctx)
+		resp, err := handler(ctx, req)
+		if err == nil && !recorded() {
+			recordCall(ctx, log, info.FullMethod)
+		}
+		return resp, err
+	}
+}
+
+// auditStreamInterceptor is auditUnaryInterceptor for streaming calls
+func auditStreamInterceptor(log *audit.Log) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !mutates(info.FullMethod) {
+			return handler(srv, stream)
+		}
+
+		ctx, recorded := audit.Track(stream.Context())
+		err := handler(srv, &trackedStream{ServerStream: stream, ctx: ctx})
+		if err == nil && !recorded() {
+			recordCall(ctx, log, info.FullMethod)
+		}
+		return err
+	}
+}
+
+// trackedStream replaces a stream's context with one tracking audit entries
+type trackedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *trackedStream) Context() context.Context {
+	return s.ctx
+}
+
+// recordCall writes the fallback entry for a call. It is written after the
+// handler's transaction has committed, so a failure is only logged.
+func recordCall(ctx context.Context, log *audit.Log, fullMethod string) {
+	_, method, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	err := log.Record(context.WithoutCancel(ctx), audit.Event{
+		Action:       method,
+		ResourceType: "grpc_call",
+		ResourceID:   fullMethod,
+	})
+	if err != nil {
+		slog.Error("Failed to record audit entry", "method", fullMethod, "error", err)
+	}
+}
+
+// mutates reports whether a method may change data. Methods are assumed to,
+// unless they are marked idempotency_level = NO_SIDE_EFFECTS or mapped to an
+// HTTP GET, or belong to the gRPC infrastructure services such as health.
+func mutates(fullMethod string) bool {
+	if strings.HasPrefix(fullMethod, "/grpc.") {
+		return false
+	}
+
+	name := protoreflect.FullName(strings.ReplaceAll(strings.TrimPrefix(fullMethod, "/"), "/", "."))
+	descriptor, err := protoregistry.GlobalFiles.FindDescriptorByName(name)
+	if err != nil {
+		return true
+	}
+	method, ok := descriptor.(protoreflect.MethodDescriptor)
+	if !ok {
+		return true
+	}
+
+	options, ok := method.Options().(*descriptorpb.MethodOptions)
+	if !ok || options == nil {
+		return true
+	}
+	if options.GetIdempotencyLevel() == descriptorpb.MethodOptions_NO_SIDE_EFFECTS {
+		return false
+	}
+	rule, _ := proto.GetExtension(options, annotations.E_Http).(*annotations.HttpRule)
+	return rule.GetGet() == ""
+}
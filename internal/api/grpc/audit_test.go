@@ -0,0 +1,95 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/travisbale/go-template/internal/audit"
+	"github.com/travisbale/go-template/internal/domain"
+	"github.com/travisbale/heimdall/tenant"
+	"google.golang.org/grpc"
+)
+
+// auditStore collects the entries written by an audit.Log
+type auditStore struct {
+	entries []domain.AuditEntry
+}
+
+func (s *auditStore) Insert(ctx context.Context, entry domain.AuditEntry) (domain.AuditEntry, error) {
+	s.entries = append(s.entries, entry)
+	return entry, nil
+}
+
+func (s *auditStore) List(ctx context.Context, filter domain.AuditFilter, afterCreatedAt *time.Time, afterID *uuid.UUID, limit int32) ([]domain.AuditEntry, error) {
+	return s.entries, nil
+}
+
+func TestAuditUnaryInterceptor(t *testing.T) {
+	const createWidget = "/example.v1.Widgets/CreateWidget"
+	ctx := audit.WithActor(tenant.WithTenant(context.Background(), uuid.New()), audit.Actor{ID: "user-1", Type: audit.ActorUser})
+
+	tests := []struct {
+		name    string
+		method  string
+		handler func(log *audit.Log) grpc.UnaryHandler
+		want    []string
+	}{
+		{
+			name:   "unrecorded mutation",
+			method: createWidget,
+			handler: func(*audit.Log) grpc.UnaryHandler {
+				return func(ctx context.Context, req any) (any, error) { return nil, nil }
+			},
+			want: []string{"CreateWidget"},
+		},
+		{
+			name:   "recorded mutation",
+			method: createWidget,
+			handler: func(log *audit.Log) grpc.UnaryHandler {
+				return func(ctx context.Context, req any) (any, error) {
+					return nil, log.Record(ctx, audit.Event{Action: "widget.create", ResourceType: "widget", ResourceID: "1"})
+				}
+			},
+			want: []string{"widget.create"},
+		},
+		{
+			name:   "failed mutation",
+			method: createWidget,
+			handler: func(*audit.Log) grpc.UnaryHandler {
+				return func(ctx context.Context, req any) (any, error) { return nil, errors.New("failed") }
+			},
+		},
+		{
+			name:   "infrastructure service",
+			method: "/grpc.health.v1.Health/Check",
+			handler: func(*audit.Log) grpc.UnaryHandler {
+				return func(ctx context.Context, req any) (any, error) { return nil, nil }
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &auditStore{}
+			log := audit.NewLog(store)
+
+			interceptor := auditUnaryInterceptor(log)
+			_, _ = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, tt.handler(log))
+
+			var actions []string
+			for _, entry := range store.entries {
+				actions = append(actions, entry.Action)
+				if entry.ActorID != "user-1" {
+					t.Errorf("entry attributed to %q, want user-1", entry.ActorID)
+				}
+			}
+			if !slices.Equal(actions, tt.want) {
+				t.Errorf("recorded %v, want %v", actions, tt.want)
+			}
+		})
+	}
+}
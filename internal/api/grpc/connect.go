@@ -0,0 +1,265 @@
+package grpc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// connectCodes maps gRPC codes to Connect error codes and the HTTP status
+// used for unary error responses
+var connectCodes = map[codes.Code]struct {
+	name   string
+	status int
+}{
+	codes.Canceled:           {"canceled", 499},
+	codes.Unknown:            {"unknown", http.StatusInternalServerError},
+	codes.InvalidArgument:    {"invalid_argument", http.StatusBadRequest},
+	codes.DeadlineExceeded:   {"deadline_exceeded", http.StatusGatewayTimeout},
+	codes.NotFound:           {"not_found", http.StatusNotFound},
+	codes.AlreadyExists:      {"already_exists", http.StatusConflict},
+	codes.PermissionDenied:   {"permission_denied", http.StatusForbidden},
+	codes.ResourceExhausted:  {"resource_exhausted", http.StatusTooManyRequests},
+	codes.FailedPrecondition: {"failed_precondition", http.StatusBadRequest},
+	codes.Aborted:            {"aborted", http.StatusConflict},
+	codes.OutOfRange:         {"out_of_range", http.StatusBadRequest},
+	codes.Unimplemented:      {"unimplemented", http.StatusNotImplemented},
+	codes.Internal:           {"internal", http.StatusInternalServerError},
+	codes.Unavailable:        {"unavailable", http.StatusServiceUnavailable},
+	codes.DataLoss:           {"data_loss", http.StatusInternalServerError},
+	codes.Unauthenticated:    {"unauthenticated", http.StatusUnauthorized},
+}
+
+// connectError is the JSON representation of an error in the Connect protocol
+type connectError struct {
+	Code    string               `json:"code"`
+	Message string               `json:"message,omitempty"`
+	Details []connectErrorDetail `json:"details,omitempty"`
+}
+
+type connectErrorDetail struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// connectEndStream is the final message of a Connect streaming response
+type connectEndStream struct {
+	Error    *connectError       `json:"error,omitempty"`
+	Metadata map[string][]string `json:"metadata,omitempty"`
+}
+
+// serveConnectUnary handles a unary Connect request, whose body is a single
+// un-enveloped message encoded with the codec named by the content type
+func (h *webHandler) serveConnectUnary(w http.ResponseWriter, r *http.Request, codec string) {
+	input, output, err := messageTypes(r.URL.Path)
+	if err != nil {
+		writeConnectError(w, status.New(codes.Unimplemented, err.Error()))
+		return
+	}
+	if encoding := r.Header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		writeConnectError(w, status.Newf(codes.Unimplemented, "unsupported content encoding %q", encoding))
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebMessageSize+1))
+	if err != nil {
+		writeConnectError(w, status.New(codes.InvalidArgument, "failed to read request body"))
+		return
+	}
+	if len(body) > maxWebMessageSize {
+		writeConnectError(w, status.Newf(codes.ResourceExhausted, "request exceeds limit of %d bytes", maxWebMessageSize))
+		return
+	}
+
+	message, err := toProto(codec, input, body)
+	if err != nil {
+		writeConnectError(w, status.Newf(codes.InvalidArgument, "failed to decode request: %v", err))
+		return
+	}
+
+	var reply []byte
+	response := &grpcResponse{
+		onMessage: func(message []byte) error {
+			reply = message
+			return nil
+		},
+	}
+	h.invoke(response, r, bytes.NewReader(frame(0, message)), connectTimeout(r))
+
+	copyMetadata(w.Header(), response.sentHeader, "")
+	copyMetadata(w.Header(), response.trailers(), "Trailer-")
+
+	if st := response.status(); st.Code() != codes.OK {
+		writeConnectError(w, st)
+		return
+	}
+
+	data, err := fromProto(codec, output, reply)
+	if err != nil {
+		writeConnectError(w, status.Newf(codes.Internal, "failed to encode response: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/"+codec)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(data); err != nil {
+		slog.Error("Failed to write Connect response", "error", err, "method", r.URL.Path)
+	}
+}
+
+// serveConnectStream handles a server-streaming Connect request. The request
+// and every response message are enveloped, and the stream ends with a JSON
+// message carrying the status and trailers.
+func (h *webHandler) serveConnectStream(w http.ResponseWriter, r *http.Request, codec string) {
+	contentType := "application/connect+" + codec
+
+	input, output, err := messageTypes(r.URL.Path)
+	if err == nil {
+		var flags byte
+		var payload []byte
+		if flags, payload, err = readFrame(r.Body); err == nil && flags&flagCompressed != 0 {
+			err = fmt.Errorf("compressed messages are not supported")
+		}
+		if err == nil {
+			payload, err = toProto(codec, input, payload)
+		}
+
+		if err == nil {
+			response := &grpcResponse{
+				onHeaders: func(header http.Header) {
+					copyMetadata(w.Header(), header, "")
+					w.Header().Set("Content-Type", contentType)
+					w.WriteHeader(http.StatusOK)
+				},
+				onMessage: func(message []byte) error {
+					data, err := fromProto(codec, output, message)
+					if err != nil {
+						return err
+					}
+					if _, err := w.Write(frame(0, data)); err != nil {
+						return err
+					}
+					if flusher, ok := w.(http.Flusher); ok {
+						flusher.Flush()
+					}
+					return nil
+				},
+			}
+			h.invoke(response, r, bytes.NewReader(frame(0, payload)), connectTimeout(r))
+			writeConnectEndStream(w, r, response.status(), response.trailers())
+			return
+		}
+	}
+
+	writeConnectStreamError(w, r, contentType, status.Newf(codes.InvalidArgument, "failed to decode request: %v", err))
+}
+
+// writeConnectStreamError sends a Connect streaming response that ends
+// with an error before any message
+func writeConnectStreamError(w http.ResponseWriter, r *http.Request, contentType string, st *status.Status) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	writeConnectEndStream(w, r, st, nil)
+}
+
+// writeConnectEndStream sends the final message of a Connect stream, carrying
+// the status and trailers
+func writeConnectEndStream(w http.ResponseWriter, r *http.Request, st *status.Status, trailers http.Header) {
+	end := connectEndStream{Metadata: make(map[string][]string)}
+	copyMetadata(http.Header(end.Metadata), trailers, "")
+	if st.Code() != codes.OK {
+		end.Error = toConnectError(st)
+	}
+
+	data, err := json.Marshal(end)
+	if err != nil {
+		slog.Error("Failed to encode Connect end of stream", "error", err)
+		return
+	}
+	if _, err := w.Write(frame(flagEndStream, data)); err != nil {
+		slog.Error("Failed to write Connect end of stream", "error", err, "method", r.URL.Path)
+	}
+}
+
+// connectTimeout converts the Connect-Timeout-Ms header to a gRPC timeout
+func connectTimeout(r *http.Request) string {
+	ms, err := strconv.ParseInt(r.Header.Get("Connect-Timeout-Ms"), 10, 64)
+	if err != nil || ms <= 0 {
+		return ""
+	}
+	return strconv.FormatInt(ms, 10) + "m"
+}
+
+// toProto converts a request message in the given codec to the binary wire format
+func toProto(codec string, messageType protoreflect.MessageType, data []byte) ([]byte, error) {
+	switch codec {
+	case "proto":
+		return data, nil
+	case "json":
+		message := messageType.New().Interface()
+		if err := protojson.Unmarshal(data, message); err != nil {
+			return nil, err
+		}
+		return proto.Marshal(message)
+	default:
+		return nil, fmt.Errorf("unsupported codec %q", codec)
+	}
+}
+
+// fromProto converts a response message from the binary wire format to the given codec
+func fromProto(codec string, messageType protoreflect.MessageType, data []byte) ([]byte, error) {
+	switch codec {
+	case "proto":
+		return data, nil
+	case "json":
+		message := messageType.New().Interface()
+		if err := proto.Unmarshal(data, message); err != nil {
+			return nil, err
+		}
+		return protojson.Marshal(message)
+	default:
+		return nil, fmt.Errorf("unsupported codec %q", codec)
+	}
+}
+
+// writeConnectError sends a unary Connect error response
+func writeConnectError(w http.ResponseWriter, st *status.Status) {
+	code, ok := connectCodes[st.Code()]
+	if !ok {
+		code = connectCodes[codes.Unknown]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code.status)
+	if err := json.NewEncoder(w).Encode(toConnectError(st)); err != nil {
+		slog.Error("Failed to encode Connect error", "error", err, "status", code.status)
+	}
+}
+
+func toConnectError(st *status.Status) *connectError {
+	code, ok := connectCodes[st.Code()]
+	if !ok {
+		code = connectCodes[codes.Unknown]
+	}
+
+	connectErr := &connectError{Code: code.name, Message: st.Message()}
+	for _, detail := range st.Proto().GetDetails() {
+		connectErr.Details = append(connectErr.Details, connectErrorDetail{
+			Type:  strings.TrimPrefix(detail.GetTypeUrl(), "type.googleapis.com/"),
+			Value: base64.RawStdEncoding.EncodeToString(detail.GetValue()),
+		})
+	}
+	return connectErr
+}
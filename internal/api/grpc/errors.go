@@ -0,0 +1,55 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/travisbale/go-template/internal/domain"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// statusFromError converts a domain error returned by a service into a
+// status error with the matching code and the domain error's client-safe
+// message. Other errors are returned unchanged.
+func statusFromError(err error) error {
+	var domainErr *domain.Error
+	if !errors.As(err, &domainErr) {
+		return err
+	}
+
+	var code codes.Code
+	switch {
+	case errors.Is(domainErr.Kind, domain.ErrNotFound):
+		code = codes.NotFound
+	case errors.Is(domainErr.Kind, domain.ErrAlreadyExists):
+		code = codes.AlreadyExists
+	case errors.Is(domainErr.Kind, domain.ErrReferenceViolation):
+		code = codes.FailedPrecondition
+	case errors.Is(domainErr.Kind, domain.ErrInvalid):
+		code = codes.InvalidArgument
+	case errors.Is(domainErr.Kind, domain.ErrConflict):
+		code = codes.Aborted
+	default:
+		return err
+	}
+	return status.Error(code, domainErr.Error())
+}
+
+// unaryErrorInterceptor lets unary handlers return domain errors
+func unaryErrorInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	resp, err := handler(ctx, req)
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+	return resp, nil
+}
+
+// streamErrorInterceptor lets streaming handlers return domain errors
+func streamErrorInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := handler(srv, stream); err != nil {
+		return statusFromError(err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package grpc
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/travisbale/go-template/internal/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStatusFromError(t *testing.T) {
+	tests := []struct {
+		kind error
+		code codes.Code
+	}{
+		{domain.ErrNotFound, codes.NotFound},
+		{domain.ErrAlreadyExists, codes.AlreadyExists},
+		{domain.ErrReferenceViolation, codes.FailedPrecondition},
+		{domain.ErrInvalid, codes.InvalidArgument},
+		{domain.ErrConflict, codes.Aborted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code.String(), func(t *testing.T) {
+			cause := errors.New("duplicate key value violates unique constraint")
+			err := fmt.Errorf("failed to create widget: %w", &domain.Error{Kind: tt.kind, Resource: "widget", Err: cause})
+
+			st, ok := status.FromError(statusFromError(err))
+			if !ok {
+				t.Fatalf("got %v, want a status error", err)
+			}
+			if st.Code() != tt.code {
+				t.Errorf("code = %s, want %s", st.Code(), tt.code)
+			}
+			if want := "widget " + tt.kind.Error(); st.Message() != want {
+				t.Errorf("message = %q, want %q without the cause", st.Message(), want)
+			}
+		})
+	}
+
+	t.Run("other errors", func(t *testing.T) {
+		for _, err := range []error{
+			errors.New("connection refused"),
+			&domain.Error{Kind: errors.New("unknown kind")},
+		} {
+			if got := statusFromError(err); got != err {
+				t.Errorf("statusFromError(%v) = %v, want it unchanged", err, got)
+			}
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/travisbale/go-template/sdk"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// gatewayRegistration registers a service's generated gateway handlers on the
+// gateway mux. Generated RegisterXHandlerServer functions call the service
+// implementation in-process, so no loopback connection is needed.
+type gatewayRegistration func(ctx context.Context, mux *runtime.ServeMux) error
+
+// Gateway returns an HTTP handler that transcodes REST requests to the
+// registered gRPC services according to their google.api.http annotations
+func (s *Server) Gateway(ctx context.Context) (http.Handler, error) {
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.HTTPBodyMarshaler{
+			Marshaler: &runtime.JSONPb{
+				// Use snake_case field names to match the hand-written HTTP API
+				MarshalOptions: protojson.MarshalOptions{
+					UseProtoNames:   true,
+					EmitUnpopulated: true,
+				},
+				UnmarshalOptions: protojson.UnmarshalOptions{},
+			},
+		}),
+		runtime.WithMetadata(forwardRequestID),
+		runtime.WithErrorHandler(handleGatewayError),
+	)
+
+	for _, register := range s.gateway {
+		if err := register(ctx, mux); err != nil {
+			return nil, err
+		}
+	}
+
+	return mux, nil
+}
+
+// forwardRequestID makes the chi request ID available to services as metadata
+func forwardRequestID(ctx context.Context, r *http.Request) metadata.MD {
+	if id := middleware.GetReqID(r.Context()); id != "" {
+		return metadata.Pairs("x-request-id", id)
+	}
+	return nil
+}
+
+// handleGatewayError translates gRPC status errors into the same problem
+// responses returned by the hand-written HTTP handlers
+func handleGatewayError(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	// Gateway handlers call services in-process, bypassing the interceptors
+	st := status.Convert(statusFromError(err))
+	code := runtime.HTTPStatusFromCode(st.Code())
+	if st.Code() == codes.Aborted {
+		// Aborted reports a stale write, which HTTP handlers answer with 412
+		code = http.StatusPreconditionFailed
+	}
+
+	problem := sdk.Problem{
+		Title:  http.StatusText(code),
+		Status: code,
+		Detail: st.Message(),
+	}
+	for _, detail := range st.Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, violation := range badRequest.GetFieldViolations() {
+				problem.InvalidParams = append(problem.InvalidParams, sdk.InvalidParam{
+					Name:   violation.GetField(),
+					Reason: violation.GetDescription(),
+				})
+			}
+		}
+	}
+
+	// Avoid leaking internal error messages to clients
+	if code >= http.StatusInternalServerError {
+		slog.Error("gRPC gateway error", "error", err, "status", code, "path", r.URL.Path)
+		problem.Detail = ""
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(code)
+	if encodeErr := json.NewEncoder(w).Encode(problem); encodeErr != nil {
+		slog.Error("Failed to encode problem response", "error", encodeErr, "status", code)
+	}
+}
@@ -0,0 +1,48 @@
+package grpc
+
+import (
+	"errors"
+
+	"github.com/travisbale/go-template/internal/api/pagination"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// listRequest is satisfied by generated request messages that follow AIP-158,
+// i.e. that declare page_size, page_token, order_by and filter fields
+type listRequest interface {
+	GetPageSize() int32
+	GetPageToken() string
+	GetOrderBy() string
+	GetFilter() string
+}
+
+// parseListRequest validates the list fields of a request against the endpoint's spec.
+// Invalid parameters are returned as InvalidArgument with BadRequest field violations.
+func parseListRequest(codec *pagination.Codec, spec *pagination.Spec, req listRequest) (*pagination.Query, error) {
+	query, err := codec.Parse(spec, pagination.Params{
+		PageSize:  req.GetPageSize(),
+		PageToken: req.GetPageToken(),
+		OrderBy:   req.GetOrderBy(),
+		Filter:    req.GetFilter(),
+	})
+	if err == nil {
+		return query, nil
+	}
+
+	var paramErr *pagination.Error
+	if !errors.As(err, &paramErr) {
+		return nil, status.Error(codes.Internal, "failed to parse list parameters")
+	}
+
+	st, detailErr := status.New(codes.InvalidArgument, paramErr.Error()).WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			{Field: paramErr.Param, Description: paramErr.Reason},
+		},
+	})
+	if detailErr != nil {
+		return nil, status.Error(codes.InvalidArgument, paramErr.Error())
+	}
+	return nil, st.Err()
+}
@@ -4,36 +4,60 @@ import (
 	"fmt"
 	"net"
 
+	"github.com/travisbale/go-template/internal/api/pagination"
+	"github.com/travisbale/go-template/internal/audit"
 	"github.com/travisbale/go-template/internal/db/postgres"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 )
 
 type Config struct {
-	Address string
-	DB      *postgres.DB
+	Address    string
+	DB         *postgres.DB
+	PageTokens *pagination.Codec
+	Audit      *audit.Log
 }
 
 // Server implements the gRPC service
 type Server struct {
 	Addr string
 	*grpc.Server
+	gateway []gatewayRegistration
 }
 
 // NewServer creates a new gRPC server
 func NewServer(config *Config) *Server {
-	grpcServer := grpc.NewServer()
+	// Services return domain errors, which are translated to status codes
+	unary := []grpc.UnaryServerInterceptor{unaryErrorInterceptor}
+	stream := []grpc.StreamServerInterceptor{streamErrorInterceptor}
+
+	// Mutations whose handlers record no audit entry get a fallback one
+	if config.Audit != nil {
+		unary = append([]grpc.UnaryServerInterceptor{auditUnaryInterceptor(config.Audit)}, unary...)
+		stream = append([]grpc.StreamServerInterceptor{auditStreamInterceptor(config.Audit)}, stream...)
+	}
+
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	)
 
 	// Enable gRPC reflection for development/debugging with grpcurl
 	reflection.Register(grpcServer)
 
-	// Register your gRPC services here
+	// Register your gRPC services here. Services with google.api.http
+	// annotations are also added to the gateway so they are served under /v1.
 	// Example:
 	// pb.RegisterYourServiceServer(grpcServer, yourServiceHandler)
+	// gateway = append(gateway, func(ctx context.Context, mux *runtime.ServeMux) error {
+	//     return pb.RegisterYourServiceHandlerServer(ctx, mux, yourServiceHandler)
+	// })
+	var gateway []gatewayRegistration
 
 	return &Server{
-		Addr:   config.Address,
-		Server: grpcServer,
+		Addr:    config.Address,
+		Server:  grpcServer,
+		gateway: gateway,
 	}
 }
 
@@ -0,0 +1,490 @@
+package grpc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+const (
+	// maxWebMessageSize matches the default gRPC receive limit
+	maxWebMessageSize = 4 << 20
+
+	// Envelope flags
+	flagCompressed = 0x01
+	flagEndStream  = 0x02
+	flagTrailer    = 0x80
+)
+
+// WebHandler returns an HTTP handler that serves the registered gRPC services
+// to browser clients over the Connect protocol and gRPC-Web. Requests are
+// translated into gRPC and dispatched through grpc.Server.ServeHTTP, so the
+// services, interceptors and status codes are exactly those of the gRPC listener.
+// Unary and server-streaming methods are supported.
+//
+// authenticate is the HTTP authentication middleware. Requests it rejects get
+// an unauthenticated or permission denied error in the client's protocol
+// rather than the middleware's own response.
+func (s *Server) WebHandler(authenticate func(http.Handler) http.Handler) http.Handler {
+	return &webHandler{server: s.Server, methods: s.methods(), authenticate: authenticate}
+}
+
+// Services returns the fully-qualified names of the services exposed by WebHandler
+func (s *Server) Services() []string {
+	var names []string
+	for name := range s.GetServiceInfo() {
+		if !strings.HasPrefix(name, "grpc.reflection.") {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// methods indexes every exposed method by its /package.Service/Method path
+func (s *Server) methods() map[string]grpc.MethodInfo {
+	methods := make(map[string]grpc.MethodInfo)
+	for _, service := range s.Services() {
+		for _, method := range s.GetServiceInfo()[service].Methods {
+			methods["/"+service+"/"+method.Name] = method
+		}
+	}
+	return methods
+}
+
+type webHandler struct {
+	server       *grpc.Server
+	methods      map[string]grpc.MethodInfo
+	authenticate func(http.Handler) http.Handler
+}
+
+// webProtocol is the browser protocol a request was made with
+type webProtocol int
+
+const (
+	protocolGRPCWeb webProtocol = iota
+	protocolConnectUnary
+	protocolConnectStream
+)
+
+func (h *webHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method, ok := h.methods[r.URL.Path]
+	if !ok {
+		http.Error(w, "unknown method", http.StatusNotFound)
+		return
+	}
+	if method.IsClientStream {
+		http.Error(w, "client and bidirectional streaming are not supported by browser protocols", http.StatusNotImplemented)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid content type %q", r.Header.Get("Content-Type")), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var protocol webProtocol
+	var codec string
+	switch {
+	case strings.HasPrefix(contentType, "application/grpc-web"):
+		protocol = protocolGRPCWeb
+	case strings.HasPrefix(contentType, "application/connect+"):
+		if !method.IsServerStream {
+			http.Error(w, "unary methods must use the unary Connect protocol", http.StatusUnsupportedMediaType)
+			return
+		}
+		protocol, codec = protocolConnectStream, strings.TrimPrefix(contentType, "application/connect+")
+	case contentType == "application/proto" || contentType == "application/json":
+		if method.IsServerStream {
+			http.Error(w, "streaming methods must use the streaming Connect protocol", http.StatusUnsupportedMediaType)
+			return
+		}
+		protocol, codec = protocolConnectUnary, strings.TrimPrefix(contentType, "application/")
+	default:
+		http.Error(w, fmt.Sprintf("unsupported content type %q", contentType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	serve := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch protocol {
+		case protocolGRPCWeb:
+			h.serveGRPCWeb(w, r, contentType)
+		case protocolConnectStream:
+			h.serveConnectStream(w, r, codec)
+		case protocolConnectUnary:
+			h.serveConnectUnary(w, r, codec)
+		}
+	})
+	if h.authenticate == nil {
+		serve(w, r)
+		return
+	}
+
+	// The middleware calls serve with the real writer when it accepts the
+	// request; otherwise its response is replaced with a protocol error
+	rejection := &rejectedResponse{header: make(http.Header)}
+	accepted := false
+	h.authenticate(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		accepted = true
+		serve(w, r)
+	})).ServeHTTP(rejection, r)
+	if accepted {
+		return
+	}
+
+	st := rejection.status()
+	switch protocol {
+	case protocolGRPCWeb:
+		writeGRPCWebError(w, contentType, st)
+	case protocolConnectStream:
+		writeConnectStreamError(w, r, "application/connect+"+codec, st)
+	case protocolConnectUnary:
+		writeConnectError(w, st)
+	}
+}
+
+// rejectedResponse records the response of an authentication middleware
+// that rejected a request
+type rejectedResponse struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (r *rejectedResponse) Header() http.Header { return r.header }
+
+func (r *rejectedResponse) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+}
+
+func (r *rejectedResponse) Write(data []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(data)
+}
+
+// status converts the rejection to a gRPC status, keeping the middleware's
+// message, which may be plain text or a JSON object with an error field
+func (r *rejectedResponse) status() *grpcstatus.Status {
+	code := codes.Unknown
+	switch r.code {
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	}
+
+	message := strings.TrimSpace(r.body.String())
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal([]byte(message), &body) == nil && body.Error != "" {
+		message = body.Error
+	}
+	return grpcstatus.New(code, message)
+}
+
+// writeGRPCWebError sends a trailers-only gRPC-Web response, carrying the
+// status in the headers
+func writeGRPCWebError(w http.ResponseWriter, contentType string, st *grpcstatus.Status) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Grpc-Status", strconv.Itoa(int(st.Code())))
+	w.Header().Set("Grpc-Message", encodeGRPCMessage(st.Message()))
+	w.WriteHeader(http.StatusOK)
+}
+
+// encodeGRPCMessage percent-encodes a status message as the gRPC protocol requires
+func encodeGRPCMessage(message string) string {
+	var b strings.Builder
+	for i := 0; i < len(message); i++ {
+		c := message[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// serveGRPCWeb handles application/grpc-web[+proto] and application/grpc-web-text.
+// The framing is identical to gRPC except that trailers are sent as a final
+// frame in the body, and the -text variant base64 encodes the whole stream.
+func (h *webHandler) serveGRPCWeb(w http.ResponseWriter, r *http.Request, contentType string) {
+	text := strings.HasPrefix(contentType, "application/grpc-web-text")
+
+	var body io.Reader = r.Body
+	if text {
+		body = base64.NewDecoder(base64.StdEncoding, r.Body)
+	}
+
+	write := func(data []byte) error {
+		if text {
+			data = []byte(base64.StdEncoding.EncodeToString(data))
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	response := &grpcResponse{
+		onHeaders: func(header http.Header) {
+			copyMetadata(w.Header(), header, "")
+			w.Header().Set("Content-Type", contentType)
+			w.WriteHeader(http.StatusOK)
+		},
+		onMessage: func(message []byte) error {
+			return write(frame(0, message))
+		},
+	}
+
+	h.invoke(response, r, body, r.Header.Get("Grpc-Timeout"))
+
+	// Trailers are sent in the body as an HTTP/1-style header block
+	var trailers bytes.Buffer
+	for key, values := range response.trailers() {
+		for _, value := range values {
+			fmt.Fprintf(&trailers, "%s: %s\r\n", strings.ToLower(key), value)
+		}
+	}
+	_ = write(frame(flagTrailer, trailers.Bytes()))
+}
+
+// invoke dispatches a request to the gRPC server. The request is presented as
+// HTTP/2 with an application/grpc content type, which is all ServeHTTP requires.
+func (h *webHandler) invoke(response *grpcResponse, r *http.Request, body io.Reader, timeout string) {
+	req := r.Clone(r.Context())
+	req.Proto, req.ProtoMajor, req.ProtoMinor = "HTTP/2", 2, 0
+	req.Body = io.NopCloser(body)
+	req.ContentLength = -1
+
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Del("Content-Length")
+	req.Header.Del("Grpc-Timeout")
+	if timeout != "" {
+		req.Header.Set("Grpc-Timeout", timeout)
+	}
+	for key := range req.Header {
+		if strings.HasPrefix(key, "Connect-") || strings.HasPrefix(key, "X-Grpc-Web") {
+			req.Header.Del(key)
+		}
+	}
+
+	h.server.ServeHTTP(response, req)
+	response.finish()
+}
+
+// frame wraps a message in the 5-byte length-prefixed envelope shared by gRPC,
+// gRPC-Web and Connect streaming
+func frame(flags byte, message []byte) []byte {
+	data := make([]byte, 5+len(message))
+	data[0] = flags
+	binary.BigEndian.PutUint32(data[1:5], uint32(len(message)))
+	copy(data[5:], message)
+	return data
+}
+
+// readFrame reads a single enveloped message
+func readFrame(r io.Reader) (byte, []byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return 0, nil, err
+	}
+
+	length := binary.BigEndian.Uint32(prefix[1:])
+	if length > maxWebMessageSize {
+		return 0, nil, fmt.Errorf("message of %d bytes exceeds limit of %d", length, maxWebMessageSize)
+	}
+
+	message := make([]byte, length)
+	if _, err := io.ReadFull(r, message); err != nil {
+		return 0, nil, err
+	}
+	return prefix[0], message, nil
+}
+
+// grpcResponse is the http.ResponseWriter handed to grpc.Server.ServeHTTP.
+// It parses the gRPC framing as it is written, passing headers and each
+// message to the protocol handler, and keeps the trailers for the end.
+type grpcResponse struct {
+	onHeaders func(http.Header)
+	onMessage func([]byte) error
+
+	header      http.Header
+	sentHeader  http.Header
+	buffer      bytes.Buffer
+	wroteHeader bool
+	err         error
+}
+
+func (g *grpcResponse) Header() http.Header {
+	if g.header == nil {
+		g.header = make(http.Header)
+	}
+	return g.header
+}
+
+func (g *grpcResponse) WriteHeader(int) {
+	if g.wroteHeader {
+		return
+	}
+	g.wroteHeader = true
+	g.sentHeader = g.Header().Clone()
+}
+
+func (g *grpcResponse) Write(data []byte) (int, error) {
+	g.WriteHeader(http.StatusOK)
+	if g.err != nil {
+		return 0, g.err
+	}
+
+	g.buffer.Write(data)
+	for g.buffer.Len() >= 5 {
+		length := int(binary.BigEndian.Uint32(g.buffer.Bytes()[1:5]))
+		if g.buffer.Len() < 5+length {
+			break
+		}
+
+		envelope := g.buffer.Next(5 + length)
+		if envelope[0]&flagCompressed != 0 {
+			g.err = fmt.Errorf("compressed responses are not supported")
+			return 0, g.err
+		}
+
+		if g.onHeaders != nil {
+			g.onHeaders(g.sentHeader)
+			g.onHeaders = nil
+		}
+		if g.err = g.onMessage(bytes.Clone(envelope[5:])); g.err != nil {
+			return 0, g.err
+		}
+	}
+
+	return len(data), nil
+}
+
+// Flush is required by ServeHTTP; data is forwarded as soon as a frame is complete
+func (g *grpcResponse) Flush() {
+	g.WriteHeader(http.StatusOK)
+}
+
+// finish sends the headers if no message was written, e.g. for an error status
+func (g *grpcResponse) finish() {
+	g.WriteHeader(http.StatusOK)
+	if g.onHeaders != nil {
+		g.onHeaders(g.sentHeader)
+		g.onHeaders = nil
+	}
+}
+
+// trailers returns the status and trailing metadata written after the body
+func (g *grpcResponse) trailers() http.Header {
+	trailers := make(http.Header)
+	for key, values := range g.Header() {
+		switch {
+		case strings.HasPrefix(key, http.TrailerPrefix):
+			trailers[textproto.CanonicalMIMEHeaderKey(strings.TrimPrefix(key, http.TrailerPrefix))] = values
+		case key == "Grpc-Status", key == "Grpc-Message", key == "Grpc-Status-Details-Bin":
+			trailers[key] = values
+		}
+	}
+	return trailers
+}
+
+// status decodes the gRPC status from the trailers
+func (g *grpcResponse) status() *grpcstatus.Status {
+	if g.err != nil {
+		return grpcstatus.New(codes.Internal, g.err.Error())
+	}
+
+	trailers := g.trailers()
+	code, err := strconv.Atoi(trailers.Get("Grpc-Status"))
+	if err != nil {
+		return grpcstatus.New(codes.Unknown, "missing grpc-status")
+	}
+	message, _ := url.PathUnescape(trailers.Get("Grpc-Message"))
+
+	if encoded := trailers.Get("Grpc-Status-Details-Bin"); encoded != "" {
+		if data, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(encoded, "=")); err == nil {
+			var pb spb.Status
+			if proto.Unmarshal(data, &pb) == nil {
+				return grpcstatus.FromProto(&pb)
+			}
+		}
+	}
+
+	return grpcstatus.New(codes.Code(code), message)
+}
+
+// copyMetadata copies custom metadata from a gRPC header block, skipping
+// protocol headers, optionally adding a prefix to each key
+func copyMetadata(dst, src http.Header, prefix string) {
+	for key, values := range src {
+		lower := strings.ToLower(key)
+		if lower == "content-type" || lower == "trailer" || lower == "date" || strings.HasPrefix(lower, "grpc-") {
+			continue
+		}
+		for _, value := range values {
+			dst.Add(prefix+key, value)
+		}
+	}
+}
+
+// messageTypes resolves the request and response message types of a method
+// from the global registry populated by the generated code
+func messageTypes(path string) (protoreflect.MessageType, protoreflect.MessageType, error) {
+	serviceName, methodName, ok := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if !ok {
+		return nil, nil, fmt.Errorf("malformed method path %q", path)
+	}
+
+	descriptor, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(serviceName))
+	if err != nil {
+		return nil, nil, err
+	}
+	service, ok := descriptor.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s is not a service", serviceName)
+	}
+	method := service.Methods().ByName(protoreflect.Name(methodName))
+	if method == nil {
+		return nil, nil, fmt.Errorf("unknown method %s", path)
+	}
+
+	input, err := protoregistry.GlobalTypes.FindMessageByName(method.Input().FullName())
+	if err != nil {
+		return nil, nil, err
+	}
+	output, err := protoregistry.GlobalTypes.FindMessageByName(method.Output().FullName())
+	if err != nil {
+		return nil, nil, err
+	}
+	return input, output, nil
+}
@@ -0,0 +1,245 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const testToken = "Bearer valid"
+
+// requireToken mimics jwt.Middleware, rejecting requests without testToken
+// with a JSON 401 body
+func requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != testToken {
+			http.Error(w, `{"error":"invalid or expired token"}`, http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// newWebServer serves the gRPC health service through WebHandler, reporting
+// the "widgets" service as serving
+func newWebServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := NewServer(&Config{})
+	checker := health.NewServer()
+	checker.SetServingStatus("widgets", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server.Server, checker)
+
+	web := httptest.NewServer(server.WebHandler(requireToken))
+	t.Cleanup(web.Close)
+	return web
+}
+
+// protocols are the client configurations browsers use
+var protocols = []struct {
+	name    string
+	options []connect.ClientOption
+}{
+	{"connect proto", nil},
+	{"connect json", []connect.ClientOption{connect.WithProtoJSON()}},
+	{"grpc-web proto", []connect.ClientOption{connect.WithGRPCWeb()}},
+}
+
+func checkClient(web *httptest.Server, options []connect.ClientOption) *connect.Client[healthpb.HealthCheckRequest, healthpb.HealthCheckResponse] {
+	return connect.NewClient[healthpb.HealthCheckRequest, healthpb.HealthCheckResponse](
+		web.Client(), web.URL+"/grpc.health.v1.Health/Check", options...)
+}
+
+func watchClient(web *httptest.Server, options []connect.ClientOption) *connect.Client[healthpb.HealthCheckRequest, healthpb.HealthCheckResponse] {
+	return connect.NewClient[healthpb.HealthCheckRequest, healthpb.HealthCheckResponse](
+		web.Client(), web.URL+"/grpc.health.v1.Health/Watch", options...)
+}
+
+func newRequest(service, token string) *connect.Request[healthpb.HealthCheckRequest] {
+	request := connect.NewRequest(&healthpb.HealthCheckRequest{Service: service})
+	if token != "" {
+		request.Header().Set("Authorization", token)
+	}
+	return request
+}
+
+func TestWebHandlerUnary(t *testing.T) {
+	web := newWebServer(t)
+
+	for _, protocol := range protocols {
+		t.Run(protocol.name, func(t *testing.T) {
+			client := checkClient(web, protocol.options)
+
+			response, err := client.CallUnary(context.Background(), newRequest("widgets", testToken))
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if got := response.Msg.GetStatus(); got != healthpb.HealthCheckResponse_SERVING {
+				t.Errorf("got status %v, want SERVING", got)
+			}
+
+			_, err = client.CallUnary(context.Background(), newRequest("gadgets", testToken))
+			if code := connect.CodeOf(err); code != connect.CodeNotFound {
+				t.Errorf("unknown service: got code %v, want %v (error %v)", code, connect.CodeNotFound, err)
+			}
+		})
+	}
+}
+
+func TestWebHandlerServerStream(t *testing.T) {
+	web := newWebServer(t)
+
+	for _, protocol := range protocols {
+		t.Run(protocol.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// Watch sends the current status, then waits for changes
+			stream, err := watchClient(web, protocol.options).CallServerStream(ctx, newRequest("widgets", testToken))
+			if err != nil {
+				t.Fatalf("Watch failed: %v", err)
+			}
+			defer stream.Close() //nolint:errcheck
+
+			if !stream.Receive() {
+				t.Fatalf("no message received: %v", stream.Err())
+			}
+			if got := stream.Msg().GetStatus(); got != healthpb.HealthCheckResponse_SERVING {
+				t.Errorf("got status %v, want SERVING", got)
+			}
+		})
+	}
+}
+
+func TestWebHandlerUnauthenticated(t *testing.T) {
+	web := newWebServer(t)
+
+	for _, protocol := range protocols {
+		t.Run(protocol.name, func(t *testing.T) {
+			_, err := checkClient(web, protocol.options).CallUnary(context.Background(), newRequest("widgets", ""))
+			assertUnauthenticated(t, err)
+
+			stream, err := watchClient(web, protocol.options).CallServerStream(context.Background(), newRequest("widgets", ""))
+			if err == nil {
+				defer stream.Close() //nolint:errcheck
+				if stream.Receive() {
+					t.Fatal("received a message without a token")
+				}
+				err = stream.Err()
+			}
+			assertUnauthenticated(t, err)
+		})
+	}
+}
+
+func assertUnauthenticated(t *testing.T, err error) {
+	t.Helper()
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) || connectErr.Code() != connect.CodeUnauthenticated {
+		t.Fatalf("got error %v, want unauthenticated", err)
+	}
+	if connectErr.Message() != "invalid or expired token" {
+		t.Errorf("got message %q, want the middleware's message", connectErr.Message())
+	}
+}
+
+func TestWebHandlerContentTypeParameters(t *testing.T) {
+	web := newWebServer(t)
+
+	request, err := http.NewRequest(http.MethodPost, web.URL+"/grpc.health.v1.Health/Check", strings.NewReader(`{"service":"widgets"}`))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	request.Header.Set("Content-Type", "application/json; charset=utf-8")
+	request.Header.Set("Authorization", testToken)
+
+	response, err := web.Client().Do(request)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer response.Body.Close() //nolint:errcheck
+
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("got status %d, want %d", response.StatusCode, http.StatusOK)
+	}
+	if got := response.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("got content type %q, want application/json", got)
+	}
+}
+
+func TestWebHandlerGRPCWebText(t *testing.T) {
+	web := newWebServer(t)
+
+	message, err := proto.Marshal(&healthpb.HealthCheckRequest{Service: "widgets"})
+	if err != nil {
+		t.Fatalf("failed to encode request: %v", err)
+	}
+	body := base64.StdEncoding.EncodeToString(frame(0, message))
+
+	request, err := http.NewRequest(http.MethodPost, web.URL+"/grpc.health.v1.Health/Check", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	request.Header.Set("Content-Type", "application/grpc-web-text")
+	request.Header.Set("Authorization", testToken)
+
+	response, err := web.Client().Do(request)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer response.Body.Close() //nolint:errcheck
+
+	// Each frame is encoded separately, so decode them one at a time
+	encoded, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	var decoded []byte
+	for remaining := string(encoded); remaining != ""; {
+		end := strings.IndexByte(remaining, '=')
+		if end < 0 {
+			end = len(remaining)
+		}
+		for end < len(remaining) && remaining[end] == '=' {
+			end++
+		}
+		data, err := base64.StdEncoding.DecodeString(remaining[:end])
+		if err != nil {
+			t.Fatalf("response is not base64: %v", err)
+		}
+		decoded = append(decoded, data...)
+		remaining = remaining[end:]
+	}
+	reader := bytes.NewReader(decoded)
+
+	flags, payload, err := readFrame(reader)
+	if err != nil || flags != 0 {
+		t.Fatalf("failed to read message frame: flags %x, error %v", flags, err)
+	}
+	var reply healthpb.HealthCheckResponse
+	if err := proto.Unmarshal(payload, &reply); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if reply.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("got status %v, want SERVING", reply.GetStatus())
+	}
+
+	flags, trailers, err := readFrame(reader)
+	if err != nil || flags&flagTrailer == 0 {
+		t.Fatalf("failed to read trailer frame: flags %x, error %v", flags, err)
+	}
+	if !strings.Contains(string(trailers), "grpc-status: 0\r\n") {
+		t.Errorf("trailers %q do not report success", trailers)
+	}
+}
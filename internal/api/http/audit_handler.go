@@ -0,0 +1,212 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/travisbale/go-template/internal/api/pagination"
+	"github.com/travisbale/go-template/internal/audit"
+	"github.com/travisbale/go-template/sdk"
+	"github.com/travisbale/heimdall/jwt"
+)
+
+// auditReadPermission is the JWT permission required to list the audit log
+const auditReadPermission = "audit:read"
+
+// auditListSpec lists the audit log newest first. Only created_at supports
+// range filters; the other fields match exactly.
+var auditListSpec = &pagination.Spec{
+	Fields: map[string]pagination.Field{
+		"id":            {Type: pagination.UUID},
+		"created_at":    {Type: pagination.Time, Filterable: true},
+		"actor_id":      {Type: pagination.String, Filterable: true},
+		"action":        {Type: pagination.String, Filterable: true},
+		"resource_type": {Type: pagination.String, Filterable: true},
+		"resource_id":   {Type: pagination.String, Filterable: true},
+		"request_id":    {Type: pagination.String, Filterable: true},
+	},
+	DefaultOrder: []pagination.Order{{Field: "created_at", Desc: true}},
+	TieBreaker:   "id",
+}
+
+type auditLister interface {
+	List(ctx context.Context, filter audit.Filter, afterCreatedAt *time.Time, afterID *uuid.UUID, limit int32) ([]audit.Record, error)
+}
+
+// HandleListAudit lists the caller's tenant audit log. The caller needs the
+// audit:read permission.
+func HandleListAudit(log auditLister, codec *pagination.Codec) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, err := jwt.GetJWTClaims(r)
+		if err != nil {
+			respondProblem(w, sdk.Problem{Status: http.StatusUnauthorized})
+			return
+		}
+		if !slices.Contains(claims.Permissions, auditReadPermission) {
+			respondProblem(w, sdk.Problem{Status: http.StatusForbidden, Detail: "requires the " + auditReadPermission + " permission"})
+			return
+		}
+
+		query, ok := parseListQuery(w, r, codec, auditListSpec)
+		if !ok {
+			return
+		}
+
+		filter, err := auditFilter(query)
+		if err != nil {
+			respondProblem(w, sdk.Problem{
+				Status:        http.StatusBadRequest,
+				Detail:        "invalid list parameters",
+				InvalidParams: []sdk.InvalidParam{{Name: pagination.ParamFilter, Reason: err.Error()}},
+			})
+			return
+		}
+
+		records, err := log.List(r.Context(), filter,
+			pagination.After[time.Time](query, "created_at"),
+			pagination.After[uuid.UUID](query, "id"),
+			query.Limit())
+		if err != nil {
+			respondDomainError(w, "failed to list audit records", err)
+			return
+		}
+
+		records, next, err := pagination.Page(codec, query, records, func(record audit.Record) pagination.Cursor {
+			return pagination.Cursor{"created_at": record.CreatedAt, "id": record.ID}
+		})
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "failed to list audit records", err)
+			return
+		}
+
+		response := sdk.ListResponse[sdk.AuditRecord]{Items: make([]sdk.AuditRecord, len(records)), NextPageToken: next}
+		for i, record := range records {
+			response.Items[i] = sdk.AuditRecord{
+				ID:           record.ID.String(),
+				ActorID:      record.ActorID,
+				ActorType:    record.ActorType,
+				Action:       record.Action,
+				ResourceType: record.ResourceType,
+				ResourceID:   record.ResourceID,
+				Changes:      record.Changes,
+				RequestID:    record.RequestID,
+				CreatedAt:    record.CreatedAt,
+			}
+		}
+		respondJSON(w, http.StatusOK, response)
+	}
+}
+
+// auditFilter converts parsed filters to the store's filter, rejecting
+// operators the query cannot apply
+func auditFilter(query *pagination.Query) (audit.Filter, error) {
+	var filter audit.Filter
+	for _, f := range query.Filters {
+		if f.Field == "created_at" {
+			at := f.Value.(time.Time)
+			switch f.Op {
+			case pagination.GreaterOrEqual:
+				filter.CreatedFrom = &at
+			case pagination.Less:
+				filter.CreatedUntil = &at
+			default:
+				return audit.Filter{}, fmt.Errorf("operator %s is not supported for %q, use >= or <", f.Op, f.Field)
+			}
+			continue
+		}
+
+		if f.Op != pagination.Equal {
+			return audit.Filter{}, fmt.Errorf("operator %s is not supported for %q, use =", f.Op, f.Field)
+		}
+		value := f.Value.(string)
+		switch f.Field {
+		case "actor_id":
+			filter.ActorID = &value
+		case "action":
+			filter.Action = &value
+		case "resource_type":
+			filter.ResourceType = &value
+		case "resource_id":
+			filter.ResourceID = &value
+		case "request_id":
+			filter.RequestID = &value
+		}
+	}
+	return filter, nil
+}
+
+// AuthenticateWeb is the authentication middleware for the Connect and
+// gRPC-Web handler. It validates the JWT and attributes the audit entries
+// recorded by the calls, including the gRPC interceptor's fallback entries,
+// to the authenticated user and the request ID.
+func AuthenticateWeb(validator *jwt.Validator) func(http.Handler) http.Handler {
+	authenticate := jwt.Middleware(validator)
+	return func(next http.Handler) http.Handler {
+		return authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(auditContext(r)))
+		}))
+	}
+}
+
+// auditContext returns the request's context carrying its actor and request ID
+func auditContext(r *http.Request) context.Context {
+	ctx := r.Context()
+	if claims, err := jwt.GetJWTClaims(r); err == nil {
+		ctx = audit.WithActor(ctx, audit.Actor{ID: claims.Subject, Type: audit.ActorUser})
+	}
+	if requestID := middleware.GetReqID(ctx); requestID != "" {
+		ctx = audit.WithRequestID(ctx, requestID)
+	}
+	return ctx
+}
+
+// AuditMiddleware attributes the changes recorded while handling a request
+// to the authenticated user and the request ID, so it must run after the JWT
+// middleware. Successful POST, PUT, PATCH and DELETE requests whose handlers
+// recorded nothing get a fallback entry naming the method and path. It is best
+// effort: it is written after the response, outside the handler's
+// transaction, so a failure is only logged and the change stands. Handlers
+// must record their own entries, in the transaction that makes the change,
+// for the audit log to be complete.
+func AuditMiddleware(log *audit.Log) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := auditContext(r)
+
+			switch r.Method {
+			case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+			default:
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			ctx, recorded := audit.Track(ctx)
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if status >= http.StatusBadRequest || recorded() {
+				return
+			}
+
+			// The response has been sent, so record even if the client has gone
+			err := log.Record(context.WithoutCancel(ctx), audit.Event{
+				Action:       r.Method,
+				ResourceType: "http_request",
+				ResourceID:   r.URL.Path,
+			})
+			if err != nil {
+				slog.Error("Failed to record audit entry", "method", r.Method, "path", r.URL.Path, "error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package http
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// corsAllowedHeaders includes the headers sent by Connect and gRPC-Web clients
+// and If-Match for conditional writes
+var corsAllowedHeaders = strings.Join([]string{
+	"Authorization",
+	"Content-Type",
+	"If-Match",
+	"Connect-Protocol-Version",
+	"Connect-Timeout-Ms",
+	"Grpc-Timeout",
+	"X-Grpc-Web",
+	"X-User-Agent",
+}, ", ")
+
+// corsExposedHeaders lets browser clients read resource versions and gRPC
+// status headers on trailers-only responses
+var corsExposedHeaders = strings.Join([]string{
+	"ETag",
+	"Grpc-Status",
+	"Grpc-Message",
+	"Grpc-Status-Details-Bin",
+}, ", ")
+
+// CORSMiddleware allows browser clients on the given origins to call the API.
+// Preflight requests are answered before authentication runs.
+func CORSMiddleware(allowedOrigins []string) func(http.Handler) http.Handler {
+	allowAll := slices.Contains(allowedOrigins, "*")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" || (!allowAll && !slices.Contains(allowedOrigins, origin)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Origin")
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Expose-Headers", corsExposedHeaders)
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE")
+				w.Header().Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+				w.Header().Set("Access-Control-Max-Age", "7200")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
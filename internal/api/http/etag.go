@@ -0,0 +1,122 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/travisbale/go-template/sdk"
+)
+
+// setETag sets the response's ETag to the resource's version. Handlers set it
+// on reads and writes of versioned resources so that clients can send it back
+// in If-Match.
+func setETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", `"`+strconv.FormatInt(version, 10)+`"`)
+}
+
+// ifMatch is the precondition of a conditional write
+type ifMatch struct {
+	// versions lists the acceptable versions; nil means any version
+	versions []int64
+}
+
+// version returns the version the repository's versioned update must apply
+// to, given the version the handler read. It is nil for an unconditional
+// write, and the read version when that is listed, so that the update still
+// fails if the row changes in between. Otherwise the write is stale and
+// version returns false.
+func (m ifMatch) version(current int64) (*int64, bool) {
+	if m.versions == nil {
+		return nil, true
+	}
+	if !slices.Contains(m.versions, current) {
+		return nil, false
+	}
+	return &current, true
+}
+
+// parseIfMatch parses the If-Match header, a comma-separated list of entity
+// tags or "*" (RFC 9110, section 13.1.1). The write is unconditional when
+// the header is absent or "*". Comparison is strong, so weak tags and tags
+// that are not versions never match; if no tag can match, the response is a
+// 412 without touching the database. A malformed header gets a 400. In both
+// cases parseIfMatch returns false.
+func parseIfMatch(w http.ResponseWriter, r *http.Request) (ifMatch, bool) {
+	values := r.Header.Values("If-Match")
+	header := strings.TrimSpace(strings.Join(values, ","))
+	if header == "" || header == "*" {
+		return ifMatch{}, true
+	}
+
+	tags, err := parseEntityTags(header)
+	if err != nil {
+		respondProblem(w, sdk.Problem{
+			Status:        http.StatusBadRequest,
+			Detail:        "request has invalid headers",
+			InvalidParams: []sdk.InvalidParam{{Name: "If-Match", Reason: err.Error()}},
+		})
+		return ifMatch{}, false
+	}
+
+	match := ifMatch{versions: []int64{}}
+	for _, tag := range tags {
+		if tag.weak {
+			continue
+		}
+		if version, err := strconv.ParseInt(tag.opaque, 10, 64); err == nil {
+			match.versions = append(match.versions, version)
+		}
+	}
+	if len(match.versions) == 0 {
+		respondProblem(w, sdk.Problem{Status: http.StatusPreconditionFailed, Detail: "If-Match does not match the current version"})
+		return ifMatch{}, false
+	}
+	return match, true
+}
+
+// entityTag is a parsed entity tag without its quotes
+type entityTag struct {
+	weak   bool
+	opaque string
+}
+
+// parseEntityTags parses a comma-separated list of entity tags. Commas may
+// appear inside a tag, so the list is scanned rather than split.
+func parseEntityTags(header string) ([]entityTag, error) {
+	var tags []entityTag
+	rest := header
+	for {
+		rest = strings.TrimLeft(rest, " \t,")
+		if rest == "" {
+			break
+		}
+
+		var tag entityTag
+		if strings.HasPrefix(rest, "W/") {
+			tag.weak = true
+			rest = rest[2:]
+		}
+		if !strings.HasPrefix(rest, `"`) {
+			return nil, errors.New(`must be "*" or a list of quoted entity tags`)
+		}
+		end := strings.IndexByte(rest[1:], '"')
+		if end < 0 {
+			return nil, errors.New("entity tag is missing its closing quote")
+		}
+		tag.opaque = rest[1 : end+1]
+		tags = append(tags, tag)
+
+		// Tags are separated by a comma and optional whitespace
+		rest = strings.TrimLeft(rest[end+2:], " \t")
+		if rest != "" && rest[0] != ',' {
+			return nil, errors.New("entity tags must be separated by commas")
+		}
+	}
+	if len(tags) == 0 {
+		return nil, errors.New("no entity tags")
+	}
+	return tags, nil
+}
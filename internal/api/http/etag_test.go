@@ -0,0 +1,67 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []string
+		status  int
+		current int64
+		want    *int64
+		stale   bool
+	}{
+		{name: "absent", current: 3},
+		{name: "any", headers: []string{"*"}, current: 3},
+		{name: "single match", headers: []string{`"3"`}, current: 3, want: ptr(3)},
+		{name: "single stale", headers: []string{`"2"`}, current: 3, stale: true},
+		{name: "list with match", headers: []string{`"1", "3"`}, current: 3, want: ptr(3)},
+		{name: "list without match", headers: []string{`"1","2"`}, current: 3, stale: true},
+		{name: "repeated headers", headers: []string{`"1"`, `"3"`}, current: 3, want: ptr(3)},
+		{name: "weak and strong", headers: []string{`W/"3", "3"`}, current: 3, want: ptr(3)},
+		{name: "comma in tag", headers: []string{`"a,b", "3"`}, current: 3, want: ptr(3)},
+		{name: "only weak", headers: []string{`W/"3"`}, status: http.StatusPreconditionFailed},
+		{name: "only unknown", headers: []string{`"abc", W/"3"`}, status: http.StatusPreconditionFailed},
+		{name: "unquoted", headers: []string{`3`}, status: http.StatusBadRequest},
+		{name: "unterminated", headers: []string{`"3`}, status: http.StatusBadRequest},
+		{name: "missing comma", headers: []string{`"1" "3"`}, status: http.StatusBadRequest},
+		{name: "star in list", headers: []string{`*, "3"`}, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPut, "/", nil)
+			for _, header := range tt.headers {
+				request.Header.Add("If-Match", header)
+			}
+			recorder := httptest.NewRecorder()
+
+			match, ok := parseIfMatch(recorder, request)
+			if tt.status != 0 {
+				if ok || recorder.Code != tt.status {
+					t.Fatalf("got ok %v and status %d, want status %d", ok, recorder.Code, tt.status)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("parseIfMatch rejected the request with status %d", recorder.Code)
+			}
+
+			version, ok := match.version(tt.current)
+			if ok == tt.stale {
+				t.Fatalf("got ok %v, want stale %v", ok, tt.stale)
+			}
+			if (version == nil) != (tt.want == nil) || (version != nil && *version != *tt.want) {
+				t.Errorf("got version %v, want %v", version, tt.want)
+			}
+		})
+	}
+}
+
+func ptr(v int64) *int64 {
+	return &v
+}
@@ -1,11 +1,22 @@
 package http
 
 import (
+	"context"
 	"net/http"
+	"time"
 
+	"github.com/travisbale/go-template/internal/db/postgres"
 	"github.com/travisbale/go-template/sdk"
 )
 
+// readinessTimeout bounds how long a readiness probe waits on dependencies
+const readinessTimeout = 2 * time.Second
+
+type readinessChecker interface {
+	Health(ctx context.Context) error
+	Replicas() []postgres.ReplicaStatus
+}
+
 // HandleHealth returns the service health status
 func HandleHealth(w http.ResponseWriter, r *http.Request) {
 	response := sdk.HealthResponse{
@@ -14,3 +25,52 @@ func HandleHealth(w http.ResponseWriter, r *http.Request) {
 
 	respondJSON(w, http.StatusOK, response)
 }
+
+// HandleStarting reports that the service is not ready because it has not
+// yet connected to the database and checked its schema
+func HandleStarting(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusServiceUnavailable, sdk.ReadinessResponse{
+		Status: "STARTING",
+		Checks: []sdk.ReadinessCheck{{Name: "database", Status: "UNAVAILABLE", Error: "connecting"}},
+	})
+}
+
+// HandleReady reports whether the service can serve traffic. The primary
+// database must be reachable. Unhealthy read replicas are reported but only
+// degrade readiness, since reads fall back to the primary.
+func HandleReady(db readinessChecker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+
+		response := sdk.ReadinessResponse{Status: "OK"}
+		status := http.StatusOK
+
+		primary := sdk.ReadinessCheck{Name: "database", Status: "OK"}
+		if err := db.Health(ctx); err != nil {
+			primary.Status = "UNAVAILABLE"
+			primary.Error = err.Error()
+			response.Status = "UNAVAILABLE"
+			status = http.StatusServiceUnavailable
+		}
+		response.Checks = append(response.Checks, primary)
+
+		replicas := db.Replicas()
+		healthyReplicas := 0
+		for _, replica := range replicas {
+			check := sdk.ReadinessCheck{Name: "database_replica:" + replica.Host, Status: "OK"}
+			if replica.Healthy {
+				healthyReplicas++
+			} else {
+				check.Status = "UNAVAILABLE"
+				check.Error = replica.Error
+			}
+			response.Checks = append(response.Checks, check)
+		}
+		if len(replicas) > 0 && healthyReplicas == 0 && status == http.StatusOK {
+			response.Status = "DEGRADED"
+		}
+
+		respondJSON(w, status, response)
+	}
+}
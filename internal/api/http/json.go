@@ -2,11 +2,29 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"mime"
 	"net/http"
+	"reflect"
+	"strings"
 	"time"
+
+	"github.com/travisbale/go-template/internal/domain"
+	"github.com/travisbale/go-template/sdk"
 )
 
+// maxRequestBodySize limits the size of JSON request bodies
+const maxRequestBodySize = 1 << 20 // 1 MiB
+
+// validator is implemented by request types that check their own fields.
+// Returning sdk.ValidationErrors reports each invalid field individually.
+type validator interface {
+	Validate() error
+}
+
 // respondJSON sends a JSON response
 func respondJSON(writer http.ResponseWriter, status int, data any) {
 	writer.Header().Set("Content-Type", "application/json")
@@ -30,6 +48,195 @@ func respondError(writer http.ResponseWriter, status int, message string, err er
 	}
 }
 
+// respondProblem sends an RFC 9457 problem details response
+func respondProblem(writer http.ResponseWriter, problem sdk.Problem) {
+	if problem.Title == "" {
+		problem.Title = http.StatusText(problem.Status)
+	}
+
+	writer.Header().Set("Content-Type", "application/problem+json")
+	writer.WriteHeader(problem.Status)
+	if err := json.NewEncoder(writer).Encode(problem); err != nil {
+		slog.Error("Failed to encode problem response", "error", err, "status", problem.Status)
+	}
+}
+
+// respondDomainError maps domain errors from services and adapters to problem
+// responses, using the same status codes as the gRPC gateway. Other errors
+// are logged and reported as a 500 with the given message.
+func respondDomainError(writer http.ResponseWriter, message string, err error) {
+	var domainErr *domain.Error
+	if !errors.As(err, &domainErr) {
+		respondError(writer, http.StatusInternalServerError, message, err)
+		return
+	}
+
+	var status int
+	switch {
+	case errors.Is(domainErr.Kind, domain.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(domainErr.Kind, domain.ErrAlreadyExists):
+		status = http.StatusConflict
+	case errors.Is(domainErr.Kind, domain.ErrReferenceViolation), errors.Is(domainErr.Kind, domain.ErrInvalid):
+		status = http.StatusBadRequest
+	case errors.Is(domainErr.Kind, domain.ErrConflict):
+		status = http.StatusPreconditionFailed
+	default:
+		respondError(writer, http.StatusInternalServerError, message, err)
+		return
+	}
+	respondProblem(writer, sdk.Problem{Status: status, Detail: domainErr.Error()})
+}
+
+// decodeJSON decodes and validates a JSON request body. Unknown fields,
+// trailing data and bodies over maxRequestBodySize are rejected. On failure
+// it writes a problem response and returns false.
+func decodeJSON[T any](writer http.ResponseWriter, request *http.Request) (T, bool) {
+	var body T
+
+	if !hasJSONContentType(request) {
+		respondProblem(writer, sdk.Problem{
+			Status: http.StatusUnsupportedMediaType,
+			Detail: "Content-Type must be application/json",
+		})
+		return body, false
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(writer, request.Body, maxRequestBodySize))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&body); err != nil {
+		respondProblem(writer, decodeProblem(err))
+		return body, false
+	}
+	if decoder.More() {
+		respondProblem(writer, sdk.Problem{
+			Status: http.StatusBadRequest,
+			Detail: "request body must contain a single JSON value",
+		})
+		return body, false
+	}
+
+	v, ok := asValidator(&body)
+	if !ok {
+		respondProblem(writer, sdk.Problem{
+			Status: http.StatusBadRequest,
+			Detail: "request body must not be null",
+		})
+		return body, false
+	}
+	if v != nil {
+		if err := v.Validate(); err != nil {
+			respondProblem(writer, validationProblem(err))
+			return body, false
+		}
+	}
+
+	return body, true
+}
+
+// asValidator returns the validator implemented by a decoded body, whether
+// T is a value type with pointer or value methods or itself a pointer. It
+// returns false when T is a pointer and the body was null, since there is
+// nothing to validate or handle.
+func asValidator[T any](body *T) (validator, bool) {
+	if value := reflect.ValueOf(*body); value.Kind() == reflect.Pointer && value.IsNil() {
+		return nil, false
+	}
+	if v, ok := any(*body).(validator); ok {
+		return v, true
+	}
+	if v, ok := any(body).(validator); ok {
+		return v, true
+	}
+	return nil, true
+}
+
+func hasJSONContentType(request *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// decodeProblem translates a json decoding error into a problem response
+func decodeProblem(err error) sdk.Problem {
+	var (
+		syntaxErr   *json.SyntaxError
+		typeErr     *json.UnmarshalTypeError
+		maxBytesErr *http.MaxBytesError
+	)
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return sdk.Problem{Status: http.StatusBadRequest, Detail: "request body must not be empty"}
+	case errors.As(err, &maxBytesErr):
+		return sdk.Problem{
+			Status: http.StatusRequestEntityTooLarge,
+			Detail: fmt.Sprintf("request body must not exceed %d bytes", maxBytesErr.Limit),
+		}
+	case errors.As(err, &syntaxErr):
+		return sdk.Problem{
+			Status: http.StatusBadRequest,
+			Detail: fmt.Sprintf("malformed JSON at offset %d", syntaxErr.Offset),
+		}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return sdk.Problem{Status: http.StatusBadRequest, Detail: "malformed JSON"}
+	case errors.As(err, &typeErr):
+		name := typeErr.Field
+		if name == "" {
+			name = "$"
+		}
+		return sdk.Problem{
+			Status:        http.StatusBadRequest,
+			Detail:        "request body has invalid fields",
+			InvalidParams: []sdk.InvalidParam{{Name: name, Reason: "must be " + jsonTypeName(typeErr.Type)}},
+		}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		name := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return sdk.Problem{
+			Status:        http.StatusBadRequest,
+			Detail:        "request body has invalid fields",
+			InvalidParams: []sdk.InvalidParam{{Name: name, Reason: "unknown field"}},
+		}
+	default:
+		return sdk.Problem{Status: http.StatusBadRequest, Detail: "malformed request body"}
+	}
+}
+
+// validationProblem translates a Validate error into a problem response
+func validationProblem(err error) sdk.Problem {
+	var fieldErrs sdk.ValidationErrors
+	if errors.As(err, &fieldErrs) {
+		return sdk.Problem{
+			Status:        http.StatusBadRequest,
+			Detail:        "request body has invalid fields",
+			InvalidParams: fieldErrs,
+		}
+	}
+
+	return sdk.Problem{Status: http.StatusBadRequest, Detail: err.Error()}
+}
+
+// jsonTypeName maps a Go type to the JSON type a client should have sent
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
 // parseDate parses a date string in YYYY-MM-DD format
 func parseDate(dateStr string) (time.Time, error) {
 	return time.Parse("2006-01-02", dateStr)
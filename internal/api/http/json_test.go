@@ -0,0 +1,125 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/travisbale/go-template/internal/domain"
+	"github.com/travisbale/go-template/sdk"
+)
+
+type createRequest struct {
+	Name string `json:"name"`
+}
+
+func (c *createRequest) Validate() error {
+	if c.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+type valueRequest struct {
+	Name string `json:"name"`
+}
+
+func (v valueRequest) Validate() error {
+	if v.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+// decodeRequest decodes body as T and returns the problem detail written on
+// failure, or an empty string on success
+func decodeRequest[T any](t *testing.T, body string) string {
+	t.Helper()
+	request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+
+	if _, ok := decodeJSON[T](recorder, request); ok {
+		return ""
+	}
+	var problem sdk.Problem
+	if err := json.Unmarshal(recorder.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("failed to decode problem: %v", err)
+	}
+	return problem.Detail
+}
+
+func TestDecodeJSONValidates(t *testing.T) {
+	tests := []struct {
+		name   string
+		decode func(t *testing.T, body string) string
+	}{
+		{"pointer methods", decodeRequest[createRequest]},
+		{"pointer type", decodeRequest[*createRequest]},
+		{"value methods", decodeRequest[valueRequest]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if detail := tt.decode(t, `{"name":"widget"}`); detail != "" {
+				t.Errorf("valid body rejected: %s", detail)
+			}
+			if detail := tt.decode(t, `{"name":""}`); detail != "name is required" {
+				t.Errorf("invalid body: got %q, want the validation error", detail)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONRejectsNullPointer(t *testing.T) {
+	if detail := decodeRequest[*createRequest](t, `null`); detail != "request body must not be null" {
+		t.Errorf("got %q, want null to be rejected", detail)
+	}
+}
+
+func TestRespondDomainError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		detail string
+	}{
+		{"not found", &domain.Error{Kind: domain.ErrNotFound, Resource: "widget"}, http.StatusNotFound, "widget not found"},
+		{"already exists", &domain.Error{Kind: domain.ErrAlreadyExists, Resource: "widget"}, http.StatusConflict, "widget already exists"},
+		{"reference violation", &domain.Error{Kind: domain.ErrReferenceViolation, Resource: "widget"}, http.StatusBadRequest, "widget conflicts with a related resource"},
+		{"invalid", &domain.Error{Kind: domain.ErrInvalid, Resource: "widget"}, http.StatusBadRequest, "widget is invalid"},
+		{"conflict", &domain.Error{Kind: domain.ErrConflict, Resource: "widget"}, http.StatusPreconditionFailed, "widget has changed since it was read"},
+		{"wrapped", fmt.Errorf("failed to update widget: %w", &domain.Error{Kind: domain.ErrNotFound, Resource: "widget", Err: errors.New("no rows")}), http.StatusNotFound, "widget not found"},
+		{"unknown kind", &domain.Error{Kind: errors.New("unknown"), Resource: "widget"}, http.StatusInternalServerError, ""},
+		{"internal", errors.New("connection refused"), http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			respondDomainError(recorder, "Failed to update widget", tt.err)
+
+			if recorder.Code != tt.status {
+				t.Fatalf("status = %d, want %d", recorder.Code, tt.status)
+			}
+			if tt.detail == "" {
+				if strings.Contains(recorder.Body.String(), "connection refused") {
+					t.Errorf("internal error leaked to the client: %s", recorder.Body)
+				}
+				return
+			}
+
+			var problem sdk.Problem
+			if err := json.NewDecoder(recorder.Body).Decode(&problem); err != nil {
+				t.Fatalf("failed to decode problem: %v", err)
+			}
+			if problem.Status != tt.status || problem.Detail != tt.detail {
+				t.Errorf("got problem %d %q, want %d %q", problem.Status, problem.Detail, tt.status, tt.detail)
+			}
+		})
+	}
+}
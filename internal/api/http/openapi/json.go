@@ -0,0 +1,14 @@
+package openapi
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// WriteJSON writes the document as indented JSON with a stable key order,
+// so that the output can be committed and diffed in CI
+func WriteJSON(w io.Writer, doc *Document) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
@@ -0,0 +1,253 @@
+package openapi
+
+import (
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/travisbale/go-template/sdk"
+)
+
+// securitySchemeName is the name of the bearer JWT security scheme
+const securitySchemeName = "bearerAuth"
+
+// pathParam matches chi path parameters, including optional regexp constraints
+var pathParam = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// Route describes an endpoint's contract alongside its chi handler
+type Route struct {
+	// ID is the unique operationId, e.g. "getWidget"
+	ID      string
+	Summary string
+	Tags    []string
+	// Request is a zero value of the request body type, or nil if the endpoint has no body
+	Request any
+	// Response is a zero value of the success response type, or nil for an empty response
+	Response any
+	// ContentType is the media type of the success response, defaulting to application/json
+	ContentType string
+	// Status is the success status code, defaulting to 200
+	Status int
+	// Errors lists the error status codes the endpoint can return as problem responses
+	Errors []int
+	// Query lists additional query parameters
+	Query []*Parameter
+	// Paginated adds the standard page_size, page_token, order_by and filter parameters
+	Paginated bool
+}
+
+// Spec accumulates route metadata and produces the OpenAPI document
+type Spec struct {
+	info    Info
+	paths   map[string]*PathItem
+	schemas *schemas
+}
+
+// NewSpec creates an empty spec for the API
+func NewSpec(title, version string) *Spec {
+	return &Spec{
+		info:    Info{Title: title, Version: version},
+		paths:   make(map[string]*PathItem),
+		schemas: newSchemas(),
+	}
+}
+
+// Document returns the OpenAPI document for every route registered so far
+func (s *Spec) Document() *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    s.info,
+		Paths:   s.paths,
+		Components: Components{
+			Schemas: s.schemas.components,
+			SecuritySchemes: map[string]*SecurityScheme{
+				securitySchemeName: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+}
+
+// ServeHTTP serves the document as JSON
+func (s *Spec) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := WriteJSON(w, s.Document()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Spec) add(method, pattern string, secured bool, route Route) {
+	if route.Status == 0 {
+		route.Status = http.StatusOK
+	}
+	if route.ContentType == "" {
+		route.ContentType = "application/json"
+	}
+
+	op := &Operation{
+		OperationID: route.ID,
+		Summary:     route.Summary,
+		Tags:        route.Tags,
+		Responses:   make(map[string]*Response),
+	}
+
+	// Path parameters are taken from the chi pattern
+	for _, match := range pathParam.FindAllStringSubmatch(pattern, -1) {
+		op.Parameters = append(op.Parameters, &Parameter{
+			Name: match[1], In: "path", Required: true, Schema: &Schema{Type: "string"},
+		})
+	}
+	if route.Paginated {
+		op.Parameters = append(op.Parameters, paginationParameters()...)
+	}
+	op.Parameters = append(op.Parameters, route.Query...)
+
+	if route.Request != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]*MediaType{"application/json": {Schema: s.schemas.of(route.Request)}},
+		}
+	}
+
+	success := &Response{Description: http.StatusText(route.Status)}
+	if route.Response != nil {
+		success.Content = map[string]*MediaType{route.ContentType: {Schema: s.schemas.of(route.Response)}}
+	}
+	op.Responses[strconv.Itoa(route.Status)] = success
+
+	statuses := append([]int{}, route.Errors...)
+	if route.Request != nil || route.Paginated {
+		statuses = append(statuses, http.StatusBadRequest)
+	}
+	if secured {
+		statuses = append(statuses, http.StatusUnauthorized)
+		op.Security = []map[string][]string{{securitySchemeName: {}}}
+	}
+	sort.Ints(statuses)
+	for _, status := range statuses {
+		op.Responses[strconv.Itoa(status)] = &Response{
+			Description: http.StatusText(status),
+			Content:     map[string]*MediaType{"application/problem+json": {Schema: s.schemas.of(sdk.Problem{})}},
+		}
+	}
+
+	openAPIPath := pathParam.ReplaceAllString(pattern, "{$1}")
+	item, ok := s.paths[openAPIPath]
+	if !ok {
+		item = &PathItem{}
+		s.paths[openAPIPath] = item
+	}
+
+	switch method {
+	case http.MethodGet:
+		item.Get = op
+	case http.MethodPut:
+		item.Put = op
+	case http.MethodPost:
+		item.Post = op
+	case http.MethodDelete:
+		item.Delete = op
+	case http.MethodPatch:
+		item.Patch = op
+	}
+}
+
+func paginationParameters() []*Parameter {
+	minimum := float64(0)
+	return []*Parameter{
+		{Name: "page_size", In: "query", Description: "Maximum number of items to return", Schema: &Schema{Type: "integer", Format: "int32", Minimum: &minimum}},
+		{Name: "page_token", In: "query", Description: "Token from a previous response's next_page_token", Schema: &Schema{Type: "string"}},
+		{Name: "order_by", In: "query", Description: "Comma-separated sort fields, each optionally followed by desc", Schema: &Schema{Type: "string"}},
+		{Name: "filter", In: "query", Description: "Filter expressions joined with AND", Schema: &Schema{Type: "string"}},
+	}
+}
+
+// Router wraps a chi router so that every route is registered together with its metadata
+type Router struct {
+	chi     chi.Router
+	spec    *Spec
+	prefix  string
+	secured bool
+}
+
+// NewRouter wraps a chi router, recording routes into spec
+func NewRouter(router chi.Router, spec *Spec) *Router {
+	return &Router{chi: router, spec: spec}
+}
+
+// Use appends middleware to the router's stack
+func (r *Router) Use(middlewares ...func(http.Handler) http.Handler) {
+	r.chi.Use(middlewares...)
+}
+
+// Authenticated appends the authentication middleware and marks every route
+// registered afterwards on this router as requiring a bearer token
+func (r *Router) Authenticated(middleware func(http.Handler) http.Handler) {
+	r.chi.Use(middleware)
+	r.secured = true
+}
+
+// Route mounts a sub-router along a pattern
+func (r *Router) Route(pattern string, fn func(*Router)) {
+	r.chi.Route(pattern, func(sub chi.Router) {
+		fn(&Router{chi: sub, spec: r.spec, prefix: path.Join(r.prefix, pattern), secured: r.secured})
+	})
+}
+
+// Group creates an inline group with its own middleware stack
+func (r *Router) Group(fn func(*Router)) {
+	r.chi.Group(func(sub chi.Router) {
+		fn(&Router{chi: sub, spec: r.spec, prefix: r.prefix, secured: r.secured})
+	})
+}
+
+// Mount attaches a handler that is not described in the document, such as
+// the gRPC gateway whose contract lives in the proto definitions
+func (r *Router) Mount(pattern string, handler http.Handler) {
+	r.chi.Handle(pattern, handler)
+}
+
+// Handle registers a handler and its metadata for a method and pattern
+func (r *Router) Handle(method, pattern string, handler http.HandlerFunc, route Route) {
+	r.chi.MethodFunc(method, pattern, handler)
+	r.spec.add(method, r.fullPath(pattern), r.secured, route)
+}
+
+// Get registers a GET route
+func (r *Router) Get(pattern string, handler http.HandlerFunc, route Route) {
+	r.Handle(http.MethodGet, pattern, handler, route)
+}
+
+// Post registers a POST route
+func (r *Router) Post(pattern string, handler http.HandlerFunc, route Route) {
+	r.Handle(http.MethodPost, pattern, handler, route)
+}
+
+// Put registers a PUT route
+func (r *Router) Put(pattern string, handler http.HandlerFunc, route Route) {
+	r.Handle(http.MethodPut, pattern, handler, route)
+}
+
+// Patch registers a PATCH route
+func (r *Router) Patch(pattern string, handler http.HandlerFunc, route Route) {
+	r.Handle(http.MethodPatch, pattern, handler, route)
+}
+
+// Delete registers a DELETE route
+func (r *Router) Delete(pattern string, handler http.HandlerFunc, route Route) {
+	r.Handle(http.MethodDelete, pattern, handler, route)
+}
+
+func (r *Router) fullPath(pattern string) string {
+	if r.prefix == "" {
+		return pattern
+	}
+	full := path.Join(r.prefix, pattern)
+	if strings.HasSuffix(pattern, "/") && !strings.HasSuffix(full, "/") {
+		full += "/"
+	}
+	return full
+}
@@ -0,0 +1,110 @@
+package openapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/travisbale/go-template/sdk"
+)
+
+func TestSpecPathParameters(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		params  []string
+	}{
+		{"/widgets", "/widgets", nil},
+		{"/widgets/{id}", "/widgets/{id}", []string{"id"}},
+		{"/widgets/{id:[0-9]+}", "/widgets/{id}", []string{"id"}},
+		{"/tenants/{tenantID}/widgets/{widgetID:[a-f0-9-]+}/parts", "/tenants/{tenantID}/widgets/{widgetID}/parts", []string{"tenantID", "widgetID"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			spec := NewSpec("test", "v1")
+			spec.add(http.MethodGet, tt.pattern, false, Route{ID: "op"})
+
+			item, ok := spec.paths[tt.path]
+			if !ok || item.Get == nil {
+				t.Fatalf("paths = %v, want a GET operation at %s", spec.paths, tt.path)
+			}
+
+			var names []string
+			for _, param := range item.Get.Parameters {
+				if param.In != "path" || !param.Required {
+					t.Errorf("parameter %s is %s (required %t), want a required path parameter", param.Name, param.In, param.Required)
+				}
+				names = append(names, param.Name)
+			}
+			if !slices.Equal(names, tt.params) {
+				t.Errorf("path parameters = %v, want %v", names, tt.params)
+			}
+		})
+	}
+}
+
+func TestSpecResponses(t *testing.T) {
+	spec := NewSpec("test", "v1")
+	spec.add(http.MethodPost, "/widgets", true, Route{
+		ID:       "createWidget",
+		Request:  sdk.AuditRecord{},
+		Response: sdk.AuditRecord{},
+		Status:   http.StatusCreated,
+		Errors:   []int{http.StatusConflict},
+	})
+	spec.add(http.MethodGet, "/widgets", false, Route{ID: "listWidgets", Paginated: true})
+
+	create := spec.paths["/widgets"].Post
+	if create == nil || create.RequestBody == nil || len(create.Security) != 1 {
+		t.Fatalf("create = %+v, want a secured operation with a request body", create)
+	}
+	for _, status := range []string{"201", "400", "401", "409"} {
+		if _, ok := create.Responses[status]; !ok {
+			t.Errorf("create has no %s response", status)
+		}
+	}
+
+	list := spec.paths["/widgets"].Get
+	if list == nil || list.Security != nil {
+		t.Fatalf("list = %+v, want an unsecured operation", list)
+	}
+	if _, ok := list.Responses["401"]; ok {
+		t.Error("unsecured list documents a 401 response")
+	}
+	var names []string
+	for _, param := range list.Parameters {
+		names = append(names, param.Name)
+	}
+	if want := []string{"page_size", "page_token", "order_by", "filter"}; !slices.Equal(names, want) {
+		t.Errorf("list parameters = %v, want %v", names, want)
+	}
+}
+
+func TestRouterRecordsNestedRoutes(t *testing.T) {
+	spec := NewSpec("test", "v1")
+	router := NewRouter(chi.NewRouter(), spec)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(chi.URLParam(r, "id"))) //nolint:errcheck
+	}
+
+	router.Route("/v1", func(api *Router) {
+		api.Group(func(api *Router) {
+			api.Authenticated(func(next http.Handler) http.Handler { return next })
+			api.Get("/widgets/{id:[0-9]+}", handler, Route{ID: "getWidget"})
+		})
+	})
+
+	op := spec.paths["/v1/widgets/{id}"]
+	if op == nil || op.Get == nil || op.Get.Security == nil {
+		t.Fatalf("paths = %v, want a secured GET at /v1/widgets/{id}", spec.paths)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.chi.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/widgets/42", nil))
+	if recorder.Body.String() != "42" {
+		t.Errorf("handler got id %q, want 42", recorder.Body.String())
+	}
+}
@@ -0,0 +1,172 @@
+package openapi
+
+import (
+	"encoding"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	uuidType          = reflect.TypeFor[uuid.UUID]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// genericArgs matches the package-qualified type arguments of a generic type name
+var genericArgs = regexp.MustCompile(`[\w./-]*\.`)
+
+// schemas generates JSON schemas from Go types, registering named struct
+// types as reusable components
+type schemas struct {
+	components map[string]*Schema
+}
+
+func newSchemas() *schemas {
+	return &schemas{components: make(map[string]*Schema)}
+}
+
+// of returns the schema for the type of value, or nil if value is nil
+func (s *schemas) of(value any) *Schema {
+	if value == nil {
+		return nil
+	}
+	return s.schema(reflect.TypeOf(value))
+}
+
+func (s *schemas) schema(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Pointer {
+		schema := s.schema(t.Elem())
+		if schema.Ref != "" {
+			// Siblings of $ref are not merged into it, so nullability needs anyOf
+			return &Schema{AnyOf: []*Schema{schema, {Type: "null"}}}
+		}
+		if name, ok := schema.Type.(string); ok {
+			schema.Type = []string{name, "null"}
+		}
+		return schema
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType) {
+			return &Schema{Type: "string"}
+		}
+		return s.ref(t)
+	default:
+		// Interfaces and anything else accept any JSON value
+		return &Schema{}
+	}
+}
+
+// ref registers a struct type as a component and returns a reference to it
+func (s *schemas) ref(t reflect.Type) *Schema {
+	name := componentName(t)
+	if t.Name() == "" {
+		return s.object(t)
+	}
+
+	if _, ok := s.components[name]; !ok {
+		// Reserve the name first so that recursive types terminate
+		s.components[name] = &Schema{}
+		*s.components[name] = *s.object(t)
+	}
+
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// object builds an object schema from the exported fields of a struct
+func (s *schemas) object(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+		embeddedType, pointer := embeddedStruct(field)
+		if !field.IsExported() && embeddedType == nil {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || (!field.IsExported() && name != "") {
+			continue
+		}
+
+		// Embedded structs without a name are flattened like encoding/json
+		// does, even when their type is unexported. The fields of a nil
+		// embedded pointer are omitted, so none of them are required.
+		if embeddedType != nil && name == "" {
+			embedded := s.object(embeddedType)
+			for key, value := range embedded.Properties {
+				schema.Properties[key] = value
+			}
+			if !pointer {
+				schema.Required = append(schema.Required, embedded.Required...)
+			}
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = s.schema(field.Type)
+
+		if !strings.Contains(opts, "omitempty") && !strings.Contains(opts, "omitzero") && field.Type.Kind() != reflect.Pointer {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+
+	return schema
+}
+
+// embeddedStruct returns the struct type of an embedded struct or struct
+// pointer field, and whether it is a pointer
+func embeddedStruct(field reflect.StructField) (reflect.Type, bool) {
+	if !field.Anonymous {
+		return nil, false
+	}
+	t, pointer := field.Type, field.Type.Kind() == reflect.Pointer
+	if pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, false
+	}
+	return t, pointer
+}
+
+// componentName turns a Go type name into a component name, so that
+// ListResponse[github.com/org/svc/sdk.Widget] becomes ListResponse_Widget
+func componentName(t reflect.Type) string {
+	name := genericArgs.ReplaceAllString(t.Name(), "")
+	name = strings.NewReplacer("[", "_", "]", "", ",", "_", "*", "").Replace(name)
+	return name
+}
@@ -0,0 +1,166 @@
+package openapi
+
+import (
+	"encoding/json"
+	"maps"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/travisbale/go-template/sdk"
+)
+
+type testPair[K, V any] struct {
+	Key   K `json:"key"`
+	Value V `json:"value"`
+}
+
+type testBase struct {
+	ID      string `json:"id"`
+	Comment string `json:"comment,omitempty"`
+}
+
+type testAudit struct {
+	Created time.Time `json:"created"`
+}
+
+type testNode struct {
+	testBase
+	*testAudit
+	Parent   *testNode         `json:"parent"`
+	Children []testNode        `json:"children"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Weight   *float64          `json:"weight"`
+	Tags     []string          `json:"tags,omitzero"`
+	Owner    uuid.UUID         `json:"owner"`
+	Data     []byte            `json:"data"`
+	Extra    any               `json:"extra"`
+	Named    testBase          `json:"named"`
+	Inline   struct {
+		Flag bool `json:"flag"`
+	} `json:"inline"`
+	Untagged string
+	Skipped  string `json:"-"`
+	hidden   string //nolint:unused
+}
+
+func TestComponentName(t *testing.T) {
+	tests := []struct {
+		t    reflect.Type
+		want string
+	}{
+		{reflect.TypeFor[sdk.AuditRecord](), "AuditRecord"},
+		{reflect.TypeFor[sdk.ListResponse[sdk.AuditRecord]](), "ListResponse_AuditRecord"},
+		{reflect.TypeFor[testPair[string, int]](), "testPair_string_int"},
+		{reflect.TypeFor[testPair[sdk.AuditRecord, *sdk.Problem]](), "testPair_AuditRecord_Problem"},
+		{reflect.TypeFor[sdk.ListResponse[testPair[uuid.UUID, sdk.AuditRecord]]](), "ListResponse_testPair_UUID_AuditRecord"},
+	}
+
+	for _, tt := range tests {
+		if got := componentName(tt.t); got != tt.want {
+			t.Errorf("componentName(%s) = %q, want %q", tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestSchemaScalars(t *testing.T) {
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{true, `{"type":"boolean"}`},
+		{int32(0), `{"type":"integer","format":"int32"}`},
+		{uint8(0), `{"type":"integer","format":"int32"}`},
+		{0, `{"type":"integer","format":"int64"}`},
+		{float32(0), `{"type":"number","format":"float"}`},
+		{0.0, `{"type":"number","format":"double"}`},
+		{"", `{"type":"string"}`},
+		{time.Time{}, `{"type":"string","format":"date-time"}`},
+		{uuid.UUID{}, `{"type":"string","format":"uuid"}`},
+		{[]byte{}, `{"type":"string","format":"byte"}`},
+		{[]int{}, `{"type":"array","items":{"type":"integer","format":"int64"}}`},
+		{map[string]bool{}, `{"type":"object","additionalProperties":{"type":"boolean"}}`},
+		{new(string), `{"type":["string","null"]}`},
+		{new(time.Time), `{"type":["string","null"],"format":"date-time"}`},
+		{new([]string), `{"type":["array","null"],"items":{"type":"string"}}`},
+		{new(sdk.AuditRecord), `{"anyOf":[{"$ref":"#/components/schemas/AuditRecord"},{"type":"null"}]}`},
+	}
+
+	for _, tt := range tests {
+		got := encode(t, newSchemas().of(tt.value))
+		if got != tt.want {
+			t.Errorf("schema of %T = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSchemaStruct(t *testing.T) {
+	s := newSchemas()
+	ref := s.of(testNode{})
+	if ref.Ref != "#/components/schemas/testNode" {
+		t.Fatalf("ref = %q, want testNode component", ref.Ref)
+	}
+	node := s.components["testNode"]
+	if node == nil {
+		t.Fatalf("testNode was not registered, components: %v", slices.Collect(maps.Keys(s.components)))
+	}
+
+	wantProperties := []string{
+		"id", "comment", "created", "parent", "children", "labels", "weight",
+		"tags", "owner", "data", "extra", "named", "inline", "Untagged",
+	}
+	if got := slices.Sorted(maps.Keys(node.Properties)); !slices.Equal(got, slices.Sorted(slices.Values(wantProperties))) {
+		t.Errorf("properties = %v, want %v", got, wantProperties)
+	}
+
+	// omitempty, omitzero, pointers and the fields of embedded pointers are optional
+	wantRequired := []string{"children", "data", "extra", "id", "inline", "named", "owner", "Untagged"}
+	if got := slices.Sorted(slices.Values(node.Required)); !slices.Equal(got, slices.Sorted(slices.Values(wantRequired))) {
+		t.Errorf("required = %v, want %v", got, wantRequired)
+	}
+
+	tests := map[string]string{
+		"parent":   `{"anyOf":[{"$ref":"#/components/schemas/testNode"},{"type":"null"}]}`,
+		"children": `{"type":"array","items":{"$ref":"#/components/schemas/testNode"}}`,
+		"weight":   `{"type":["number","null"],"format":"double"}`,
+		"extra":    `{}`,
+		"named":    `{"$ref":"#/components/schemas/testBase"}`,
+		"inline":   `{"type":"object","properties":{"flag":{"type":"boolean"}},"required":["flag"]}`,
+	}
+	for name, want := range tests {
+		if got := encode(t, node.Properties[name]); got != want {
+			t.Errorf("property %s = %s, want %s", name, got, want)
+		}
+	}
+
+	// Named struct fields are components; flattened and anonymous ones are not
+	if _, ok := s.components["testBase"]; !ok {
+		t.Error("testBase was not registered")
+	}
+	if _, ok := s.components["testAudit"]; ok {
+		t.Error("embedded testAudit was registered as a component")
+	}
+}
+
+func TestSchemaGenericComponent(t *testing.T) {
+	s := newSchemas()
+	ref := s.of(sdk.ListResponse[sdk.AuditRecord]{})
+	if ref.Ref != "#/components/schemas/ListResponse_AuditRecord" {
+		t.Fatalf("ref = %q, want ListResponse_AuditRecord", ref.Ref)
+	}
+	items := s.components["ListResponse_AuditRecord"].Properties["items"]
+	if got := encode(t, items); got != `{"type":"array","items":{"$ref":"#/components/schemas/AuditRecord"}}` {
+		t.Errorf("items = %s, want an array of AuditRecord", got)
+	}
+}
+
+func encode(t *testing.T, schema *Schema) string {
+	t.Helper()
+	data, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatalf("failed to encode schema: %v", err)
+	}
+	return string(data)
+}
@@ -0,0 +1,33 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/travisbale/go-template/internal/api/pagination"
+	"github.com/travisbale/go-template/sdk"
+)
+
+// parseListQuery validates the list parameters of a request against the
+// endpoint's spec. On failure it writes a 400 problem response and returns false.
+func parseListQuery(w http.ResponseWriter, r *http.Request, codec *pagination.Codec, spec *pagination.Spec) (*pagination.Query, bool) {
+	params, err := pagination.FromQuery(r.URL.Query())
+	if err == nil {
+		var query *pagination.Query
+		if query, err = codec.Parse(spec, params); err == nil {
+			return query, true
+		}
+	}
+
+	var paramErr *pagination.Error
+	if errors.As(err, &paramErr) {
+		respondProblem(w, sdk.Problem{
+			Status:        http.StatusBadRequest,
+			Detail:        "invalid list parameters",
+			InvalidParams: []sdk.InvalidParam{{Name: paramErr.Param, Reason: paramErr.Reason}},
+		})
+	} else {
+		respondError(w, http.StatusInternalServerError, "failed to parse list parameters", err)
+	}
+	return nil, false
+}
@@ -3,11 +3,17 @@ package http
 import (
 	"context"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/travisbale/go-template/internal/api/http/openapi"
+	"github.com/travisbale/go-template/internal/api/pagination"
+	"github.com/travisbale/go-template/internal/audit"
 	"github.com/travisbale/go-template/internal/db/postgres"
+	"github.com/travisbale/go-template/internal/pubsub"
+	"github.com/travisbale/go-template/sdk"
 	"github.com/travisbale/heimdall/jwt"
 )
 
@@ -15,41 +21,174 @@ type Config struct {
 	Address      string
 	JWTValidator *jwt.Validator
 	DB           *postgres.DB
-	Environment  string // "development", "staging", "production"
+	PageTokens   *pagination.Codec
+	Audit        *audit.Log   // Records changes made through the API
+	Hub          *pubsub.Hub  // Messages streamed to clients over SSE and WebSocket
+	Gateway      http.Handler // gRPC services transcoded to REST, served under /v1
+	GRPCWeb      http.Handler // gRPC services over Connect and gRPC-Web, authenticating requests itself
+	GRPCServices []string     // Services served by GRPCWeb at /{service}/{method}
+	CORSOrigins  []string     // Origins allowed to call the API from a browser
+	Environment  string       // "development", "staging", "production"
+	Version      string
 }
 
+// Server serves the HTTP API. A server from NewStartupServer answers only the
+// health probes, reporting not ready, until Install adds the API routes.
 type Server struct {
 	*http.Server
+	spec   *openapi.Spec
+	router atomic.Pointer[chi.Mux]
 }
 
+// NewServer creates a server with the API routes installed
 func NewServer(config *Config) *Server {
+	server := NewStartupServer(config.Address)
+	server.Install(config)
+	return server
+}
+
+// NewStartupServer creates a server that can listen before the API's
+// dependencies are available. /healthz succeeds, so the process is not
+// restarted while it waits for the database, and /readyz and every other
+// route return 503 until Install is called.
+func NewStartupServer(address string) *Server {
+	server := &Server{}
+	server.Server = &http.Server{
+		Addr:              address,
+		Handler:           http.HandlerFunc(server.serveHTTP),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	router := chi.NewRouter()
+	router.Use(middleware.Recoverer)
+	router.Get("/healthz", HandleHealth)
+	router.Get("/readyz", HandleStarting)
+	router.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "1")
+		respondProblem(w, sdk.Problem{Status: http.StatusServiceUnavailable, Detail: "the service is starting"})
+	})
+	server.router.Store(router)
+
+	return server
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.router.Load().ServeHTTP(w, r)
+}
+
+// Install adds the API routes, replacing the startup routes
+func (s *Server) Install(config *Config) {
 	router := chi.NewRouter()
+	spec := openapi.NewSpec("app", config.Version)
+	api := openapi.NewRouter(router, spec)
 
 	// Global middleware
+	router.Use(StreamTokenMiddleware)
 	router.Use(middleware.Logger)
 	router.Use(middleware.Recoverer)
 	router.Use(middleware.RequestID)
+	if len(config.CORSOrigins) > 0 {
+		router.Use(CORSMiddleware(config.CORSOrigins))
+	}
 
 	// Health check endpoint (public, no auth required)
-	router.Get("/healthz", HandleHealth)
+	api.Get("/healthz", HandleHealth, openapi.Route{
+		ID:       "getHealth",
+		Summary:  "Report service health",
+		Tags:     []string{"health"},
+		Response: sdk.HealthResponse{},
+	})
+
+	// Readiness check endpoint (public, verifies database connectivity)
+	api.Get("/readyz", HandleReady(config.DB), openapi.Route{
+		ID:       "getReadiness",
+		Summary:  "Report whether the service can serve traffic",
+		Tags:     []string{"health"},
+		Response: sdk.ReadinessResponse{},
+	})
+
+	// OpenAPI document describing every route registered through api
+	router.Method(http.MethodGet, "/openapi.json", spec)
+
+	// Browser access to gRPC services via Connect and gRPC-Web. The handler
+	// authenticates requests itself so that failures use the protocol's errors.
+	if config.GRPCWeb != nil && len(config.GRPCServices) > 0 {
+		for _, service := range config.GRPCServices {
+			api.Mount("/"+service+"/*", config.GRPCWeb)
+		}
+	}
 
 	// API v1 routes
-	router.Route("/v1", func(router chi.Router) {
+	api.Route("/v1", func(api *openapi.Router) {
+		// Event streams for browser clients
+		api.Group(func(api *openapi.Router) {
+			api.Authenticated(jwt.Middleware(config.JWTValidator))
+			api.Get("/events", HandleEvents(config.Hub), openapi.Route{
+				ID:          "streamEvents",
+				Summary:     "Stream events on the given topics as server-sent events",
+				Tags:        []string{"events"},
+				Response:    sdk.StreamMessage{},
+				ContentType: "text/event-stream",
+				Query:       []*openapi.Parameter{streamTopicsParameter},
+				Errors:      []int{http.StatusBadRequest},
+			})
+			api.Get("/events/ws", HandleWebSocket(config.Hub, config.CORSOrigins), openapi.Route{
+				ID:      "streamEventsWebSocket",
+				Summary: "Stream events on the given topics over a WebSocket",
+				Tags:    []string{"events"},
+				Status:  http.StatusSwitchingProtocols,
+				Query:   []*openapi.Parameter{streamTopicsParameter},
+				Errors:  []int{http.StatusBadRequest},
+			})
+		})
+
+		// Audit log of the caller's tenant
+		api.Group(func(api *openapi.Router) {
+			api.Authenticated(jwt.Middleware(config.JWTValidator))
+			api.Get("/audit", HandleListAudit(config.Audit, config.PageTokens), openapi.Route{
+				ID:        "listAuditRecords",
+				Summary:   "List audit records, newest first",
+				Tags:      []string{"audit"},
+				Response:  sdk.ListResponse[sdk.AuditRecord]{},
+				Paginated: true,
+				Errors:    []int{http.StatusForbidden},
+			})
+		})
+
 		// Add your authenticated routes here
 		// Example:
-		// router.Group(func(r chi.Router) {
-		//     r.Use(AuthMiddleware(config.JWTValidator))
-		//     r.Get("/resource", HandleGetResource)
+		// api.Group(func(api *openapi.Router) {
+		//     api.Authenticated(jwt.Middleware(config.JWTValidator))
+		//     api.Use(AuditMiddleware(config.Audit))
+		//     api.Get("/resource/{id}", HandleGetResource, openapi.Route{
+		//         ID:       "getResource",
+		//         Response: sdk.Resource{},
+		//         Errors:   []int{http.StatusNotFound},
+		//     })
 		// })
+
+		// Routes not handled above fall through to the gRPC gateway
+		if config.Gateway != nil {
+			api.Group(func(api *openapi.Router) {
+				api.Authenticated(jwt.Middleware(config.JWTValidator))
+				api.Use(AuditMiddleware(config.Audit))
+				api.Mount("/*", config.Gateway)
+			})
+		}
 	})
 
-	return &Server{
-		&http.Server{
-			Addr:              config.Address,
-			Handler:           router,
-			ReadHeaderTimeout: 5 * time.Second,
-		},
+	// Shutdown waits for active requests, so end the event streams as it begins
+	if config.Hub != nil {
+		s.RegisterOnShutdown(config.Hub.Close)
 	}
+
+	s.spec = spec
+	s.router.Store(router)
+}
+
+// OpenAPI returns the OpenAPI document for the server's routes
+func (s *Server) OpenAPI() *openapi.Document {
+	return s.spec.Document()
 }
 
 // Shutdown gracefully shuts down the HTTP server
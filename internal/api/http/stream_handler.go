@@ -0,0 +1,237 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/travisbale/go-template/internal/api/http/openapi"
+	"github.com/travisbale/go-template/internal/pubsub"
+	"github.com/travisbale/go-template/sdk"
+	"github.com/travisbale/heimdall/tenant"
+)
+
+const (
+	// streamHeartbeatInterval keeps idle streams from being closed by proxies
+	// and detects clients that have gone away
+	streamHeartbeatInterval = 15 * time.Second
+	// streamWriteTimeout bounds each write so that a client that stops
+	// reading is disconnected instead of blocking its handler
+	streamWriteTimeout = 10 * time.Second
+)
+
+// streamTopicsParameter documents the topics query parameter of the stream endpoints
+var streamTopicsParameter = &openapi.Parameter{
+	Name:        "topic",
+	In:          "query",
+	Description: "Topic to subscribe to, repeated for each topic",
+	Required:    true,
+	Schema:      &openapi.Schema{Type: "string"},
+}
+
+// HandleEvents streams the messages published to the requested topics as
+// server-sent events. The stream ends when the client disconnects, falls
+// behind, messages were lost or the server shuts down; EventSource clients
+// then reconnect.
+func HandleEvents(hub *pubsub.Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sub, ok := subscribeStream(w, r, hub)
+		if !ok {
+			return
+		}
+		defer sub.Close()
+
+		controller := http.NewResponseController(w)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		if err := controller.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(streamHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			var event []byte
+			select {
+			case <-r.Context().Done():
+				return
+			case <-heartbeat.C:
+				event = []byte(": heartbeat\n\n")
+			case msg, ok := <-sub.Messages():
+				if !ok {
+					return
+				}
+				var data bytes.Buffer
+				if err := json.Compact(&data, msg.Data); err != nil {
+					data.Reset()
+					data.WriteString("null")
+				}
+				event = fmt.Appendf(nil, "event: %s\ndata: %s\n\n", msg.Topic, data.Bytes())
+			}
+
+			if err := controller.SetWriteDeadline(time.Now().Add(streamWriteTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return
+			}
+			if _, err := w.Write(event); err != nil {
+				return
+			}
+			if err := controller.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// HandleWebSocket streams the messages published to the requested topics
+// over a WebSocket as JSON sdk.StreamMessage frames. Messages sent by the
+// client are ignored. The connection is closed with StatusTryAgainLater when
+// the client falls behind or messages were lost, and StatusGoingAway when the
+// server shuts down.
+func HandleWebSocket(hub *pubsub.Hub, allowedOrigins []string) http.HandlerFunc {
+	originPatterns := websocketOriginPatterns(allowedOrigins)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		sub, ok := subscribeStream(w, r, hub)
+		if !ok {
+			return
+		}
+		defer sub.Close()
+
+		conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{OriginPatterns: originPatterns})
+		if err != nil {
+			// Accept has already written the error response
+			return
+		}
+		defer conn.CloseNow() //nolint:errcheck
+
+		// Reading in the background answers pings and notices the client closing
+		ctx := conn.CloseRead(r.Context())
+
+		heartbeat := time.NewTicker(streamHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-heartbeat.C:
+				pingCtx, cancel := context.WithTimeout(ctx, streamWriteTimeout)
+				err := conn.Ping(pingCtx)
+				cancel()
+				if err != nil {
+					return
+				}
+			case msg, ok := <-sub.Messages():
+				if !ok {
+					switch err := sub.Err(); {
+					case errors.Is(err, pubsub.ErrSlowConsumer):
+						conn.Close(websocket.StatusTryAgainLater, "client fell behind") //nolint:errcheck
+					case errors.Is(err, pubsub.ErrMessagesLost):
+						conn.Close(websocket.StatusTryAgainLater, "messages were lost") //nolint:errcheck
+					default:
+						conn.Close(websocket.StatusGoingAway, "server shutting down") //nolint:errcheck
+					}
+					return
+				}
+
+				writeCtx, cancel := context.WithTimeout(ctx, streamWriteTimeout)
+				err := wsjson.Write(writeCtx, conn, sdk.StreamMessage{Topic: msg.Topic, Data: msg.Data})
+				cancel()
+				if err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// subscribeStream subscribes the caller's tenant to the topics in the query.
+// On failure it writes a problem response and returns false.
+func subscribeStream(w http.ResponseWriter, r *http.Request, hub *pubsub.Hub) (*pubsub.Subscription, bool) {
+	topics := r.URL.Query()["topic"]
+	if len(topics) == 0 {
+		respondProblem(w, sdk.Problem{
+			Status:        http.StatusBadRequest,
+			Detail:        "at least one topic is required",
+			InvalidParams: []sdk.InvalidParam{{Name: "topic", Reason: "is required"}},
+		})
+		return nil, false
+	}
+	for _, topic := range topics {
+		if topic == "" || strings.ContainsAny(topic, " \r\n") {
+			respondProblem(w, sdk.Problem{
+				Status:        http.StatusBadRequest,
+				Detail:        "request has invalid parameters",
+				InvalidParams: []sdk.InvalidParam{{Name: "topic", Reason: "must be non-empty and contain no whitespace"}},
+			})
+			return nil, false
+		}
+	}
+
+	tenantID, err := tenant.FromContext(r.Context())
+	if err != nil {
+		respondProblem(w, sdk.Problem{Status: http.StatusUnauthorized})
+		return nil, false
+	}
+
+	sub, err := hub.Subscribe(tenantID, topics...)
+	if err != nil {
+		respondProblem(w, sdk.Problem{Status: http.StatusServiceUnavailable, Detail: "server is shutting down"})
+		return nil, false
+	}
+	return sub, true
+}
+
+// StreamTokenMiddleware lets EventSource and WebSocket clients, which cannot
+// set headers in browsers, pass their bearer token in the access_token query
+// parameter. It applies only to stream requests and removes the token from
+// the URL so that it is not written to access logs, so it must run before
+// the request logger.
+func StreamTokenMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		isStream := strings.Contains(r.Header.Get("Accept"), "text/event-stream") ||
+			strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+
+		query := r.URL.Query()
+		token := query.Get("access_token")
+		if !isStream || token == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		query.Del("access_token")
+		r = r.Clone(r.Context())
+		r.URL.RawQuery = query.Encode()
+		r.RequestURI = r.URL.RequestURI()
+		if r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// websocketOriginPatterns converts CORS origins to the host patterns used by
+// the WebSocket origin check. Same-origin requests are always accepted.
+func websocketOriginPatterns(allowedOrigins []string) []string {
+	var patterns []string
+	for _, origin := range allowedOrigins {
+		if origin == "*" {
+			return []string{"*"}
+		}
+		if parsed, err := url.Parse(origin); err == nil && parsed.Host != "" {
+			patterns = append(patterns, parsed.Host)
+		}
+	}
+	return patterns
+}
@@ -0,0 +1,169 @@
+package pagination
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FieldType determines how filter values and cursor positions are parsed.
+// Filter.Value and the values read with After have the Go type noted below.
+type FieldType int
+
+const (
+	String FieldType = iota // string
+	Int                     // int64
+	Bool                    // bool
+	Time                    // time.Time
+	UUID                    // uuid.UUID
+)
+
+// Operator is a comparison operator in the filter grammar
+type Operator string
+
+const (
+	Equal          Operator = "="
+	NotEqual       Operator = "!="
+	Less           Operator = "<"
+	LessOrEqual    Operator = "<="
+	Greater        Operator = ">"
+	GreaterOrEqual Operator = ">="
+)
+
+// operators is ordered so that two-character operators are matched first
+var operators = []Operator{NotEqual, LessOrEqual, GreaterOrEqual, Equal, Less, Greater}
+
+// Filter is a single `field op value` comparison
+type Filter struct {
+	Field string
+	Op    Operator
+	Value any
+}
+
+// String returns the filter value as a string
+func (f Filter) String() string {
+	return formatValue(f.Value)
+}
+
+// parseFilter parses a filter expression of the form
+//
+//	status = "active" AND created_at >= 2024-01-01T00:00:00Z
+//
+// Expressions are joined with AND; values may be double-quoted
+func (s *Spec) parseFilter(raw string) ([]Filter, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	var filters []Filter
+	for _, expr := range splitAnd(raw) {
+		filter, err := s.parseExpression(expr)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+
+	return filters, nil
+}
+
+func (s *Spec) parseExpression(expr string) (Filter, error) {
+	expr = strings.TrimSpace(expr)
+
+	index, op := -1, Operator("")
+	for _, candidate := range operators {
+		if i := strings.Index(expr, string(candidate)); i > 0 && (index < 0 || i < index) {
+			index, op = i, candidate
+		}
+	}
+	if index < 0 {
+		return Filter{}, invalid(ParamFilter, "expression %q has no operator", expr)
+	}
+
+	name := strings.TrimSpace(expr[:index])
+	field, ok := s.Fields[name]
+	if !ok || !field.Filterable {
+		return Filter{}, invalid(ParamFilter, "cannot filter on %q", name)
+	}
+
+	raw := strings.TrimSpace(expr[index+len(op):])
+	if unquoted, err := strconv.Unquote(raw); err == nil {
+		raw = unquoted
+	}
+	if raw == "" {
+		return Filter{}, invalid(ParamFilter, "expression %q has no value", expr)
+	}
+
+	if op != Equal && op != NotEqual && (field.Type == Bool || field.Type == UUID) {
+		return Filter{}, invalid(ParamFilter, "operator %s is not supported for %q", op, name)
+	}
+
+	value, err := parseValue(field.Type, raw)
+	if err != nil {
+		return Filter{}, invalid(ParamFilter, "invalid value for %q: %v", name, err)
+	}
+
+	return Filter{Field: name, Op: op, Value: value}, nil
+}
+
+// splitAnd splits on the AND keyword outside of quoted strings
+func splitAnd(raw string) []string {
+	var parts []string
+	var current strings.Builder
+	inQuotes := false
+
+	words := strings.SplitAfter(raw, " ")
+	for _, word := range words {
+		if strings.Count(word, `"`)%2 == 1 {
+			inQuotes = !inQuotes
+		}
+		if !inQuotes && strings.TrimSpace(word) == "AND" {
+			parts = append(parts, current.String())
+			current.Reset()
+			continue
+		}
+		current.WriteString(word)
+	}
+
+	return append(parts, current.String())
+}
+
+func parseValue(fieldType FieldType, raw string) (any, error) {
+	switch fieldType {
+	case Int:
+		return strconv.ParseInt(raw, 10, 64)
+	case Bool:
+		return strconv.ParseBool(raw)
+	case Time:
+		return time.Parse(time.RFC3339Nano, raw)
+	case UUID:
+		return uuid.Parse(raw)
+	default:
+		return raw, nil
+	}
+}
+
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case int:
+		return strconv.Itoa(v)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	case uuid.UUID:
+		return v.String()
+	case interface{ String() string }:
+		return v.String()
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,98 @@
+package pagination
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestParseFilter(t *testing.T) {
+	id := uuid.MustParse("6b5f0a6e-4d84-4bd4-9f1b-0c3b5a0b3f4e")
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		raw  string
+		want []Filter
+	}{
+		{"empty", "  ", nil},
+		{"string", `name = widget`, []Filter{{"name", Equal, "widget"}}},
+		{"quoted string", `name = "a AND b"`, []Filter{{"name", Equal, "a AND b"}}},
+		{"int", "size >= 10", []Filter{{"size", GreaterOrEqual, int64(10)}}},
+		{"bool", "active != false", []Filter{{"active", NotEqual, false}}},
+		{"uuid", "id = " + id.String(), []Filter{{"id", Equal, id}}},
+		{"time", "created_at < 2024-01-02T03:04:05Z", []Filter{{"created_at", Less, created}}},
+		{"no spaces", "size<=3", []Filter{{"size", LessOrEqual, int64(3)}}},
+		{"operator in value", `name = "a>b"`, []Filter{{"name", Equal, "a>b"}}},
+		{
+			"conjunction",
+			`name = "x" AND size > 1 AND created_at >= 2024-01-02T03:04:05Z`,
+			[]Filter{{"name", Equal, "x"}, {"size", Greater, int64(1)}, {"created_at", GreaterOrEqual, created}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := testSpec.parseFilter(tt.raw)
+			if err != nil {
+				t.Fatalf("parseFilter(%q) failed: %v", tt.raw, err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("parseFilter(%q) = %v, want %v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		raw    string
+		reason string
+	}{
+		{"name", `expression "name" has no operator`},
+		{"= widget", `expression "= widget" has no operator`},
+		{"name =", `expression "name =" has no value`},
+		{`name = ""`, `expression "name = \"\"" has no value`},
+		{"secret = x", `cannot filter on "secret"`},
+		{"unknown = x", `cannot filter on "unknown"`},
+		{"active > true", `operator > is not supported for "active"`},
+		{"id < 6b5f0a6e-4d84-4bd4-9f1b-0c3b5a0b3f4e", `operator < is not supported for "id"`},
+		{"size = ten", `invalid value for "size"`},
+		{"active = maybe", `invalid value for "active"`},
+		{"created_at > yesterday", `invalid value for "created_at"`},
+		{"name = x AND", `expression "" has no operator`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			_, err := testSpec.parseFilter(tt.raw)
+			var paramErr *Error
+			if !errors.As(err, &paramErr) || paramErr.Param != ParamFilter || !strings.HasPrefix(paramErr.Reason, tt.reason) {
+				t.Errorf("parseFilter(%q) error = %v, want %q", tt.raw, err, tt.reason)
+			}
+		})
+	}
+}
+
+func TestSplitAnd(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{"a = 1", []string{"a = 1"}},
+		{"a = 1 AND b = 2", []string{"a = 1 ", "b = 2"}},
+		{`a = "x AND y" AND b = 2`, []string{`a = "x AND y" `, "b = 2"}},
+		{"a = 1 and b = 2", []string{"a = 1 and b = 2"}},
+		{"a = BAND", []string{"a = BAND"}},
+	}
+
+	for _, tt := range tests {
+		if got := splitAnd(tt.raw); !slices.Equal(got, tt.want) {
+			t.Errorf("splitAnd(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+// Package pagination implements the list conventions shared by every /v1
+// endpoint: page_size, an opaque signed page_token, order_by and filter.
+//
+// A validated Query maps onto a keyset sqlc query of the form
+//
+//	-- name: ListWidgetsByCreatedAt :many
+//	SELECT * FROM widgets
+//	WHERE sqlc.narg('after_created_at')::timestamptz IS NULL
+//	   OR (created_at, id) < (sqlc.narg('after_created_at'), sqlc.narg('after_id')::uuid)
+//	ORDER BY created_at DESC, id DESC
+//	LIMIT sqlc.arg('limit');
+//
+// using After[time.Time](query, "created_at"), After[uuid.UUID](query, "id")
+// and query.Limit() as the parameters.
+package pagination
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultPageSize is used when a spec does not set its own default
+	DefaultPageSize = 50
+	// MaxPageSize is used when a spec does not set its own maximum
+	MaxPageSize = 1000
+)
+
+// Query parameter names shared by every list endpoint
+const (
+	ParamPageSize  = "page_size"
+	ParamPageToken = "page_token"
+	ParamOrderBy   = "order_by"
+	ParamFilter    = "filter"
+)
+
+// Error describes an invalid list parameter
+type Error struct {
+	Param  string
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Param, e.Reason)
+}
+
+func invalid(param, format string, args ...any) *Error {
+	return &Error{Param: param, Reason: fmt.Sprintf(format, args...)}
+}
+
+// Params holds the raw list parameters as received from a client.
+// The fields mirror AIP-158 (page_size, page_token) plus AIP-132 order_by and filter.
+type Params struct {
+	PageSize  int32
+	PageToken string
+	OrderBy   string
+	Filter    string
+}
+
+// FromQuery extracts list parameters from URL query values
+func FromQuery(values url.Values) (Params, error) {
+	params := Params{
+		PageToken: values.Get(ParamPageToken),
+		OrderBy:   values.Get(ParamOrderBy),
+		Filter:    values.Get(ParamFilter),
+	}
+
+	if raw := values.Get(ParamPageSize); raw != "" {
+		size, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil {
+			return Params{}, invalid(ParamPageSize, "must be an integer")
+		}
+		params.PageSize = int32(size)
+	}
+
+	return params, nil
+}
+
+// Order is a single sort key
+type Order struct {
+	Field string
+	Desc  bool
+}
+
+// String formats the order in order_by syntax
+func (o Order) String() string {
+	if o.Desc {
+		return o.Field + " desc"
+	}
+	return o.Field
+}
+
+// Query is a validated list request ready to be turned into keyset query parameters
+type Query struct {
+	// PageSize is the number of items to return
+	PageSize int32
+	// OrderBy always ends with the spec's tie breaker so that the sort is total
+	OrderBy []Order
+	// Filters are the parsed filter expressions, all of which must match
+	Filters []Filter
+	// After is the position of the last item on the previous page, or nil for the first page
+	After Cursor
+
+	spec        *Spec
+	fingerprint string
+}
+
+// Limit returns the LIMIT to pass to the query. One extra row is fetched
+// so that the presence of a next page can be detected without a COUNT.
+func (q *Query) Limit() int32 {
+	return q.PageSize + 1
+}
+
+// Desc reports whether the primary sort key is descending, which determines
+// whether the keyset comparison should use < or >
+func (q *Query) Desc() bool {
+	return len(q.OrderBy) > 0 && q.OrderBy[0].Desc
+}
+
+// OrderKey returns the order_by expression in canonical form, suitable for
+// selecting between per-ordering sqlc queries
+func (q *Query) OrderKey() string {
+	parts := make([]string, len(q.OrderBy))
+	for i, order := range q.OrderBy {
+		parts[i] = order.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Filter returns the first filter on the given field and operator
+func (q *Query) Filter(field string, op Operator) (Filter, bool) {
+	for _, filter := range q.Filters {
+		if filter.Field == field && filter.Op == op {
+			return filter, true
+		}
+	}
+	return Filter{}, false
+}
+
+// Spec is the per-endpoint allowlist of fields that can be sorted and filtered on
+type Spec struct {
+	// Fields maps API field names to their type and capabilities
+	Fields map[string]Field
+	// DefaultOrder is used when the client does not send order_by
+	DefaultOrder []Order
+	// TieBreaker is a unique field appended to every ordering, usually "id"
+	TieBreaker string
+	// DefaultPageSize and MaxPageSize fall back to the package defaults when zero
+	DefaultPageSize int32
+	MaxPageSize     int32
+}
+
+// Field describes a single field that a list endpoint exposes
+type Field struct {
+	Type       FieldType
+	Sortable   bool
+	Filterable bool
+}
+
+func (s *Spec) pageSize(requested int32) (int32, error) {
+	defaultSize := s.DefaultPageSize
+	if defaultSize == 0 {
+		defaultSize = DefaultPageSize
+	}
+	maxSize := s.MaxPageSize
+	if maxSize == 0 {
+		maxSize = MaxPageSize
+	}
+
+	switch {
+	case requested < 0:
+		return 0, invalid(ParamPageSize, "must not be negative")
+	case requested == 0:
+		return defaultSize, nil
+	case requested > maxSize:
+		// AIP-158: values above the maximum are coerced rather than rejected
+		return maxSize, nil
+	default:
+		return requested, nil
+	}
+}
+
+func (s *Spec) parseOrderBy(raw string) ([]Order, error) {
+	var orders []Order
+	if strings.TrimSpace(raw) == "" {
+		orders = append(orders, s.DefaultOrder...)
+	} else {
+		seen := make(map[string]bool)
+		for _, part := range strings.Split(raw, ",") {
+			words := strings.Fields(part)
+			if len(words) == 0 || len(words) > 2 {
+				return nil, invalid(ParamOrderBy, "malformed expression %q", strings.TrimSpace(part))
+			}
+
+			order := Order{Field: words[0]}
+			if len(words) == 2 {
+				switch strings.ToLower(words[1]) {
+				case "asc":
+				case "desc":
+					order.Desc = true
+				default:
+					return nil, invalid(ParamOrderBy, "unknown direction %q", words[1])
+				}
+			}
+
+			field, ok := s.Fields[order.Field]
+			if !ok || !field.Sortable {
+				return nil, invalid(ParamOrderBy, "cannot sort by %q", order.Field)
+			}
+			if seen[order.Field] {
+				return nil, invalid(ParamOrderBy, "duplicate field %q", order.Field)
+			}
+			seen[order.Field] = true
+
+			orders = append(orders, order)
+		}
+
+		// Keyset pagination compares row tuples, which requires a single direction
+		for _, order := range orders[1:] {
+			if order.Desc != orders[0].Desc {
+				return nil, invalid(ParamOrderBy, "all fields must be sorted in the same direction")
+			}
+		}
+	}
+
+	// Append the tie breaker so that pages never overlap or skip rows
+	if s.TieBreaker != "" {
+		for _, order := range orders {
+			if order.Field == s.TieBreaker {
+				return orders, nil
+			}
+		}
+		desc := len(orders) > 0 && orders[0].Desc
+		orders = append(orders, Order{Field: s.TieBreaker, Desc: desc})
+	}
+
+	return orders, nil
+}
@@ -0,0 +1,120 @@
+package pagination
+
+import (
+	"errors"
+	"net/url"
+	"slices"
+	"testing"
+)
+
+var testSpec = &Spec{
+	Fields: map[string]Field{
+		"id":         {Type: UUID, Sortable: true, Filterable: true},
+		"name":       {Type: String, Sortable: true, Filterable: true},
+		"size":       {Type: Int, Sortable: true, Filterable: true},
+		"active":     {Type: Bool, Filterable: true},
+		"created_at": {Type: Time, Sortable: true, Filterable: true},
+		"secret":     {Type: String},
+	},
+	DefaultOrder:    []Order{{Field: "created_at", Desc: true}},
+	TieBreaker:      "id",
+	DefaultPageSize: 20,
+	MaxPageSize:     100,
+}
+
+func TestPageSize(t *testing.T) {
+	tests := []struct {
+		name      string
+		spec      *Spec
+		requested int32
+		want      int32
+		wantErr   bool
+	}{
+		{"default", testSpec, 0, 20, false},
+		{"within range", testSpec, 50, 50, false},
+		{"maximum", testSpec, 100, 100, false},
+		{"above maximum is coerced", testSpec, 5000, 100, false},
+		{"negative", testSpec, -1, 0, true},
+		{"package default", &Spec{}, 0, DefaultPageSize, false},
+		{"package maximum", &Spec{}, MaxPageSize + 1, MaxPageSize, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.spec.pageSize(tt.requested)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("pageSize(%d) error = %v, want error %t", tt.requested, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("pageSize(%d) = %d, want %d", tt.requested, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromQuery(t *testing.T) {
+	params, err := FromQuery(url.Values{"page_size": {"10"}, "page_token": {"t"}, "order_by": {"name"}, "filter": {"size > 1"}})
+	if err != nil {
+		t.Fatalf("FromQuery failed: %v", err)
+	}
+	want := Params{PageSize: 10, PageToken: "t", OrderBy: "name", Filter: "size > 1"}
+	if params != want {
+		t.Errorf("FromQuery = %+v, want %+v", params, want)
+	}
+
+	for _, raw := range []string{"ten", "1.5", "99999999999"} {
+		_, err := FromQuery(url.Values{"page_size": {raw}})
+		var paramErr *Error
+		if !errors.As(err, &paramErr) || paramErr.Param != ParamPageSize {
+			t.Errorf("FromQuery(page_size=%s) error = %v, want invalid page_size", raw, err)
+		}
+	}
+}
+
+func TestParseOrderBy(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    []Order
+		wantErr string
+	}{
+		{"", []Order{{"created_at", true}, {"id", true}}, ""},
+		{"name", []Order{{"name", false}, {"id", false}}, ""},
+		{"name DESC, size desc", []Order{{"name", true}, {"size", true}, {"id", true}}, ""},
+		{"id asc", []Order{{"id", false}}, ""},
+		{"name sideways", nil, `unknown direction "sideways"`},
+		{"name desc extra", nil, `malformed expression "name desc extra"`},
+		{"name,", nil, `malformed expression ""`},
+		{"secret", nil, `cannot sort by "secret"`},
+		{"unknown", nil, `cannot sort by "unknown"`},
+		{"name, name", nil, `duplicate field "name"`},
+		{"name, size desc", nil, "all fields must be sorted in the same direction"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := testSpec.parseOrderBy(tt.raw)
+			if tt.wantErr != "" {
+				assertInvalid(t, err, ParamOrderBy, tt.wantErr)
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseOrderBy(%q) failed: %v", tt.raw, err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("parseOrderBy(%q) = %v, want %v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+// assertInvalid checks that err is an *Error for param with the given reason
+func assertInvalid(t *testing.T, err error, param, reason string) {
+	t.Helper()
+	var paramErr *Error
+	if !errors.As(err, &paramErr) {
+		t.Fatalf("error = %v, want *Error", err)
+	}
+	if paramErr.Param != param || paramErr.Reason != reason {
+		t.Errorf("error = %s: %s, want %s: %s", paramErr.Param, paramErr.Reason, param, reason)
+	}
+}
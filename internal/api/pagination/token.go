@@ -0,0 +1,198 @@
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Cursor maps order_by field names to the values of the last item on a page
+type Cursor map[string]any
+
+// token is the payload of an encoded page token
+type token struct {
+	Fingerprint string            `json:"f"`
+	Values      map[string]string `json:"v"`
+}
+
+// Codec parses list parameters and signs page tokens so that clients cannot
+// forge cursors or reuse a token with a different order_by or filter
+type Codec struct {
+	key []byte
+}
+
+// NewCodec creates a codec that signs page tokens with the given secret.
+// If the secret is empty a random key is generated, which means tokens
+// will not survive a restart or be accepted by other replicas.
+func NewCodec(secret []byte) *Codec {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(fmt.Sprintf("failed to generate page token key: %v", err))
+		}
+	}
+
+	return &Codec{key: secret}
+}
+
+// Parse validates list parameters against the spec and decodes the page token
+func (c *Codec) Parse(spec *Spec, params Params) (*Query, error) {
+	pageSize, err := spec.pageSize(params.PageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	orderBy, err := spec.parseOrderBy(params.OrderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	filters, err := spec.parseFilter(params.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	query := &Query{
+		PageSize: pageSize,
+		OrderBy:  orderBy,
+		Filters:  filters,
+		spec:     spec,
+	}
+	query.fingerprint = query.computeFingerprint()
+
+	if params.PageToken != "" {
+		if query.After, err = c.decode(query, params.PageToken); err != nil {
+			return nil, err
+		}
+	}
+
+	return query, nil
+}
+
+// NextPageToken encodes the position of the last item on the current page
+func (c *Codec) NextPageToken(query *Query, last Cursor) (string, error) {
+	payload := token{
+		Fingerprint: query.fingerprint,
+		Values:      make(map[string]string, len(query.OrderBy)),
+	}
+	for _, order := range query.OrderBy {
+		value, ok := last[order.Field]
+		if !ok {
+			return "", fmt.Errorf("cursor is missing order field %q", order.Field)
+		}
+		payload.Values[order.Field] = formatValue(value)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode page token: %w", err)
+	}
+
+	encoding := base64.RawURLEncoding
+	return encoding.EncodeToString(data) + "." + encoding.EncodeToString(c.sign(data)), nil
+}
+
+// Page trims a result set fetched with query.Limit() to the page size and
+// returns the token for the next page, which is empty on the last page
+func Page[T any](c *Codec, query *Query, items []T, cursor func(T) Cursor) ([]T, string, error) {
+	if len(items) <= int(query.PageSize) {
+		return items, "", nil
+	}
+
+	items = items[:query.PageSize]
+	next, err := c.NextPageToken(query, cursor(items[len(items)-1]))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return items, next, nil
+}
+
+// After returns the typed cursor value for a field, or nil on the first page
+// or when the query is not ordered by the field. The pointer form maps
+// directly onto nullable sqlc parameters. T must be the Go type the field's
+// FieldType decodes to; any other type panics, since a nil cursor would
+// restart the listing at the first page on every request.
+func After[T any](query *Query, field string) *T {
+	if query.After == nil {
+		return nil
+	}
+	raw, ok := query.After[field]
+	if !ok {
+		return nil
+	}
+	value, ok := raw.(T)
+	if !ok {
+		panic(fmt.Sprintf("pagination: cursor field %q is %T, not %T", field, raw, value))
+	}
+	return &value
+}
+
+func (c *Codec) decode(query *Query, raw string) (Cursor, error) {
+	encoding := base64.RawURLEncoding
+
+	encodedData, encodedSig, ok := strings.Cut(raw, ".")
+	if !ok {
+		return nil, invalid(ParamPageToken, "malformed token")
+	}
+	data, err := encoding.DecodeString(encodedData)
+	if err != nil {
+		return nil, invalid(ParamPageToken, "malformed token")
+	}
+	signature, err := encoding.DecodeString(encodedSig)
+	if err != nil || !hmac.Equal(signature, c.sign(data)) {
+		return nil, invalid(ParamPageToken, "signature mismatch")
+	}
+
+	var payload token
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, invalid(ParamPageToken, "malformed token")
+	}
+	if payload.Fingerprint != query.fingerprint {
+		return nil, invalid(ParamPageToken, "token does not match order_by and filter")
+	}
+
+	cursor := make(Cursor, len(query.OrderBy))
+	for _, order := range query.OrderBy {
+		raw, ok := payload.Values[order.Field]
+		if !ok {
+			return nil, invalid(ParamPageToken, "token is missing field %q", order.Field)
+		}
+
+		fieldType := String
+		if field, ok := query.spec.Fields[order.Field]; ok {
+			fieldType = field.Type
+		}
+
+		value, err := parseValue(fieldType, raw)
+		if err != nil {
+			return nil, invalid(ParamPageToken, "invalid value for %q", order.Field)
+		}
+		cursor[order.Field] = value
+	}
+
+	return cursor, nil
+}
+
+func (c *Codec) sign(data []byte) []byte {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// computeFingerprint identifies the ordering and filtering a token was issued for
+func (q *Query) computeFingerprint() string {
+	var b strings.Builder
+	b.WriteString(q.OrderKey())
+	for _, filter := range q.Filters {
+		fmt.Fprintf(&b, "|%s%s%s", filter.Field, filter.Op, filter.String())
+	}
+
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:8])
+}
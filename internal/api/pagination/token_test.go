@@ -0,0 +1,135 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPageTokenRoundTrip(t *testing.T) {
+	codec := NewCodec([]byte("secret"))
+	params := Params{OrderBy: "size desc", Filter: "name = x"}
+
+	first, err := codec.Parse(testSpec, params)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if first.After != nil || After[int64](first, "size") != nil {
+		t.Fatal("first page has a cursor")
+	}
+
+	id := uuid.New()
+	token, err := codec.NextPageToken(first, Cursor{"size": int64(42), "id": id})
+	if err != nil {
+		t.Fatalf("NextPageToken failed: %v", err)
+	}
+
+	params.PageToken = token
+	next, err := codec.Parse(testSpec, params)
+	if err != nil {
+		t.Fatalf("Parse with token failed: %v", err)
+	}
+	if size := After[int64](next, "size"); size == nil || *size != 42 {
+		t.Errorf("size cursor = %v, want 42", size)
+	}
+	if after := After[uuid.UUID](next, "id"); after == nil || *after != id {
+		t.Errorf("id cursor = %v, want %s", after, id)
+	}
+	if after := After[time.Time](next, "created_at"); after != nil {
+		t.Errorf("created_at cursor = %v, want nil for a field outside the ordering", after)
+	}
+}
+
+func TestPageTokenRejected(t *testing.T) {
+	codec := NewCodec([]byte("secret"))
+	params := Params{OrderBy: "name", Filter: "size > 1"}
+	query, err := codec.Parse(testSpec, params)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	token, err := codec.NextPageToken(query, Cursor{"name": "widget", "id": uuid.New()})
+	if err != nil {
+		t.Fatalf("NextPageToken failed: %v", err)
+	}
+
+	data, signature, _ := strings.Cut(token, ".")
+	payload, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil {
+		t.Fatalf("failed to decode token: %v", err)
+	}
+	tampered := strings.Replace(string(payload), "widget", "gadget", 1)
+
+	tests := []struct {
+		name   string
+		codec  *Codec
+		params Params
+		token  string
+		reason string
+	}{
+		{"no separator", codec, params, data, "malformed token"},
+		{"bad encoding", codec, params, "!!!." + signature, "malformed token"},
+		{"tampered payload", codec, params, base64.RawURLEncoding.EncodeToString([]byte(tampered)) + "." + signature, "signature mismatch"},
+		{"truncated signature", codec, params, token[:len(token)-2], "signature mismatch"},
+		{"other key", NewCodec([]byte("other")), params, token, "signature mismatch"},
+		{"different order_by", codec, Params{OrderBy: "name desc", Filter: params.Filter}, token, "token does not match order_by and filter"},
+		{"different filter", codec, Params{OrderBy: params.OrderBy, Filter: "size > 2"}, token, "token does not match order_by and filter"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.params.PageToken = tt.token
+			_, err := tt.codec.Parse(testSpec, tt.params)
+			assertInvalid(t, err, ParamPageToken, tt.reason)
+		})
+	}
+}
+
+func TestNextPageTokenRequiresOrderFields(t *testing.T) {
+	codec := NewCodec(nil)
+	query, err := codec.Parse(testSpec, Params{})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if _, err := codec.NextPageToken(query, Cursor{"id": uuid.New()}); err == nil {
+		t.Error("NextPageToken accepted a cursor without created_at")
+	}
+}
+
+func TestPage(t *testing.T) {
+	codec := NewCodec([]byte("secret"))
+	query, err := codec.Parse(testSpec, Params{PageSize: 2, OrderBy: "size"})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	cursor := func(size int64) Cursor { return Cursor{"size": size, "id": uuid.Nil} }
+
+	items, next, err := Page(codec, query, []int64{1, 2}, cursor)
+	if err != nil || len(items) != 2 || next != "" {
+		t.Errorf("last page = %v, %q, %v, want 2 items and no token", items, next, err)
+	}
+
+	items, next, err = Page(codec, query, []int64{1, 2, 3}, cursor)
+	if err != nil || len(items) != 2 || next == "" {
+		t.Fatalf("full page = %v, %q, %v, want 2 items and a token", items, next, err)
+	}
+	following, err := codec.Parse(testSpec, Params{PageSize: 2, OrderBy: "size", PageToken: next})
+	if err != nil {
+		t.Fatalf("Parse with token failed: %v", err)
+	}
+	if size := After[int64](following, "size"); size == nil || *size != 2 {
+		t.Errorf("size cursor = %v, want the last item on the page", size)
+	}
+}
+
+func TestAfterPanicsOnTypeMismatch(t *testing.T) {
+	query := &Query{After: Cursor{"size": int64(1)}}
+	defer func() {
+		if recover() == nil {
+			t.Error("After[int] on an int64 cursor did not panic")
+		}
+	}()
+	After[int](query, "size")
+}
@@ -0,0 +1,35 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/travisbale/go-template/internal/db/postgres"
+)
+
+// openDB connects to the database and brings the schema up to date, or waits
+// for whichever instance or job migrates it
+func openDB(ctx context.Context, config *Config) (*postgres.DB, error) {
+	db, err := postgres.NewDB(ctx, config.DatabaseURL, config.Logger,
+		postgres.WithConnectTimeout(config.DatabaseConnectTimeout),
+		postgres.WithPoolConfig(config.DatabasePool),
+		postgres.WithReplicas(config.DatabaseReplicaURLs, config.DatabaseReplicaMaxLag),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	if config.MigrateOnStart {
+		if err := db.MigrateWithLock(ctx, config.DatabaseURL); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to run database migrations: %w", err)
+		}
+	} else {
+		if err := db.WaitForSchema(ctx, config.MigrationWaitTimeout); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to verify database schema: %w", err)
+		}
+	}
+
+	return db, nil
+}
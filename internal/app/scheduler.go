@@ -0,0 +1,31 @@
+package app
+
+import (
+	"github.com/travisbale/go-template/internal/db/postgres"
+	"github.com/travisbale/go-template/internal/scheduler"
+)
+
+// registerScheduledTasks adds the application's periodic tasks. Only the
+// replica holding the scheduler lease runs them, once per occurrence.
+//
+//	err := s.Add(scheduler.Task{
+//		Name:     "cleanup_expired_sessions",
+//		Schedule: "0 3 * * *",
+//		CatchUp:  scheduler.CatchUpOnce,
+//		Run: func(ctx context.Context) error {
+//			return sessions.DeleteExpired(ctx)
+//		},
+//	})
+func registerScheduledTasks(s *scheduler.Scheduler, db *postgres.DB) error {
+	// Register scheduled tasks here
+	return nil
+}
+
+// newScheduler creates a scheduler for the application's periodic tasks
+func newScheduler(db *postgres.DB, config *Config) (*scheduler.Scheduler, error) {
+	s := scheduler.NewScheduler(db, config.Logger, config.Scheduler)
+	if err := registerScheduledTasks(s, db); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -2,12 +2,22 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
+	"sync"
+	"time"
 
 	"github.com/travisbale/go-template/internal/api/grpc"
 	"github.com/travisbale/go-template/internal/api/http"
+	"github.com/travisbale/go-template/internal/api/pagination"
+	"github.com/travisbale/go-template/internal/audit"
 	"github.com/travisbale/go-template/internal/db/postgres"
+	"github.com/travisbale/go-template/internal/jobs"
+	"github.com/travisbale/go-template/internal/outbox"
+	"github.com/travisbale/go-template/internal/pubsub"
+	"github.com/travisbale/go-template/internal/scheduler"
 	"github.com/travisbale/heimdall/jwt"
 )
 
@@ -18,88 +28,300 @@ type logger interface {
 
 // Config holds the configuration for creating a new server
 type Config struct {
-	HTTPAddress      string
-	GRPCAddress      string
-	DatabaseURL      string
-	JWTPublicKeyPath string
-	Environment      string
-	Logger           logger
+	HTTPAddress            string
+	GRPCAddress            string
+	DatabaseURL            string
+	DatabaseConnectTimeout time.Duration
+	MigrateOnStart         bool
+	MigrationWaitTimeout   time.Duration
+	DatabaseReplicaURLs    []string
+	DatabaseReplicaMaxLag  time.Duration
+	DatabasePool           postgres.PoolConfig
+	JWTPublicKeyPath       string
+	PageTokenSecret        string
+	CORSOrigins            []string
+	Environment            string
+	Version                string
+	Logger                 logger
+
+	// OutboxPublisher receives events from the outbox relay. Events are
+	// dispatched in-process when it is nil.
+	OutboxPublisher outbox.Publisher
+
+	// RunWorkers runs background jobs in the server process. Disable it when
+	// jobs are processed by `app worker` instead.
+	RunWorkers bool
+	Worker     jobs.Config
+
+	// Scheduler tunes leader election and catch-up for scheduled tasks
+	Scheduler scheduler.Config
+}
+
+// defaultShutdownTimeout bounds the shutdown of the HTTP and gRPC servers
+const defaultShutdownTimeout = 10 * time.Second
+
+// ShutdownTimeout returns how long Shutdown should be allowed to take. With
+// RunWorkers it adds the worker's drain, which starts once the servers have
+// stopped, so that running jobs finish and record their outcomes before the
+// database is closed.
+func (c *Config) ShutdownTimeout() time.Duration {
+	if !c.RunWorkers {
+		return defaultShutdownTimeout
+	}
+	return defaultShutdownTimeout + c.Worker.StopTimeout()
 }
 
+// component is a background process that runs for the lifetime of the server,
+// such as the outbox relay or the scheduler. Run blocks until ctx is cancelled.
+type component interface {
+	Run(ctx context.Context) error
+}
+
+// errShuttingDown means Shutdown was called before the server finished starting
+var errShuttingDown = errors.New("server is shutting down")
+
 // Server wraps the HTTP and gRPC servers and their dependencies
 type Server struct {
-	httpServer *http.Server
+	config       *Config
+	httpServer   *http.Server
+	jwtValidator *jwt.Validator
+	logger       logger
+
+	// The fields below are set by Serve once the database is available,
+	// while Shutdown may be called from another goroutine, so mu guards
+	// them. stop cancels startup and the components, and done is closed once
+	// they have all returned.
+	mu         sync.Mutex
+	closing    bool
 	grpcServer *grpc.Server
 	db         *postgres.DB
+	components []component
+	stop       context.CancelFunc
+	done       chan struct{}
 }
 
-// NewServer creates a new server instance with all dependencies
-func NewServer(ctx context.Context, config *Config) (*Server, error) {
-	// Connect to database
-	db, err := postgres.NewDB(ctx, config.DatabaseURL, config.Logger)
+// NewServer creates a server instance. Its dependencies, starting with the
+// database, are connected by Serve once the HTTP listener is answering health
+// probes, so that a slow database does not fail liveness checks.
+func NewServer(config *Config) (*Server, error) {
+	// Create JWT validator
+	jwtValidator, err := jwt.NewValidator(config.JWTPublicKeyPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+		return nil, fmt.Errorf("failed to create JWT validator: %w", err)
 	}
 
-	// Run database migrations
-	if err := postgres.MigrateUp(config.DatabaseURL); err != nil {
-		db.Close()
-		return nil, fmt.Errorf("failed to run database migrations: %w", err)
+	return &Server{
+		config:       config,
+		httpServer:   http.NewStartupServer(config.HTTPAddress),
+		jwtValidator: jwtValidator,
+		logger:       config.Logger,
+		done:         make(chan struct{}),
+	}, nil
+}
+
+// initialize connects the database and creates everything that depends on
+// it, then installs the API routes
+func (s *Server) initialize(ctx context.Context) error {
+	config := s.config
+
+	// Connect to database and run migrations, or wait for whichever instance or job runs them
+	db, err := openDB(ctx, config)
+	if err != nil {
+		return err
 	}
 
-	// Create JWT validator
-	jwtValidator, err := jwt.NewValidator(config.JWTPublicKeyPath)
+	// Create page token codec shared by HTTP and gRPC list endpoints
+	if config.PageTokenSecret == "" {
+		config.Logger.Info("No page token secret configured, page tokens will not be valid across restarts or replicas")
+	}
+	pageTokens := pagination.NewCodec([]byte(config.PageTokenSecret))
+
+	// Relay events written to the outbox
+	publisher := config.OutboxPublisher
+	if publisher == nil {
+		publisher = outbox.NewInProcessPublisher()
+	}
+	components := []component{outbox.NewRelay(db, publisher, config.Logger, outbox.Config{})}
+
+	// Stream messages sent with pubsub.Notify on any replica to this replica's clients
+	hub := pubsub.NewHub()
+	components = append(components, pubsub.NewBridge(db, hub, config.Logger))
+
+	// Fire periodic tasks on whichever replica holds the scheduler lease
+	tasks, err := newScheduler(db, config)
 	if err != nil {
 		db.Close()
-		return nil, fmt.Errorf("failed to create JWT validator: %w", err)
+		return fmt.Errorf("failed to register scheduled tasks: %w", err)
+	}
+	components = append(components, tasks)
+
+	// Run background jobs alongside the API unless dedicated workers do
+	if config.RunWorkers {
+		components = append(components, newJobWorker(db, config))
 	}
 
 	// Create database adapters
+	auditEntries := postgres.NewAuditRepository(db)
 
 	// Create application services
+	auditLog := audit.NewLog(auditEntries)
+
+	// Create gRPC server
+	grpcServer := grpc.NewServer(&grpc.Config{
+		Address:    config.GRPCAddress,
+		DB:         db,
+		PageTokens: pageTokens,
+		Audit:      auditLog,
+	})
+
+	// Expose annotated gRPC services over REST
+	gateway, err := grpcServer.Gateway(ctx)
+	if err != nil {
+		db.Close()
+		return fmt.Errorf("failed to create gRPC gateway: %w", err)
+	}
 
-	// Create HTTP server
-	httpServer := http.NewServer(&http.Config{
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closing {
+		db.Close()
+		return errShuttingDown
+	}
+	s.db = db
+	s.grpcServer = grpcServer
+	s.components = components
+
+	// Serve the API in place of the startup routes
+	s.httpServer.Install(&http.Config{
 		Address:      config.HTTPAddress,
-		JWTValidator: jwtValidator,
+		JWTValidator: s.jwtValidator,
 		DB:           db,
+		PageTokens:   pageTokens,
+		Audit:        auditLog,
+		Hub:          hub,
+		Gateway:      gateway,
+		GRPCWeb:      grpcServer.WebHandler(http.AuthenticateWeb(s.jwtValidator)),
+		GRPCServices: grpcServer.Services(),
+		CORSOrigins:  config.CORSOrigins,
 		Environment:  config.Environment,
+		Version:      config.Version,
 	})
+	return nil
+}
 
-	// Create gRPC server
-	grpcServer := grpc.NewServer(&grpc.Config{
-		Address: config.GRPCAddress,
-		DB:      db,
-	})
+// Start begins listening for HTTP and gRPC requests on the configured addresses
+func (s *Server) Start() error {
+	grpcListener, err := net.Listen("tcp", s.config.GRPCAddress)
+	if err != nil {
+		return fmt.Errorf("failed to create gRPC listener: %w", err)
+	}
 
-	return &Server{
-		httpServer: httpServer,
-		grpcServer: grpcServer,
-		db:         db,
-	}, nil
+	httpListener, err := net.Listen("tcp", s.config.HTTPAddress)
+	if err != nil {
+		grpcListener.Close() //nolint:errcheck
+		return fmt.Errorf("failed to create HTTP listener: %w", err)
+	}
+
+	return s.Serve(httpListener, grpcListener)
 }
 
-// Start begins listening for HTTP and gRPC requests
-func (s *Server) Start() error {
+// Serve handles HTTP and gRPC requests on listeners opened by the caller,
+// e.g. on random ports in tests. The HTTP listener answers health probes while
+// the database is connected; API routes and gRPC are served once it is. Serve
+// blocks until the HTTP server stops, and returns the startup error if the
+// server could not be initialized.
+func (s *Server) Serve(httpListener, grpcListener net.Listener) error {
+	ctx, stop := context.WithCancel(context.Background())
+	s.mu.Lock()
+	s.stop = stop
+	s.mu.Unlock()
+
+	// Start HTTP server in background, answering probes during startup
+	served := make(chan error, 1)
+	go func() {
+		served <- s.httpServer.Serve(httpListener)
+	}()
+
+	if err := s.initialize(ctx); err != nil {
+		close(s.done)
+		grpcListener.Close() //nolint:errcheck
+
+		// Shutdown was called while starting, so report how the HTTP server stopped
+		if ctx.Err() != nil || errors.Is(err, errShuttingDown) {
+			return <-served
+		}
+		s.httpServer.Close() //nolint:errcheck
+		<-served
+		return err
+	}
+
+	// Start background components
+	go s.runComponents(ctx)
+
 	// Start gRPC server in background
 	go func() {
-		if err := s.grpcServer.ListenAndServe(); err != nil {
+		if err := s.grpcServer.Serve(grpcListener); err != nil {
 			slog.Error("gRPC server error", "error", err)
 		}
 	}()
 
-	// Start HTTP server (blocking)
-	return s.httpServer.ListenAndServe()
+	return <-served
 }
 
 // Shutdown gracefully shuts down the server
 func (s *Server) Shutdown(ctx context.Context) error {
-	// Stop gRPC server
-	s.grpcServer.GracefulStop()
+	// Shutdown HTTP server first. Connect and gRPC-Web calls reach the gRPC
+	// server through its ServeHTTP transport, which panics if GracefulStop
+	// drains it, so they must have finished before the gRPC server stops.
+	err := s.httpServer.Shutdown(ctx)
+
+	// Keep a server that is still starting from bringing anything up
+	s.mu.Lock()
+	s.closing = true
+	grpcServer, db, stop := s.grpcServer, s.db, s.stop
+	s.mu.Unlock()
+
+	// Stop gRPC server, cutting off any calls the HTTP server gave up on
+	if grpcServer != nil {
+		if err != nil {
+			grpcServer.Stop()
+		} else {
+			grpcServer.GracefulStop()
+		}
+	}
+
+	// Stop startup or the background components before the database they use
+	if stop != nil {
+		stop()
+		select {
+		case <-s.done:
+		case <-ctx.Done():
+			s.logger.Error("Background components did not stop in time", "error", ctx.Err())
+		}
+	}
 
 	// Close database connection
-	s.db.Close()
+	if db != nil {
+		db.Close()
+	}
 
-	// Shutdown HTTP server
-	return s.httpServer.Shutdown(ctx)
+	return err
+}
+
+// runComponents runs every component until ctx is cancelled and closes done
+// once they have all returned
+func (s *Server) runComponents(ctx context.Context) {
+	defer close(s.done)
+
+	var wg sync.WaitGroup
+	for _, c := range s.components {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.Run(ctx); err != nil {
+				s.logger.Error("Background component failed", "component", fmt.Sprintf("%T", c), "error", err)
+			}
+		}()
+	}
+	wg.Wait()
 }
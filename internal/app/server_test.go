@@ -0,0 +1,132 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/travisbale/go-template/internal/jobs"
+)
+
+// writePublicKey writes a JWT verification key to a temporary file
+func writePublicKey(t *testing.T) string {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("failed to encode key: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "jwt.pub")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public}), 0o644); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	return path
+}
+
+// closedAddress returns a local address that refuses connections
+func closedAddress(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close() //nolint:errcheck
+	return address
+}
+
+func TestServerAnswersProbesWhileConnecting(t *testing.T) {
+	httpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	grpcListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	server, err := NewServer(&Config{
+		HTTPAddress:            httpListener.Addr().String(),
+		GRPCAddress:            grpcListener.Addr().String(),
+		DatabaseURL:            "postgres://app@" + closedAddress(t) + "/app?sslmode=disable",
+		DatabaseConnectTimeout: time.Minute,
+		JWTPublicKeyPath:       writePublicKey(t),
+		Logger:                 slog.New(slog.DiscardHandler),
+	})
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(httpListener, grpcListener)
+	}()
+
+	baseURL := "http://" + httpListener.Addr().String()
+	for path, want := range map[string]int{
+		"/healthz":  http.StatusOK,
+		"/readyz":   http.StatusServiceUnavailable,
+		"/v1/audit": http.StatusServiceUnavailable,
+	} {
+		response, err := http.Get(baseURL + path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		response.Body.Close() //nolint:errcheck
+		if response.StatusCode != want {
+			t.Errorf("GET %s: got status %d, want %d", path, response.StatusCode, want)
+		}
+	}
+
+	// Shutdown abandons the connection attempts
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	select {
+	case err := <-served:
+		if !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("Serve returned %v, want %v", err, http.ErrServerClosed)
+		}
+	case <-ctx.Done():
+		t.Fatal("Serve did not return after Shutdown")
+	}
+}
+
+func TestShutdownTimeoutCoversWorkerDrain(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   time.Duration
+	}{
+		{"servers only", Config{Worker: jobs.Config{DrainTimeout: time.Minute}}, defaultShutdownTimeout},
+		{"default drain", Config{RunWorkers: true}, defaultShutdownTimeout + jobs.Config{}.StopTimeout()},
+		{"custom drain", Config{RunWorkers: true, Worker: jobs.Config{DrainTimeout: time.Minute}}, defaultShutdownTimeout + jobs.Config{DrainTimeout: time.Minute}.StopTimeout()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.config.ShutdownTimeout()
+			if got != tt.want {
+				t.Errorf("ShutdownTimeout() = %s, want %s", got, tt.want)
+			}
+			if tt.config.RunWorkers && got <= defaultShutdownTimeout+tt.config.Worker.DrainTimeout {
+				t.Errorf("ShutdownTimeout() = %s leaves no margin after the %s drain", got, tt.config.Worker.DrainTimeout)
+			}
+		})
+	}
+}
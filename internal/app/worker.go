@@ -0,0 +1,53 @@
+package app
+
+import (
+	"context"
+
+	"github.com/travisbale/go-template/internal/db/postgres"
+	"github.com/travisbale/go-template/internal/jobs"
+)
+
+// registerJobHandlers adds the handler for every job kind the application
+// enqueues. Handlers must be idempotent, as a job whose worker dies is run
+// again once its lease expires.
+//
+//	jobs.Handle(registry, "send_welcome_email", func(ctx context.Context, payload WelcomeEmail) error {
+//		return mailer.SendWelcome(ctx, payload.UserID)
+//	})
+func registerJobHandlers(registry *jobs.Registry, db *postgres.DB) {
+	// Register job handlers here
+}
+
+// newJobWorker creates a worker for the application's job handlers
+func newJobWorker(db *postgres.DB, config *Config) *jobs.Worker {
+	registry := jobs.NewRegistry()
+	registerJobHandlers(registry, db)
+	return jobs.NewWorker(db, registry, config.Logger, config.Worker)
+}
+
+// Worker runs background jobs without serving requests, so that job
+// processing can be scaled separately from the API
+type Worker struct {
+	db     *postgres.DB
+	worker *jobs.Worker
+}
+
+// NewWorker connects to the database and creates a standalone job worker
+func NewWorker(ctx context.Context, config *Config) (*Worker, error) {
+	db, err := openDB(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Worker{
+		db:     db,
+		worker: newJobWorker(db, config),
+	}, nil
+}
+
+// Run processes jobs until ctx is cancelled, then drains running jobs and
+// closes the database connection
+func (w *Worker) Run(ctx context.Context) error {
+	defer w.db.Close()
+	return w.worker.Run(ctx)
+}
@@ -0,0 +1,105 @@
+// Package audit records who changed what in each tenant's append-only audit
+// log. Services call Log.Record alongside the change they describe; the entry
+// is written in the transaction carried by the context, so it commits or
+// rolls back with the change. The actor and request ID are taken from the
+// context, where the HTTP middleware puts them.
+package audit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/travisbale/go-template/internal/domain"
+	"github.com/travisbale/heimdall/tenant"
+)
+
+// Record is an entry read from the audit log
+type Record = domain.AuditEntry
+
+// Filter narrows a listing of the audit log
+type Filter = domain.AuditFilter
+
+// Actor types
+const (
+	ActorUser   = "user"
+	ActorSystem = "system"
+)
+
+// Actor identifies who made a change
+type Actor struct {
+	ID   string
+	Type string
+}
+
+// System is the actor of changes made outside a user request, such as by
+// jobs and scheduled tasks
+var System = Actor{ID: "system", Type: ActorSystem}
+
+// Event describes a change to record
+type Event struct {
+	// Action is what was done, e.g. "widget.update"
+	Action       string
+	ResourceType string
+	ResourceID   string
+	// Before and After are the resource's state either side of the change,
+	// nil for creates and deletes respectively. Only the top-level fields
+	// that differ are stored; see Diff.
+	Before any
+	After  any
+}
+
+type store interface {
+	Insert(ctx context.Context, entry Record) (Record, error)
+	List(ctx context.Context, filter Filter, afterCreatedAt *time.Time, afterID *uuid.UUID, limit int32) ([]Record, error)
+}
+
+// Log writes and reads audit entries
+type Log struct {
+	store store
+}
+
+// NewLog creates a log that writes to the given store
+func NewLog(store store) *Log {
+	return &Log{store: store}
+}
+
+// Record writes an entry for the tenant in ctx, attributed to the actor in
+// ctx. Within a transaction started with postgres.DB.InTenantTransaction it
+// is only kept if the transaction commits.
+func (l *Log) Record(ctx context.Context, event Event) error {
+	tenantID, err := tenant.FromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+
+	changes, err := Diff(event.Before, event.After)
+	if err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+
+	actor := ActorFromContext(ctx)
+	_, err = l.store.Insert(ctx, Record{
+		TenantID:     tenantID,
+		ActorID:      actor.ID,
+		ActorType:    actor.Type,
+		Action:       event.Action,
+		ResourceType: event.ResourceType,
+		ResourceID:   event.ResourceID,
+		Changes:      changes,
+		RequestID:    RequestIDFromContext(ctx),
+	})
+	if err != nil {
+		return err
+	}
+
+	markRecorded(ctx)
+	return nil
+}
+
+// List returns the tenant's entries matching filter, newest first, starting
+// after the given keyset position
+func (l *Log) List(ctx context.Context, filter Filter, afterCreatedAt *time.Time, afterID *uuid.UUID, limit int32) ([]Record, error) {
+	return l.store.List(ctx, filter, afterCreatedAt, afterID, limit)
+}
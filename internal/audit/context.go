@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type contextKey int
+
+const (
+	actorKey contextKey = iota
+	requestIDKey
+	trackerKey
+)
+
+// WithActor attributes the changes recorded with ctx to an actor
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// ActorFromContext returns the actor set by WithActor, or System when there is none
+func ActorFromContext(ctx context.Context) Actor {
+	if actor, ok := ctx.Value(actorKey).(Actor); ok {
+		return actor
+	}
+	return System
+}
+
+// WithRequestID ties the changes recorded with ctx to a request
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestIDFromContext returns the request ID set by WithRequestID
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// Track returns a context that notes whether an entry is recorded with it,
+// and a function reporting whether one was. Middleware uses it to record a
+// fallback entry for requests whose handlers did not record their own.
+func Track(ctx context.Context) (context.Context, func() bool) {
+	recorded := new(atomic.Bool)
+	return context.WithValue(ctx, trackerKey, recorded), recorded.Load
+}
+
+func markRecorded(ctx context.Context) {
+	if recorded, ok := ctx.Value(trackerKey).(*atomic.Bool); ok {
+		recorded.Store(true)
+	}
+}
@@ -0,0 +1,72 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Change is the before and after value of a field. A missing side is null.
+type Change struct {
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// Diff compares the JSON encodings of two values, which must encode as
+// objects or be nil, and returns the top-level fields that differ as a JSON
+// object mapping each field to its Change. Fields tagged json:"-" are never
+// recorded, which keeps secrets out of the log.
+func Diff(before, after any) (json.RawMessage, error) {
+	beforeFields, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]Change)
+	for name, value := range beforeFields {
+		if other, ok := afterFields[name]; !ok || !bytes.Equal(value, other) {
+			changes[name] = Change{Before: value, After: afterFields[name]}
+		}
+	}
+	for name, value := range afterFields {
+		if _, ok := beforeFields[name]; !ok {
+			changes[name] = Change{After: value}
+		}
+	}
+
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode changes: %w", err)
+	}
+	return data, nil
+}
+
+// fields decodes a value's JSON object into compacted field values
+func fields(value any) (map[string]json.RawMessage, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %T: %w", value, err)
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, fmt.Errorf("%T does not encode as a JSON object", value)
+	}
+
+	for name, raw := range object {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, raw); err != nil {
+			return nil, fmt.Errorf("failed to compact %s: %w", name, err)
+		}
+		object[name] = compact.Bytes()
+	}
+	return object, nil
+}
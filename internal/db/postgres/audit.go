@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/travisbale/go-template/internal/db/postgres/internal/sqlc"
+	"github.com/travisbale/go-template/internal/domain"
+)
+
+// AuditRepository stores the tenant audit log
+type AuditRepository struct {
+	rows Repository[sqlc.AuditLog, domain.AuditEntry]
+}
+
+// NewAuditRepository creates an adapter for the audit_log table
+func NewAuditRepository(db *DB) *AuditRepository {
+	return &AuditRepository{rows: NewRepository(db, "audit entry", db.setTenant, auditEntryFromRow)}
+}
+
+// Insert writes an entry for the tenant in ctx. It joins the transaction
+// carried by ctx, so the entry commits or rolls back with the change it
+// describes.
+func (r *AuditRepository) Insert(ctx context.Context, entry domain.AuditEntry) (domain.AuditEntry, error) {
+	params := sqlc.InsertAuditEntryParams{
+		TenantID:     entry.TenantID,
+		ActorID:      entry.ActorID,
+		ActorType:    entry.ActorType,
+		Action:       entry.Action,
+		ResourceType: entry.ResourceType,
+		ResourceID:   entry.ResourceID,
+		Changes:      entry.Changes,
+	}
+	if params.Changes == nil {
+		params.Changes = []byte("{}")
+	}
+	if entry.RequestID != "" {
+		params.RequestID = &entry.RequestID
+	}
+
+	return r.rows.Write(ctx, func(ctx context.Context, q *sqlc.Queries) (sqlc.AuditLog, error) {
+		return q.InsertAuditEntry(ctx, params)
+	})
+}
+
+// List returns the tenant's entries matching filter, newest first, starting
+// after the given keyset position
+func (r *AuditRepository) List(ctx context.Context, filter domain.AuditFilter, afterCreatedAt *time.Time, afterID *uuid.UUID, limit int32) ([]domain.AuditEntry, error) {
+	return r.rows.List(ctx, func(ctx context.Context, q *sqlc.Queries) ([]sqlc.AuditLog, error) {
+		return q.ListAuditEntries(ctx, sqlc.ListAuditEntriesParams{
+			ActorID:        filter.ActorID,
+			Action:         filter.Action,
+			ResourceType:   filter.ResourceType,
+			ResourceID:     filter.ResourceID,
+			RequestID:      filter.RequestID,
+			CreatedFrom:    filter.CreatedFrom,
+			CreatedUntil:   filter.CreatedUntil,
+			AfterCreatedAt: afterCreatedAt,
+			AfterID:        afterID,
+			Limit:          limit,
+		})
+	})
+}
+
+func auditEntryFromRow(row sqlc.AuditLog) domain.AuditEntry {
+	entry := domain.AuditEntry{
+		ID:           row.ID,
+		TenantID:     row.TenantID,
+		ActorID:      row.ActorID,
+		ActorType:    row.ActorType,
+		Action:       row.Action,
+		ResourceType: row.ResourceType,
+		ResourceID:   row.ResourceID,
+		Changes:      row.Changes,
+		CreatedAt:    row.CreatedAt,
+	}
+	if row.RequestID != nil {
+		entry.RequestID = *row.RequestID
+	}
+	return entry
+}
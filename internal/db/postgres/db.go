@@ -3,12 +3,12 @@ package postgres
 import (
 	"context"
 	"fmt"
-	"log/slog"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/travisbale/go-template/internal/db/postgres/internal/sqlc"
+	"github.com/travisbale/heimdall/tenant"
 )
 
 type logger interface {
@@ -18,43 +18,142 @@ type logger interface {
 
 // DB wraps the pgx connection pool
 type DB struct {
-	pool   *pgxpool.Pool
-	logger logger
+	pool     *pgxpool.Pool
+	replicas *replicaSet
+	listener *listener
+	logger   logger
 }
 
-// NewDB creates a new database connection pool
-func NewDB(ctx context.Context, databaseURL string, logger logger) (*DB, error) {
-	config, err := pgxpool.ParseConfig(databaseURL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse database URL: %w", err)
+// Option configures optional database behaviour
+type Option func(*options)
+
+type options struct {
+	connectTimeout time.Duration
+	pool           PoolConfig
+	afterConnect   []func(context.Context, *pgx.Conn) error
+	replicaURLs    []string
+	replicaMaxLag  time.Duration
+}
+
+// WithConnectTimeout makes NewDB retry with backoff until the database accepts
+// connections or the timeout elapses, instead of failing on the first attempt
+func WithConnectTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.connectTimeout = timeout
 	}
+}
+
+// WithPoolConfig overrides the connection pool settings
+func WithPoolConfig(config PoolConfig) Option {
+	return func(o *options) {
+		o.pool = config
+	}
+}
 
-	// Configure connection pool
-	config.MaxConns = 25
-	config.MinConns = 5
-	config.MaxConnLifetime = time.Hour
-	config.MaxConnIdleTime = 30 * time.Minute
-	config.HealthCheckPeriod = time.Minute
+// WithAfterConnect adds a hook that runs on every new connection after the
+// session settings and custom types have been applied
+func WithAfterConnect(hook func(ctx context.Context, conn *pgx.Conn) error) Option {
+	return func(o *options) {
+		o.afterConnect = append(o.afterConnect, hook)
+	}
+}
+
+// WithReplicas routes read-only work to the given read replicas, excluding
+// any that are unreachable or lag the primary by more than maxLag
+func WithReplicas(urls []string, maxLag time.Duration) Option {
+	return func(o *options) {
+		o.replicaURLs = append(o.replicaURLs, urls...)
+		o.replicaMaxLag = maxLag
+	}
+}
+
+// NewDB creates a new database connection pool
+func NewDB(ctx context.Context, databaseURL string, logger logger, opts ...Option) (*DB, error) {
+	options := &options{}
+	for _, opt := range opts {
+		opt(options)
+	}
 
-	pool, err := pgxpool.NewWithConfig(ctx, config)
+	pool, err := newPool(ctx, databaseURL, options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create connection pool: %w", err)
+		return nil, err
+	}
+
+	db := &DB{
+		pool:     pool,
+		listener: newListener(pool.Config().ConnConfig, logger),
+		logger:   logger,
 	}
 
 	// Verify connection
-	if err := pool.Ping(ctx); err != nil {
+	if err := db.waitForConnection(ctx, options.connectTimeout); err != nil {
 		pool.Close()
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+		return nil, err
+	}
+
+	if len(options.replicaURLs) > 0 {
+		db.replicas, err = newReplicaSet(ctx, options, logger)
+		if err != nil {
+			pool.Close()
+			return nil, err
+		}
 	}
 
-	return &DB{
-		pool:   pool,
-		logger: logger,
-	}, nil
+	return db, nil
 }
 
-// Close closes the database connection pool
+// waitForConnection pings the primary until it responds, backing off between
+// attempts, so the process can start before the database is accepting connections
+func (d *DB) waitForConnection(ctx context.Context, timeout time.Duration) error {
+	const (
+		initialBackoff = 100 * time.Millisecond
+		maxBackoff     = 5 * time.Second
+	)
+
+	if timeout <= 0 {
+		if err := d.pool.Ping(ctx); err != nil {
+			return fmt.Errorf("failed to ping database: %w", err)
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	backoff := initialBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := d.pool.Ping(ctx)
+		if err == nil {
+			if attempt > 1 {
+				d.logger.Info("Connected to database", "attempts", attempt, "elapsed", time.Since(start).Round(time.Millisecond))
+			}
+			return nil
+		}
+
+		remaining := timeout - time.Since(start)
+		if remaining <= 0 || ctx.Err() != nil {
+			return fmt.Errorf("failed to ping database after %d attempts: %w", attempt, err)
+		}
+
+		d.logger.Info("Waiting for database", "attempt", attempt, "retry_in", backoff, "remaining", remaining.Round(time.Second), "error", err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to ping database after %d attempts: %w", attempt, err)
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// Close ends every subscription and closes the database connection pool
 func (d *DB) Close() {
+	d.listener.close()
+	if d.replicas != nil {
+		d.replicas.close()
+	}
 	d.pool.Close()
 }
 
@@ -63,11 +162,38 @@ func (d *DB) Health(ctx context.Context) error {
 	return d.pool.Ping(ctx)
 }
 
-// Queries returns a new Queries instance for executing SQL queries
+// Replicas reports the health of each configured read replica
+func (d *DB) Replicas() []ReplicaStatus {
+	if d.replicas == nil {
+		return nil
+	}
+	return d.replicas.statuses()
+}
+
+// Queries returns a new Queries instance for executing SQL queries.
+// Application code should prefer a repository adapter, which maps rows and
+// errors to domain types.
 func (d *DB) Queries() *sqlc.Queries {
 	return sqlc.New(d.pool)
 }
 
+// ReadQueries returns a Queries instance for read-only queries. It uses a
+// healthy read replica when one is configured, and the primary otherwise, so
+// results may be slightly stale.
+func (d *DB) ReadQueries() *sqlc.Queries {
+	return sqlc.New(d.readPool())
+}
+
+// readPool picks a healthy replica in round-robin order, falling back to the primary
+func (d *DB) readPool() *pgxpool.Pool {
+	if d.replicas != nil {
+		if pool := d.replicas.next(); pool != nil {
+			return pool
+		}
+	}
+	return d.pool
+}
+
 // Pool returns the underlying pgx connection pool
 func (d *DB) Pool() *pgxpool.Pool {
 	return d.pool
@@ -76,63 +202,55 @@ func (d *DB) Pool() *pgxpool.Pool {
 // WithTransaction executes a function within a database transaction.
 // Use this for operations that don't require tenant scoping
 func (d *DB) WithTransaction(ctx context.Context, fn func(*sqlc.Queries) error) error {
-	// Begin transaction
-	tx, err := d.pool.Begin(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	// Rollback is safe to call even if the transaction is later committed
-	defer func() {
-		if err2 := tx.Rollback(ctx); err2 != nil {
-			slog.Error("failed to rollback transaction", "error", err2)
-		}
-	}()
-
-	// Execute function
-	queries := sqlc.New(tx)
-	if err := fn(queries); err != nil {
-		return err
-	}
-
-	// Commit transaction
-	return tx.Commit(ctx)
+	return d.runTx(ctx, pgx.TxOptions{}, nil, func(_ context.Context, queries *sqlc.Queries) error {
+		return fn(queries)
+	})
 }
 
 // WithTenantContext executes a function within a tenant-scoped transaction.
 // This sets the app.current_tenant_id session variable which is used by
 // Row Level Security (RLS) policies to automatically filter queries.
 func (d *DB) WithTenantContext(ctx context.Context, fn func(*sqlc.Queries) error) error {
+	// Execute function with tenant-scoped queries
+	return d.runTx(ctx, pgx.TxOptions{}, d.setTenant, func(_ context.Context, queries *sqlc.Queries) error {
+		return fn(queries)
+	})
+}
+
+// setTenant sets the tenant context for RLS on the current transaction. The
+// setting lasts until the transaction ends, so in a savepoint the enclosing
+// tenant is restored when the savepoint is released.
+func (d *DB) setTenant(ctx context.Context, tx pgx.Tx) error {
 	// Extract tenant ID from context
-	// tenantID, err := tenant.FromContext(ctx)
-	// if err != nil {
-	// 	return fmt.Errorf("failed to get tenant from context: %w", err)
-	// }
-	tenantID := uuid.MustParse("d707726c-7062-4164-bc6a-fc8134661490")
-
-	// Begin transaction
-	tx, err := d.pool.Begin(ctx)
+	tenantID, err := tenant.FromContext(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return fmt.Errorf("failed to get tenant from context: %w", err)
 	}
-	// Rollback is safe to call even if the transaction is later committed
-	defer func() {
-		if err2 := tx.Rollback(ctx); err2 != nil {
-			slog.Error("failed to rollback transaction", "error", err2)
-		}
-	}()
 
-	// Set tenant context for RLS
-	_, err = tx.Exec(ctx, "SET LOCAL app.current_tenant_id = $1", tenantID.String())
-	if err != nil {
-		return fmt.Errorf("failed to set tenant context: %w", err)
+	state, ok := txFromContext(ctx)
+	if !ok || !state.savepoint {
+		return setTenantID(ctx, tx, tenantID.String())
 	}
 
-	// Execute function with tenant-scoped queries
-	queries := sqlc.New(tx)
-	if err := fn(queries); err != nil {
+	var previous string
+	if err := tx.QueryRow(ctx, "SELECT coalesce(current_setting('app.current_tenant_id', true), '')").Scan(&previous); err != nil {
+		return fmt.Errorf("failed to get tenant context: %w", err)
+	}
+	if previous == tenantID.String() {
+		return nil
+	}
+	if err := setTenantID(ctx, tx, tenantID.String()); err != nil {
 		return err
 	}
+	state.release = append(state.release, func(ctx context.Context) error {
+		return setTenantID(ctx, tx, previous)
+	})
+	return nil
+}
 
-	// Commit transaction
-	return tx.Commit(ctx)
+func setTenantID(ctx context.Context, tx pgx.Tx, tenantID string) error {
+	if _, err := tx.Exec(ctx, "SELECT set_config('app.current_tenant_id', $1, true)", tenantID); err != nil {
+		return fmt.Errorf("failed to set tenant context: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,187 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/travisbale/go-template/internal/db/postgres/internal/sqlc"
+)
+
+// defaultJobMaxAttempts matches the column default
+const defaultJobMaxAttempts = 25
+
+// Job is a background job claimed by a worker
+type Job struct {
+	ID          uuid.UUID
+	Kind        string
+	Payload     json.RawMessage
+	Attempts    int
+	MaxAttempts int
+	RunAt       time.Time
+	CreatedAt   time.Time
+}
+
+// JobOption configures an enqueued job
+type JobOption func(*sqlc.EnqueueJobParams)
+
+// RunAt schedules the job to run no earlier than the given time
+func RunAt(at time.Time) JobOption {
+	return func(p *sqlc.EnqueueJobParams) {
+		p.RunAt = &at
+	}
+}
+
+// RunIn schedules the job to run after the given delay
+func RunIn(delay time.Duration) JobOption {
+	return RunAt(time.Now().Add(delay))
+}
+
+// UniqueKey skips enqueueing while another pending or running job holds the same key
+func UniqueKey(key string) JobOption {
+	return func(p *sqlc.EnqueueJobParams) {
+		p.UniqueKey = &key
+	}
+}
+
+// MaxAttempts sets how many times the job is tried before it is marked failed
+func MaxAttempts(attempts int) JobOption {
+	return func(p *sqlc.EnqueueJobParams) {
+		p.MaxAttempts = int32(attempts)
+	}
+}
+
+// EnqueueJob adds a job using the queries of the enclosing transaction, so
+// that it only runs if the transaction commits. It returns false without an
+// error when a unique job with the same key is already pending or running.
+func EnqueueJob(ctx context.Context, q *sqlc.Queries, kind string, payload any, opts ...JobOption) (bool, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode job payload: %w", err)
+	}
+
+	params := sqlc.EnqueueJobParams{Kind: kind, Payload: data, MaxAttempts: defaultJobMaxAttempts}
+	for _, opt := range opts {
+		opt(&params)
+	}
+
+	if _, err := q.EnqueueJob(ctx, params); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to enqueue job: %w", err)
+	}
+	return true, nil
+}
+
+// EnqueueJob adds a job in the transaction carried by ctx, or on its own
+// when there is none
+func (d *DB) EnqueueJob(ctx context.Context, kind string, payload any, opts ...JobOption) (bool, error) {
+	return EnqueueJob(ctx, d.queries(ctx), kind, payload, opts...)
+}
+
+// ClaimJobs leases up to limit due jobs of the given kinds to the caller,
+// including running jobs whose worker stopped renewing the lease and that
+// have attempts left
+func (d *DB) ClaimJobs(ctx context.Context, kinds []string, limit int, lease time.Duration) ([]Job, error) {
+	rows, err := d.Queries().ClaimJobs(ctx, sqlc.ClaimJobsParams{
+		LeaseSeconds: lease.Seconds(),
+		Kinds:        kinds,
+		BatchSize:    int32(limit),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim jobs: %w", err)
+	}
+
+	jobs := make([]Job, len(rows))
+	for i, row := range rows {
+		jobs[i] = Job{
+			ID:          row.ID,
+			Kind:        row.Kind,
+			Payload:     row.Payload,
+			Attempts:    int(row.Attempts),
+			MaxAttempts: int(row.MaxAttempts),
+			RunAt:       row.RunAt,
+			CreatedAt:   row.CreatedAt,
+		}
+	}
+	return jobs, nil
+}
+
+// ExtendJobLease keeps a running job from being claimed by another worker. It
+// returns ErrLeaseLost if the job has been claimed again since.
+func (d *DB) ExtendJobLease(ctx context.Context, job Job, lease time.Duration) error {
+	updated, err := d.Queries().ExtendJobLease(ctx, sqlc.ExtendJobLeaseParams{
+		LeaseSeconds: lease.Seconds(),
+		ID:           job.ID,
+		Attempts:     int32(job.Attempts),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to extend job lease: %w", err)
+	}
+	return leaseHeld(updated)
+}
+
+// CompleteJob records that a job succeeded. It returns ErrLeaseLost if the
+// job has been claimed again since.
+func (d *DB) CompleteJob(ctx context.Context, job Job) error {
+	updated, err := d.Queries().CompleteJob(ctx, sqlc.CompleteJobParams{ID: job.ID, Attempts: int32(job.Attempts)})
+	if err != nil {
+		return fmt.Errorf("failed to complete job: %w", err)
+	}
+	return leaseHeld(updated)
+}
+
+// RetryJob returns a job to the queue to run again at the given time. It
+// returns ErrLeaseLost if the job has been claimed again since.
+func (d *DB) RetryJob(ctx context.Context, job Job, at time.Time, cause error) error {
+	message := cause.Error()
+	updated, err := d.Queries().RetryJob(ctx, sqlc.RetryJobParams{
+		ID:        job.ID,
+		RunAt:     at,
+		LastError: &message,
+		Attempts:  int32(job.Attempts),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reschedule job: %w", err)
+	}
+	return leaseHeld(updated)
+}
+
+// FailJob marks a job as permanently failed. It returns ErrLeaseLost if the
+// job has been claimed again since.
+func (d *DB) FailJob(ctx context.Context, job Job, cause error) error {
+	message := cause.Error()
+	updated, err := d.Queries().FailJob(ctx, sqlc.FailJobParams{
+		ID:        job.ID,
+		LastError: &message,
+		Attempts:  int32(job.Attempts),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark job failed: %w", err)
+	}
+	return leaseHeld(updated)
+}
+
+// FailAbandonedJobs marks running jobs as failed when their lease expired on
+// the final attempt, since ClaimJobs no longer picks them up
+func (d *DB) FailAbandonedJobs(ctx context.Context) (int64, error) {
+	failed, err := d.Queries().FailAbandonedJobs(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark abandoned jobs failed: %w", err)
+	}
+	return failed, nil
+}
+
+// PurgeCompletedJobs deletes jobs completed before the given time
+func (d *DB) PurgeCompletedJobs(ctx context.Context, before time.Time) (int64, error) {
+	deleted, err := d.Queries().PurgeCompletedJobs(ctx, &before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge jobs: %w", err)
+	}
+	return deleted, nil
+}
@@ -1,86 +1,493 @@
 package postgres
 
 import (
+	"context"
 	"embed"
+	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
+	"time"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
+// sqlStateUndefinedTable is returned when schema_migrations does not exist yet
+const sqlStateUndefinedTable = "42P01"
+
 //go:embed migrations/*.sql
 var migrationsFS embed.FS
 
-// MigrateUp applies all pending migrations
-func MigrateUp(databaseURL string) error {
-	sourceDriver, err := iofs.New(migrationsFS, "migrations")
+// Migration is a migration embedded in the binary
+type Migration struct {
+	Version uint
+	Name    string
+	// Data is set for Go data migrations
+	Data    bool
+	Applied bool
+	Dirty   bool
+}
+
+// MigrationResult describes the outcome of a migration run
+type MigrationResult struct {
+	// From and To are the schema versions before and after the run, 0 meaning none
+	From uint
+	To   uint
+	// Applied is the number of migrations run, in either direction
+	Applied  int
+	Duration time.Duration
+}
+
+// Migrator applies the embedded migrations to a database
+type Migrator struct {
+	databaseURL string
+	logger      logger
+}
+
+// NewMigrator creates a migrator for the given database
+func NewMigrator(databaseURL string, logger logger) *Migrator {
+	return &Migrator{databaseURL: databaseURL, logger: logger}
+}
+
+// Up applies all pending migrations
+func (m *Migrator) Up(ctx context.Context) (*MigrationResult, error) {
+	latest, err := LatestVersion()
 	if err != nil {
-		return fmt.Errorf("failed to create iofs driver: %w", err)
+		return nil, err
+	}
+	return m.upTo(ctx, "apply migrations", func(pending []Migration) uint {
+		return latest
+	})
+}
+
+// Down rolls back the last migration
+func (m *Migrator) Down(ctx context.Context) (*MigrationResult, error) {
+	return m.Steps(ctx, -1)
+}
+
+// Steps applies n pending migrations, or rolls back -n migrations when n is negative
+func (m *Migrator) Steps(ctx context.Context, n int) (*MigrationResult, error) {
+	if n > 0 {
+		return m.upTo(ctx, fmt.Sprintf("migrate %d steps", n), func(pending []Migration) uint {
+			if len(pending) == 0 {
+				return 0
+			}
+			return pending[min(n, len(pending))-1].Version
+		})
 	}
+	return m.run(ctx, fmt.Sprintf("migrate %d steps", n), func(mig *migrate.Migrate) error {
+		return mig.Steps(n)
+	})
+}
+
+// DownAll rolls back every applied migration
+func (m *Migrator) DownAll(ctx context.Context) (*MigrationResult, error) {
+	return m.run(ctx, "rollback migrations", (*migrate.Migrate).Down)
+}
 
-	m, err := migrate.NewWithSourceInstance("iofs", sourceDriver, databaseURL)
+// Goto migrates up or down to the given version, which must be 0 or the
+// version of an embedded migration
+func (m *Migrator) Goto(ctx context.Context, version uint) (*MigrationResult, error) {
+	if err := checkVersion(version); err != nil {
+		return nil, err
+	}
+	current, _, err := m.Version(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create migrate instance: %w", err)
+		return nil, err
 	}
-	defer m.Close() //nolint:errcheck
+	if version >= current {
+		return m.upTo(ctx, fmt.Sprintf("migrate to version %d", version), func([]Migration) uint {
+			return version
+		})
+	}
+	return m.run(ctx, fmt.Sprintf("migrate to version %d", version), func(mig *migrate.Migrate) error {
+		return mig.Migrate(version)
+	})
+}
 
-	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-		return fmt.Errorf("failed to apply migrations: %w", err)
+// Force records the given version as applied and clears the dirty flag
+// without running any migrations. It is used to recover after a migration
+// failed partway and the database has been repaired by hand. A version of -1
+// marks the database as having no migrations applied.
+func (m *Migrator) Force(ctx context.Context, version int) error {
+	mig, err := m.open()
+	if err != nil {
+		return err
 	}
+	defer m.close(mig)
 
-	if err == migrate.ErrNoChange {
-		fmt.Println("No migrations to apply")
-		return nil
+	if err := mig.Force(version); err != nil {
+		return fmt.Errorf("failed to force version %d: %w", version, err)
 	}
 
-	fmt.Println("Migrations applied successfully")
+	m.logger.Info("Forced migration version", "version", version)
 	return nil
 }
 
-// MigrateDown rolls back the last migration
-func MigrateDown(databaseURL string) error {
-	sourceDriver, err := iofs.New(migrationsFS, "migrations")
+// Version returns the current migration version and whether the last migration failed partway
+func (m *Migrator) Version(ctx context.Context) (uint, bool, error) {
+	mig, err := m.open()
 	if err != nil {
-		return fmt.Errorf("failed to create iofs driver: %w", err)
+		return 0, false, err
 	}
+	defer m.close(mig)
+
+	return version(mig)
+}
 
-	m, err := migrate.NewWithSourceInstance("iofs", sourceDriver, databaseURL)
+// Status lists every embedded migration and whether it has been applied
+func (m *Migrator) Status(ctx context.Context) ([]Migration, error) {
+	current, dirty, err := m.Version(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create migrate instance: %w", err)
+		return nil, err
 	}
-	defer m.Close() //nolint:errcheck
 
-	if err := m.Steps(-1); err != nil && err != migrate.ErrNoChange {
-		return fmt.Errorf("failed to rollback migration: %w", err)
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	for i := range migrations {
+		migrations[i].Applied = migrations[i].Version <= current
+		migrations[i].Dirty = dirty && migrations[i].Version == current
 	}
+	return migrations, nil
+}
 
-	if err == migrate.ErrNoChange {
-		fmt.Println("No migrations to rollback")
-		return nil
+// upTo applies pending migrations up to the version chosen by target, one at
+// a time so that data migrations run at their place in the sequence.
+// Cancelling ctx stops at the next migration boundary.
+func (m *Migrator) upTo(ctx context.Context, operation string, target func(pending []Migration) uint) (*MigrationResult, error) {
+	mig, err := m.open()
+	if err != nil {
+		return nil, err
 	}
+	defer m.close(mig)
 
-	fmt.Println("Migration rolled back successfully")
-	return nil
+	start := time.Now()
+	from, dirty, err := version(mig)
+	if err != nil {
+		return nil, err
+	}
+	if dirty {
+		return nil, fmt.Errorf("failed to %s: %w", operation, migrate.ErrDirty{Version: int(from)})
+	}
+
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, migration := range migrations {
+		if migration.Version > from {
+			pending = append(pending, migration)
+		}
+	}
+
+	result := &MigrationResult{From: from, To: from}
+	to := target(pending)
+	for _, migration := range pending {
+		if migration.Version > to {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			result.Duration = time.Since(start)
+			return result, fmt.Errorf("failed to %s: %w", operation, err)
+		}
+
+		if migration.Data {
+			if err := m.runDataMigration(ctx, dataMigrations[migration.Version]); err != nil {
+				result.Duration = time.Since(start)
+				return result, fmt.Errorf("failed to %s: %w", operation, err)
+			}
+		}
+		if err := mig.Migrate(migration.Version); err != nil {
+			result.Duration = time.Since(start)
+			return result, fmt.Errorf("failed to %s: %w", operation, err)
+		}
+
+		m.logger.Info("Applied migration", "version", migration.Version, "name", migration.Name)
+		result.To = migration.Version
+		result.Applied++
+	}
+	result.Duration = time.Since(start)
+
+	if result.Applied == 0 {
+		m.logger.Info("No migrations to apply", "version", result.From)
+		return result, nil
+	}
+
+	m.logger.Info("Migrations complete", "from", result.From, "to", result.To, "applied", result.Applied, "duration", result.Duration.Round(time.Millisecond))
+	return result, nil
 }
 
-// MigrateVersion returns the current migration version
-func MigrateVersion(databaseURL string) (uint, bool, error) {
-	sourceDriver, err := iofs.New(migrationsFS, "migrations")
+// run executes a migration operation, stopping it at the next migration
+// boundary if ctx is cancelled so that the database is not left dirty
+func (m *Migrator) run(ctx context.Context, operation string, fn func(*migrate.Migrate) error) (*MigrationResult, error) {
+	mig, err := m.open()
 	if err != nil {
-		return 0, false, fmt.Errorf("failed to create iofs driver: %w", err)
+		return nil, err
 	}
+	defer m.close(mig)
 
-	m, err := migrate.NewWithSourceInstance("iofs", sourceDriver, databaseURL)
+	start := time.Now()
+	from, _, err := version(mig)
 	if err != nil {
-		return 0, false, fmt.Errorf("failed to create migrate instance: %w", err)
+		return nil, err
 	}
-	defer m.Close() //nolint:errcheck
 
-	version, dirty, err := m.Version()
-	if err != nil && err != migrate.ErrNilVersion {
-		return 0, false, fmt.Errorf("failed to get migration version: %w", err)
+	done := make(chan error, 1)
+	go func() { done <- fn(mig) }()
+
+	var cancelled bool
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		m.logger.Info("Stopping migrations after the current step", "reason", ctx.Err())
+		mig.GracefulStop <- true
+		err = <-done
+		cancelled = true
+	}
+
+	result := &MigrationResult{From: from, To: from, Duration: time.Since(start)}
+	if to, _, versionErr := version(mig); versionErr == nil {
+		result.To = to
+		result.Applied = countBetween(from, to)
+	}
+
+	if result.To < result.From {
+		if forgetErr := m.forgetDataMigrations(ctx, result.To); forgetErr != nil {
+			m.logger.Error("failed to reset data migration progress", "error", forgetErr)
+		}
+	}
+
+	switch {
+	case errors.Is(err, migrate.ErrNoChange):
+		m.logger.Info("No migrations to apply", "version", result.From)
+		return result, nil
+	case err != nil:
+		return result, fmt.Errorf("failed to %s: %w", operation, err)
+	case cancelled:
+		return result, fmt.Errorf("failed to %s: %w", operation, ctx.Err())
+	}
+
+	m.logger.Info("Migrations complete", "from", result.From, "to", result.To, "applied", result.Applied, "duration", result.Duration.Round(time.Millisecond))
+	return result, nil
+}
+
+// open creates a migrate instance reading the embedded and data migrations
+func (m *Migrator) open() (*migrate.Migrate, error) {
+	sourceDriver, err := newMigrationSource()
+	if err != nil {
+		return nil, err
+	}
+
+	mig, err := migrate.NewWithSourceInstance("embedded", sourceDriver, m.databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migrate instance: %w", err)
+	}
+	mig.Log = migrateLogger{m.logger}
+	return mig, nil
+}
+
+func (m *Migrator) close(mig *migrate.Migrate) {
+	sourceErr, databaseErr := mig.Close()
+	if err := errors.Join(sourceErr, databaseErr); err != nil {
+		m.logger.Error("failed to close migrate instance", "error", err)
 	}
+}
 
+// version reads the schema version, treating an unmigrated database as version 0
+func version(mig *migrate.Migrate) (uint, bool, error) {
+	version, dirty, err := mig.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, fmt.Errorf("failed to get migration version: %w", err)
+	}
 	return version, dirty, nil
 }
+
+// countBetween counts the embedded migrations between two versions, which is
+// the number applied or rolled back when moving from one to the other
+func countBetween(from, to uint) int {
+	migrations, err := Migrations()
+	if err != nil {
+		return 0
+	}
+
+	low, high := min(from, to), max(from, to)
+	count := 0
+	for _, migration := range migrations {
+		if migration.Version > low && migration.Version <= high {
+			count++
+		}
+	}
+	return count
+}
+
+// migrateLogger adapts the package logger to golang-migrate's logger
+type migrateLogger struct {
+	logger logger
+}
+
+func (l migrateLogger) Printf(format string, v ...any) {
+	l.logger.Info(strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
+
+func (l migrateLogger) Verbose() bool {
+	return false
+}
+
+// MigrateUp applies all pending migrations, logging to the default logger
+func MigrateUp(ctx context.Context, databaseURL string) (*MigrationResult, error) {
+	return NewMigrator(databaseURL, slog.Default()).Up(ctx)
+}
+
+// MigrateDown rolls back the last migration, logging to the default logger
+func MigrateDown(ctx context.Context, databaseURL string) (*MigrationResult, error) {
+	return NewMigrator(databaseURL, slog.Default()).Down(ctx)
+}
+
+// MigrateVersion returns the current migration version
+func MigrateVersion(ctx context.Context, databaseURL string) (uint, bool, error) {
+	return NewMigrator(databaseURL, slog.Default()).Version(ctx)
+}
+
+// Migrations lists the SQL and data migrations embedded in the binary in version order
+func Migrations() ([]Migration, error) {
+	src, err := newMigrationSource()
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]Migration, 0, len(src.versions))
+	for _, version := range src.versions {
+		if migration, ok := dataMigrations[version]; ok {
+			migrations = append(migrations, Migration{Version: version, Name: migration.Name, Data: true})
+			continue
+		}
+		for _, name := range src.files[version] {
+			parsed, _ := source.Parse(name)
+			migrations = append(migrations, Migration{Version: version, Name: parsed.Identifier})
+			break
+		}
+	}
+	return migrations, nil
+}
+
+// checkVersion returns an error unless version is 0, meaning no migrations,
+// or the version of an embedded migration
+func checkVersion(version uint) error {
+	if version == 0 {
+		return nil
+	}
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+	for _, migration := range migrations {
+		if migration.Version == version {
+			return nil
+		}
+	}
+	return fmt.Errorf("no migration with version %d", version)
+}
+
+// migrationLockID is the advisory lock key held while migrating on startup.
+// It is distinct from golang-migrate's own lock so that instances queue up
+// here rather than failing to acquire that lock.
+const migrationLockID int64 = 0x6d69677261746521
+
+// LatestVersion returns the highest migration version embedded in the binary
+func LatestVersion() (uint, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return 0, err
+	}
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
+
+// MigrateWithLock applies pending migrations while holding a Postgres advisory
+// lock, so that when several instances start at once only one migrates and
+// the others wait for it and then find nothing to apply
+func (d *DB) MigrateWithLock(ctx context.Context, databaseURL string) error {
+	conn, err := d.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	d.logger.Info("Acquiring migration lock")
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		if _, err := conn.Exec(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", migrationLockID); err != nil {
+			d.logger.Error("failed to release migration lock", "error", err)
+		}
+	}()
+
+	_, err = NewMigrator(databaseURL, d.logger).Up(ctx)
+	return err
+}
+
+// WaitForSchema blocks until the database schema is at the version embedded in
+// the binary, for instances that do not run migrations themselves. It fails
+// immediately if the database is ahead of the binary or a migration failed
+// and left it dirty.
+func (d *DB) WaitForSchema(ctx context.Context, timeout time.Duration) error {
+	latest, err := LatestVersion()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		version, dirty, err := d.schemaVersion(ctx)
+		switch {
+		case err != nil:
+			return err
+		case dirty:
+			return fmt.Errorf("database schema is dirty at version %d, run migrate force after fixing it", version)
+		case version > latest:
+			return fmt.Errorf("database schema version %d is ahead of this binary (%d)", version, latest)
+		case version == latest:
+			return nil
+		}
+
+		d.logger.Info("Waiting for database migrations", "current_version", version, "required_version", latest)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for schema version %d (at %d): %w", latest, version, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// schemaVersion reads the version recorded by golang-migrate. A database that
+// has never been migrated is reported as version 0.
+func (d *DB) schemaVersion(ctx context.Context) (uint, bool, error) {
+	var version int64
+	var dirty bool
+	err := d.pool.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, pgx.ErrNoRows), errors.As(err, &pgErr) && pgErr.Code == sqlStateUndefinedTable:
+		return 0, false, nil
+	case err != nil:
+		return 0, false, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return uint(version), dirty, nil
+}
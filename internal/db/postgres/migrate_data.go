@@ -0,0 +1,335 @@
+package postgres
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// DataMigration is a migration written in Go, typically a backfill that is
+// awkward in SQL. It takes a version in the same sequence as the SQL files
+// and runs when migrating up through that version, in batches that each
+// commit together with the cursor to resume from, so an interrupted run picks
+// up where it stopped. Rolling back through the version is a no-op that
+// forgets the progress.
+//
+// Register data migrations from an init function in this package:
+//
+//	func init() {
+//		RegisterDataMigration(DataMigration{
+//			Version: 3,
+//			Name:    "backfill_slugs",
+//			Batch: func(ctx context.Context, tx pgx.Tx, cursor string) (string, bool, error) {
+//				var last string
+//				err := tx.QueryRow(ctx, `WITH batch AS (
+//					SELECT id FROM widgets WHERE slug IS NULL AND id::text > $1 ORDER BY id LIMIT 1000
+//				), updated AS (
+//					UPDATE widgets SET slug = lower(name) WHERE id IN (SELECT id FROM batch) RETURNING id
+//				) SELECT coalesce(max(id::text), '') FROM updated`, cursor).Scan(&last)
+//				return last, last != "", err
+//			},
+//		})
+//	}
+type DataMigration struct {
+	Version uint
+	Name    string
+	// Batch processes the batch after cursor, which is empty on the first
+	// call, and returns the cursor of the next batch and whether one remains
+	Batch func(ctx context.Context, tx pgx.Tx, cursor string) (next string, more bool, err error)
+}
+
+// DataMigrationStatus reports the progress of a data migration
+type DataMigrationStatus struct {
+	Version   uint
+	Name      string
+	Batches   int64
+	Cursor    string
+	Completed bool
+	UpdatedAt time.Time
+}
+
+// dataMigrations holds the registered data migrations by version
+var dataMigrations = make(map[uint]DataMigration)
+
+// RegisterDataMigration adds a data migration. It panics if the version is
+// already registered, as that is a programming error.
+func RegisterDataMigration(migration DataMigration) {
+	if _, ok := dataMigrations[migration.Version]; ok {
+		panic(fmt.Sprintf("data migration version %d registered twice", migration.Version))
+	}
+	dataMigrations[migration.Version] = migration
+}
+
+// DataMigrationVersions returns the versions taken by registered data migrations in order
+func DataMigrationVersions() []uint {
+	return slices.Sorted(maps.Keys(dataMigrations))
+}
+
+// createDataMigrationsTable tracks data migration progress. It is created on
+// demand, like schema_migrations, so it exists before any migration runs.
+const createDataMigrationsTable = `CREATE TABLE IF NOT EXISTS data_migrations (
+	version      BIGINT PRIMARY KEY,
+	name         TEXT NOT NULL,
+	resume_after TEXT NOT NULL DEFAULT '',
+	batches      BIGINT NOT NULL DEFAULT 0,
+	updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	completed_at TIMESTAMPTZ
+)`
+
+// runDataMigration runs the remaining batches of a data migration
+func (m *Migrator) runDataMigration(ctx context.Context, migration DataMigration) error {
+	conn, err := pgx.Connect(ctx, m.databaseURL)
+	if err != nil {
+		return fmt.Errorf("failed to connect for data migration: %w", err)
+	}
+	defer conn.Close(context.WithoutCancel(ctx)) //nolint:errcheck
+
+	if _, err := conn.Exec(ctx, createDataMigrationsTable); err != nil {
+		return fmt.Errorf("failed to create data_migrations table: %w", err)
+	}
+	if _, err := conn.Exec(ctx, "INSERT INTO data_migrations (version, name) VALUES ($1, $2) ON CONFLICT (version) DO NOTHING",
+		int64(migration.Version), migration.Name); err != nil {
+		return fmt.Errorf("failed to record data migration: %w", err)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		done, err := m.runBatch(ctx, conn, migration)
+		if err != nil {
+			return fmt.Errorf("data migration %d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+// runBatch runs one batch and saves the cursor in the same transaction. The
+// progress row is locked so that concurrent runners take turns.
+func (m *Migrator) runBatch(ctx context.Context, conn *pgx.Conn, migration DataMigration) (bool, error) {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(context.WithoutCancel(ctx)) //nolint:errcheck
+
+	var cursor string
+	var batches int64
+	var completed bool
+	err = tx.QueryRow(ctx, "SELECT resume_after, batches, completed_at IS NOT NULL FROM data_migrations WHERE version = $1 FOR UPDATE",
+		int64(migration.Version)).Scan(&cursor, &batches, &completed)
+	if err != nil {
+		return false, fmt.Errorf("failed to read progress: %w", err)
+	}
+	if completed {
+		return true, tx.Commit(ctx)
+	}
+
+	next, more, err := migration.Batch(ctx, tx, cursor)
+	if err != nil {
+		return false, fmt.Errorf("batch %d: %w", batches+1, err)
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE data_migrations
+		SET resume_after = $2, batches = batches + 1, updated_at = NOW(), completed_at = CASE WHEN $3 THEN NULL ELSE NOW() END
+		WHERE version = $1`, int64(migration.Version), next, more)
+	if err != nil {
+		return false, fmt.Errorf("failed to save progress: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit batch %d: %w", batches+1, err)
+	}
+
+	if more {
+		m.logger.Info("Data migration progress", "version", migration.Version, "name", migration.Name, "batches", batches+1, "cursor", next)
+	} else {
+		m.logger.Info("Data migration complete", "version", migration.Version, "name", migration.Name, "batches", batches+1)
+	}
+	return !more, nil
+}
+
+// DataMigrations reports the progress of every registered data migration
+func (m *Migrator) DataMigrations(ctx context.Context) ([]DataMigrationStatus, error) {
+	if len(dataMigrations) == 0 {
+		return nil, nil
+	}
+
+	progress := make(map[uint]DataMigrationStatus)
+	conn, err := pgx.Connect(ctx, m.databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close(context.WithoutCancel(ctx)) //nolint:errcheck
+
+	rows, err := conn.Query(ctx, "SELECT version, resume_after, batches, completed_at IS NOT NULL, updated_at FROM data_migrations")
+	if err == nil {
+		for rows.Next() {
+			var status DataMigrationStatus
+			var version int64
+			if err := rows.Scan(&version, &status.Cursor, &status.Batches, &status.Completed, &status.UpdatedAt); err != nil {
+				return nil, fmt.Errorf("failed to read data migrations: %w", err)
+			}
+			progress[uint(version)] = status
+		}
+		err = rows.Err()
+	}
+
+	var pgErr *pgconn.PgError
+	if err != nil && !(errors.As(err, &pgErr) && pgErr.Code == sqlStateUndefinedTable) {
+		return nil, fmt.Errorf("failed to read data migrations: %w", err)
+	}
+
+	var statuses []DataMigrationStatus
+	for version, migration := range dataMigrations {
+		status := progress[version]
+		status.Version, status.Name = version, migration.Name
+		statuses = append(statuses, status)
+	}
+	slices.SortFunc(statuses, func(a, b DataMigrationStatus) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return statuses, nil
+}
+
+// forgetDataMigrations clears the progress of data migrations that were
+// rolled back so that they run again on the way back up
+func (m *Migrator) forgetDataMigrations(ctx context.Context, version uint) error {
+	if len(dataMigrations) == 0 {
+		return nil
+	}
+
+	conn, err := pgx.Connect(ctx, m.databaseURL)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close(context.WithoutCancel(ctx)) //nolint:errcheck
+
+	_, err = conn.Exec(ctx, "DELETE FROM data_migrations WHERE version > $1", int64(version))
+	var pgErr *pgconn.PgError
+	if err != nil && !(errors.As(err, &pgErr) && pgErr.Code == sqlStateUndefinedTable) {
+		return fmt.Errorf("failed to reset data migrations: %w", err)
+	}
+	return nil
+}
+
+// migrationSource is a golang-migrate source driver over the embedded SQL
+// files and the registered data migrations. Data migrations appear as
+// comment-only files so that golang-migrate records their version.
+type migrationSource struct {
+	versions []uint
+	files    map[uint]map[source.Direction]string
+}
+
+func newMigrationSource() (*migrationSource, error) {
+	src := &migrationSource{files: make(map[uint]map[source.Direction]string)}
+
+	entries, err := fs.ReadDir(migrationsFS, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+	for _, entry := range entries {
+		parsed, err := source.Parse(entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse migration %s: %w", entry.Name(), err)
+		}
+		if src.files[parsed.Version] == nil {
+			src.files[parsed.Version] = make(map[source.Direction]string)
+			src.versions = append(src.versions, parsed.Version)
+		}
+		src.files[parsed.Version][parsed.Direction] = entry.Name()
+	}
+
+	for version := range dataMigrations {
+		if _, ok := src.files[version]; ok {
+			return nil, fmt.Errorf("data migration version %d is also used by a SQL migration", version)
+		}
+		src.versions = append(src.versions, version)
+	}
+
+	slices.Sort(src.versions)
+	return src, nil
+}
+
+// read returns the SQL of a migration. Data migrations and missing down files read as comments.
+func (s *migrationSource) read(version uint, direction source.Direction) (string, string, error) {
+	if migration, ok := dataMigrations[version]; ok {
+		if direction == source.Down {
+			return fmt.Sprintf("-- Go data migration %s: rolling back only resets its progress\n", migration.Name), migration.Name, nil
+		}
+		return fmt.Sprintf("-- Go data migration %s: runs in batches from Go code\n", migration.Name), migration.Name, nil
+	}
+
+	name, ok := s.files[version][direction]
+	if !ok {
+		return "", "", &fs.PathError{Op: "read", Path: fmt.Sprintf("%d.%s", version, direction), Err: os.ErrNotExist}
+	}
+	data, err := fs.ReadFile(migrationsFS, path.Join("migrations", name))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read migration %s: %w", name, err)
+	}
+	parsed, _ := source.Parse(name)
+	return string(data), parsed.Identifier, nil
+}
+
+func (s *migrationSource) Open(string) (source.Driver, error) {
+	return nil, errors.New("the embedded migration source cannot be opened by URL")
+}
+
+func (s *migrationSource) Close() error {
+	return nil
+}
+
+func (s *migrationSource) First() (uint, error) {
+	if len(s.versions) == 0 {
+		return 0, &fs.PathError{Op: "first", Path: "migrations", Err: os.ErrNotExist}
+	}
+	return s.versions[0], nil
+}
+
+func (s *migrationSource) Prev(version uint) (uint, error) {
+	i, ok := slices.BinarySearch(s.versions, version)
+	if !ok || i == 0 {
+		return 0, &fs.PathError{Op: "prev", Path: fmt.Sprint(version), Err: os.ErrNotExist}
+	}
+	return s.versions[i-1], nil
+}
+
+func (s *migrationSource) Next(version uint) (uint, error) {
+	i, ok := slices.BinarySearch(s.versions, version)
+	if !ok || i == len(s.versions)-1 {
+		return 0, &fs.PathError{Op: "next", Path: fmt.Sprint(version), Err: os.ErrNotExist}
+	}
+	return s.versions[i+1], nil
+}
+
+func (s *migrationSource) ReadUp(version uint) (io.ReadCloser, string, error) {
+	return s.reader(version, source.Up)
+}
+
+func (s *migrationSource) ReadDown(version uint) (io.ReadCloser, string, error) {
+	return s.reader(version, source.Down)
+}
+
+func (s *migrationSource) reader(version uint, direction source.Direction) (io.ReadCloser, string, error) {
+	body, identifier, err := s.read(version, direction)
+	if err != nil {
+		return nil, "", err
+	}
+	return io.NopCloser(strings.NewReader(body)), identifier, nil
+}
@@ -0,0 +1,38 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"testing"
+)
+
+func TestCheckVersion(t *testing.T) {
+	latest, err := LatestVersion()
+	if err != nil {
+		t.Fatalf("LatestVersion failed: %v", err)
+	}
+
+	for _, version := range []uint{0, 1, latest} {
+		if err := checkVersion(version); err != nil {
+			t.Errorf("checkVersion(%d) = %v, want nil", version, err)
+		}
+	}
+	if err := checkVersion(latest + 1); err == nil {
+		t.Errorf("checkVersion(%d) accepted a version with no migration", latest+1)
+	}
+}
+
+func TestGotoRejectsUnknownVersion(t *testing.T) {
+	latest, err := LatestVersion()
+	if err != nil {
+		t.Fatalf("LatestVersion failed: %v", err)
+	}
+
+	// The version is checked before connecting, so no database is needed
+	migrator := NewMigrator("postgres://127.0.0.1:1/none?sslmode=disable&connect_timeout=1", slog.New(slog.DiscardHandler))
+	_, err = migrator.Goto(context.Background(), latest+1)
+	if err == nil || err.Error() != fmt.Sprintf("no migration with version %d", latest+1) {
+		t.Errorf("Goto(%d) error = %v, want no migration with version %d", latest+1, err, latest+1)
+	}
+}
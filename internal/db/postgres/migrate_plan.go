@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+// PlannedMigration is a migration file that would run to reach a target version
+type PlannedMigration struct {
+	Version   uint
+	Name      string
+	Direction source.Direction
+	SQL       string
+}
+
+// Plan resolves the migrations that would run, in order, to move the database
+// from its current version to target, without executing them. Migrating down
+// to a version runs the down files of every later migration.
+func (m *Migrator) Plan(ctx context.Context, target uint) ([]PlannedMigration, error) {
+	current, err := m.planVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return planMigrations(current, target)
+}
+
+// PlanUp resolves the pending migrations that Up would apply. Like Up, it
+// never rolls back, so a database ahead of the binary has an empty plan.
+func (m *Migrator) PlanUp(ctx context.Context) ([]PlannedMigration, error) {
+	current, err := m.planVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return planUp(current)
+}
+
+// planVersion returns the version plans start from, refusing a dirty database
+func (m *Migrator) planVersion(ctx context.Context) (uint, error) {
+	current, dirty, err := m.Version(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("database is dirty at version %d, force a version before planning", current)
+	}
+	return current, nil
+}
+
+// planUp lists the migrations after current, in execution order
+func planUp(current uint) ([]PlannedMigration, error) {
+	latest, err := LatestVersion()
+	if err != nil {
+		return nil, err
+	}
+	if current >= latest {
+		return nil, nil
+	}
+	return planMigrations(current, latest)
+}
+
+// planMigrations lists the migration files between two versions in execution order
+func planMigrations(current, target uint) ([]PlannedMigration, error) {
+	if err := checkVersion(target); err != nil {
+		return nil, err
+	}
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var plan []PlannedMigration
+	if target >= current {
+		for _, migration := range migrations {
+			if migration.Version > current && migration.Version <= target {
+				plan = append(plan, PlannedMigration{Version: migration.Version, Name: migration.Name, Direction: source.Up})
+			}
+		}
+	} else {
+		for i := len(migrations) - 1; i >= 0; i-- {
+			migration := migrations[i]
+			if migration.Version > target && migration.Version <= current {
+				plan = append(plan, PlannedMigration{Version: migration.Version, Name: migration.Name, Direction: source.Down})
+			}
+		}
+	}
+
+	src, err := newMigrationSource()
+	if err != nil {
+		return nil, err
+	}
+	for i := range plan {
+		plan[i].SQL, _, err = src.read(plan[i].Version, plan[i].Direction)
+		if errors.Is(err, fs.ErrNotExist) {
+			// golang-migrate treats a missing down file as a no-op
+			plan[i].SQL, err = "-- No down migration", nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
@@ -0,0 +1,42 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+func TestPlanUp(t *testing.T) {
+	latest, err := LatestVersion()
+	if err != nil {
+		t.Fatalf("LatestVersion failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		current uint
+		want    int
+	}{
+		{"empty database", 0, int(latest)},
+		{"one behind", latest - 1, 1},
+		{"up to date", latest, 0},
+		{"ahead of the binary", latest + 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := planUp(tt.current)
+			if err != nil {
+				t.Fatalf("planUp failed: %v", err)
+			}
+			if len(plan) != tt.want {
+				t.Fatalf("got %d migrations, want %d", len(plan), tt.want)
+			}
+			for _, migration := range plan {
+				if migration.Direction != source.Up || migration.Version <= tt.current {
+					t.Errorf("planned %s migration %d from version %d", migration.Direction, migration.Version, tt.current)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS app.system_access_log;
+DROP FUNCTION IF EXISTS app.enable_tenant_rls(REGCLASS);
+DROP FUNCTION IF EXISTS app.is_system_context();
+DROP FUNCTION IF EXISTS app.current_tenant_id();
@@ -0,0 +1,61 @@
+-- Helpers for tenant isolation with Row Level Security. Tenant tables enable
+-- the standard policy with:
+--
+--   SELECT app.enable_tenant_rls('widgets');
+--
+-- and `app db verify-rls` checks that every table with a tenant_id column has.
+
+CREATE SCHEMA IF NOT EXISTS app;
+
+-- current_tenant_id returns the tenant set by WithTenantContext. It raises
+-- rather than returning NULL so that a tenant query run outside a tenant
+-- transaction fails loudly instead of silently matching no rows.
+CREATE OR REPLACE FUNCTION app.current_tenant_id() RETURNS UUID
+LANGUAGE plpgsql STABLE AS $$
+DECLARE
+    tenant TEXT := current_setting('app.current_tenant_id', true);
+BEGIN
+    IF tenant IS NULL OR tenant = '' THEN
+        RAISE EXCEPTION 'app.current_tenant_id is not set'
+            USING ERRCODE = 'insufficient_privilege',
+                  HINT = 'Run tenant queries through WithTenantContext or InTenantTransaction';
+    END IF;
+    RETURN tenant::UUID;
+END;
+$$;
+
+-- is_system_context reports whether the transaction was opened by
+-- WithSystemContext for audited cross-tenant access
+CREATE OR REPLACE FUNCTION app.is_system_context() RETURNS BOOLEAN
+LANGUAGE sql STABLE AS $$
+    SELECT coalesce(current_setting('app.system_context', true), '') = 'on'
+$$;
+
+-- enable_tenant_rls enables and forces RLS on a table with a tenant_id column
+-- and (re)creates the tenant_isolation policy. FORCE makes the policy apply to
+-- the table owner too. CASE guarantees current_tenant_id is not evaluated in
+-- a system context, where no tenant is set.
+CREATE OR REPLACE FUNCTION app.enable_tenant_rls(target REGCLASS) RETURNS VOID
+LANGUAGE plpgsql AS $$
+BEGIN
+    EXECUTE format('ALTER TABLE %s ENABLE ROW LEVEL SECURITY', target);
+    EXECUTE format('ALTER TABLE %s FORCE ROW LEVEL SECURITY', target);
+    EXECUTE format('DROP POLICY IF EXISTS tenant_isolation ON %s', target);
+    EXECUTE format(
+        'CREATE POLICY tenant_isolation ON %s
+            USING (CASE WHEN app.is_system_context() THEN true ELSE tenant_id = app.current_tenant_id() END)
+            WITH CHECK (CASE WHEN app.is_system_context() THEN true ELSE tenant_id = app.current_tenant_id() END)',
+        target
+    );
+END;
+$$;
+
+-- system_access_log records every transaction opened by WithSystemContext
+CREATE TABLE IF NOT EXISTS app.system_access_log (
+    id               BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    reason           TEXT NOT NULL,
+    database_user    TEXT NOT NULL DEFAULT session_user,
+    application_name TEXT NOT NULL DEFAULT current_setting('application_name'),
+    transaction_id   BIGINT NOT NULL DEFAULT txid_current(),
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,18 @@
+-- Events written in the same transaction as the change they describe and
+-- delivered by the outbox relay. available_at is both the retry time and the
+-- lease taken when a relay claims the event.
+CREATE TABLE outbox_events (
+    id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    topic        TEXT NOT NULL,
+    event_key    TEXT NOT NULL DEFAULT '',
+    payload      JSONB NOT NULL,
+    status       TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'dead')),
+    attempts     INTEGER NOT NULL DEFAULT 0,
+    available_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_error   TEXT,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMPTZ
+);
+
+CREATE INDEX outbox_events_pending_idx ON outbox_events (available_at) WHERE status = 'pending';
+CREATE INDEX outbox_events_delivered_idx ON outbox_events (delivered_at) WHERE status = 'delivered';
@@ -0,0 +1,25 @@
+-- Background jobs claimed by workers with FOR UPDATE SKIP LOCKED. A running
+-- job holds a lease in locked_until that its worker renews; jobs whose lease
+-- expires are claimed again.
+CREATE TABLE jobs (
+    id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    kind         TEXT NOT NULL,
+    payload      JSONB NOT NULL,
+    status       TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'failed')),
+    attempts     INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 25,
+    run_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    unique_key   TEXT,
+    locked_until TIMESTAMPTZ,
+    last_error   TEXT,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ
+);
+
+-- Only one unfinished job may hold a unique key at a time
+CREATE UNIQUE INDEX jobs_unique_key_idx ON jobs (unique_key)
+    WHERE unique_key IS NOT NULL AND status IN ('pending', 'running');
+
+CREATE INDEX jobs_pending_idx ON jobs (run_at) WHERE status = 'pending';
+CREATE INDEX jobs_running_idx ON jobs (locked_until) WHERE status = 'running';
+CREATE INDEX jobs_completed_idx ON jobs (completed_at) WHERE status = 'completed';
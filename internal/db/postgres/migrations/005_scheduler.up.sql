@@ -0,0 +1,24 @@
+-- Leader election for the scheduler. The replica holding a name's lease fires
+-- the scheduled tasks and renews the lease; another replica takes over once
+-- it expires.
+CREATE TABLE scheduler_leases (
+    name       TEXT PRIMARY KEY,
+    holder     TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+-- History of scheduled task runs. The unique constraint makes each
+-- occurrence of a schedule run at most once, even across a leader handover.
+CREATE TABLE scheduled_task_runs (
+    id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    task         TEXT NOT NULL,
+    scheduled_at TIMESTAMPTZ NOT NULL,
+    holder       TEXT NOT NULL,
+    status       TEXT NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'succeeded', 'failed', 'abandoned')),
+    error        TEXT,
+    started_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at  TIMESTAMPTZ,
+    UNIQUE (task, scheduled_at)
+);
+
+CREATE INDEX scheduled_task_runs_finished_idx ON scheduled_task_runs (finished_at);
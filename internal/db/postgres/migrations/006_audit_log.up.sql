@@ -0,0 +1,33 @@
+-- Append-only record of who changed what. Entries are written in the same
+-- transaction as the change they describe, so they commit or roll back with it.
+CREATE TABLE audit_log (
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id     UUID NOT NULL,
+    actor_id      TEXT NOT NULL,
+    actor_type    TEXT NOT NULL CHECK (actor_type IN ('user', 'system')),
+    action        TEXT NOT NULL,
+    resource_type TEXT NOT NULL,
+    resource_id   TEXT NOT NULL,
+    -- changes maps each changed field to {"before": ..., "after": ...}
+    changes       JSONB NOT NULL DEFAULT '{}',
+    request_id    TEXT,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX audit_log_tenant_created_idx ON audit_log (tenant_id, created_at DESC, id DESC);
+CREATE INDEX audit_log_resource_idx ON audit_log (tenant_id, resource_type, resource_id, created_at DESC);
+
+SELECT app.enable_tenant_rls('audit_log');
+
+-- Reject edits to the trail, including by the table owner
+CREATE OR REPLACE FUNCTION app.reject_audit_log_change() RETURNS TRIGGER
+LANGUAGE plpgsql AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only'
+        USING ERRCODE = 'insufficient_privilege';
+END;
+$$;
+
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION app.reject_audit_log_change();
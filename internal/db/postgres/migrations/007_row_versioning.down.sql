@@ -0,0 +1,2 @@
+DROP FUNCTION IF EXISTS app.enable_row_versioning(REGCLASS);
+DROP FUNCTION IF EXISTS app.touch_row();
@@ -0,0 +1,43 @@
+-- Conventions for entity tables: soft deletes and optimistic concurrency.
+-- Entity tables declare the columns themselves, so that sqlc sees them:
+--
+--   CREATE TABLE widgets (
+--       id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+--       tenant_id  UUID NOT NULL,
+--       name       TEXT NOT NULL,
+--       version    BIGINT NOT NULL DEFAULT 1,
+--       created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+--       updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+--       deleted_at TIMESTAMPTZ
+--   );
+--   CREATE UNIQUE INDEX widgets_name_key ON widgets (tenant_id, name) WHERE deleted_at IS NULL;
+--   SELECT app.enable_row_versioning('widgets');
+--
+-- Queries filter on deleted_at IS NULL, soft delete by setting deleted_at,
+-- and update with WHERE id = $1 AND version = $2 so that a stale write
+-- changes no rows.
+
+-- touch_row bumps version and updated_at on every update. The new version
+-- is always derived from the stored one, so writers cannot skip or reuse one.
+CREATE OR REPLACE FUNCTION app.touch_row() RETURNS TRIGGER
+LANGUAGE plpgsql AS $$
+BEGIN
+    NEW.version := OLD.version + 1;
+    NEW.updated_at := NOW();
+    RETURN NEW;
+END;
+$$;
+
+-- enable_row_versioning (re)creates the touch_row trigger on a table with
+-- version and updated_at columns
+CREATE OR REPLACE FUNCTION app.enable_row_versioning(target REGCLASS) RETURNS VOID
+LANGUAGE plpgsql AS $$
+BEGIN
+    EXECUTE format('DROP TRIGGER IF EXISTS touch_row ON %s', target);
+    EXECUTE format(
+        'CREATE TRIGGER touch_row BEFORE UPDATE ON %s
+            FOR EACH ROW EXECUTE FUNCTION app.touch_row()',
+        target
+    );
+END;
+$$;
@@ -0,0 +1,24 @@
+CREATE OR REPLACE FUNCTION app.current_tenant_id() RETURNS UUID
+LANGUAGE plpgsql STABLE AS $$
+DECLARE
+    tenant TEXT := current_setting('app.current_tenant_id', true);
+BEGIN
+    IF tenant IS NULL OR tenant = '' THEN
+        RAISE EXCEPTION 'app.current_tenant_id is not set'
+            USING ERRCODE = 'insufficient_privilege',
+                  HINT = 'Run tenant queries through WithTenantContext or InTenantTransaction';
+    END IF;
+    RETURN tenant::UUID;
+END;
+$$;
+
+DROP FUNCTION IF EXISTS app.tenant_not_set();
+
+CREATE OR REPLACE FUNCTION app.is_system_context() RETURNS BOOLEAN
+LANGUAGE sql STABLE AS $$
+    SELECT coalesce(current_setting('app.system_context', true), '') = 'on'
+$$;
+
+DROP FUNCTION IF EXISTS app.system_access_logged();
+
+DROP INDEX IF EXISTS app.system_access_log_transaction_idx;
@@ -0,0 +1,51 @@
+-- Tighten tenant isolation helpers.
+--
+-- app.system_context alone granted cross-tenant access, and any session can
+-- set a custom setting. The bypass now also requires the app.system_access_log
+-- row that WithSystemContext writes in the same transaction, so there is no
+-- cross-tenant access without an audit record.
+
+CREATE INDEX IF NOT EXISTS system_access_log_transaction_idx ON app.system_access_log (transaction_id);
+
+-- system_access_logged reports whether the transaction has written its
+-- app.system_access_log row. txid_current_if_assigned never assigns an ID,
+-- which a hot standby would reject; a transaction that wrote the row has one.
+CREATE OR REPLACE FUNCTION app.system_access_logged() RETURNS BOOLEAN
+LANGUAGE sql STABLE AS $$
+    SELECT EXISTS (
+        SELECT 1 FROM app.system_access_log
+        WHERE transaction_id = txid_current_if_assigned()
+    )
+$$;
+
+-- is_system_context only looks up the log once the setting is on, so it stays
+-- inlinable and tenant queries pay nothing for the check
+CREATE OR REPLACE FUNCTION app.is_system_context() RETURNS BOOLEAN
+LANGUAGE sql STABLE AS $$
+    SELECT CASE
+        WHEN coalesce(current_setting('app.system_context', true), '') <> 'on' THEN false
+        ELSE app.system_access_logged()
+    END
+$$;
+
+-- tenant_not_set raises the error for a tenant query without a tenant. It is
+-- STABLE so that current_tenant_id can still be inlined.
+CREATE OR REPLACE FUNCTION app.tenant_not_set() RETURNS UUID
+LANGUAGE plpgsql STABLE AS $$
+BEGIN
+    RAISE EXCEPTION 'app.current_tenant_id is not set'
+        USING ERRCODE = 'insufficient_privilege',
+              HINT = 'Run tenant queries through WithTenantContext or InTenantTransaction';
+END;
+$$;
+
+-- current_tenant_id is a SQL function so that the planner inlines it into
+-- policies and evaluates the setting once per query instead of once per row.
+-- coalesce only calls tenant_not_set when no tenant is set.
+CREATE OR REPLACE FUNCTION app.current_tenant_id() RETURNS UUID
+LANGUAGE sql STABLE AS $$
+    SELECT coalesce(
+        nullif(current_setting('app.current_tenant_id', true), '')::UUID,
+        app.tenant_not_set()
+    )
+$$;
@@ -0,0 +1,409 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Notification is a message received on a LISTEN/NOTIFY channel
+type Notification struct {
+	Channel string
+	Payload string
+	// PID is the backend process that sent the notification
+	PID uint32
+}
+
+var (
+	// ErrSlowSubscriber ends a subscription whose buffer filled up, as the
+	// notification that did not fit is lost
+	ErrSlowSubscriber = errors.New("subscriber fell behind")
+	// ErrListenerConnectionLost ends the subscriptions of a listener
+	// connection that dropped, as notifications sent until it is
+	// re-established are lost
+	ErrListenerConnectionLost = errors.New("listener connection was lost")
+)
+
+// Subscription delivers the notifications sent to a channel. It never skips a
+// notification silently: if one is lost, because the subscriber's buffer is
+// full or the connection drops, the subscription ends and Err reports why.
+// Subscribers that must not miss changes should then resynchronise from the
+// database and subscribe again.
+type Subscription struct {
+	channel  string
+	ch       chan Notification
+	listener *listener
+
+	// err is set, under the listener's mu, before ch is closed
+	err error
+
+	ready     chan struct{}
+	readyOnce sync.Once
+	closeOnce sync.Once
+	// stop unregisters the close on context cancellation, guarded by the listener's mu
+	stop func() bool
+}
+
+// Notifications returns the channel notifications are delivered on. It is
+// closed when the subscription ends, after which Err reports why.
+func (s *Subscription) Notifications() <-chan Notification {
+	return s.ch
+}
+
+// Err returns ErrSlowSubscriber or ErrListenerConnectionLost once the
+// subscription has ended with notifications lost, and nil otherwise
+func (s *Subscription) Err() error {
+	s.listener.mu.Lock()
+	defer s.listener.mu.Unlock()
+	return s.err
+}
+
+// Close ends the subscription. The connection stops listening on the channel
+// once it has no subscribers left.
+func (s *Subscription) Close() {
+	s.closeOnce.Do(func() {
+		s.listener.remove(s)
+	})
+}
+
+func (s *Subscription) markReady() {
+	s.readyOnce.Do(func() { close(s.ready) })
+}
+
+// isReady reports whether the channel was being listened to for the subscription
+func (s *Subscription) isReady() bool {
+	select {
+	case <-s.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// subscriptionBuffer is the number of notifications held for a subscriber
+// that is not keeping up before it is ended with ErrSlowSubscriber
+const subscriptionBuffer = 64
+
+// Subscribe listens on a channel and delivers its notifications until ctx is
+// cancelled or the subscription is closed. All subscriptions share a single
+// connection outside the pool, which is re-established, and its channels
+// listened to again, whenever it is lost. Subscribe returns once the channel
+// is being listened to, so it blocks while the database is unreachable.
+func (d *DB) Subscribe(ctx context.Context, channel string) (*Subscription, error) {
+	if channel == "" {
+		return nil, fmt.Errorf("channel name is required")
+	}
+
+	sub := &Subscription{
+		channel:  channel,
+		ch:       make(chan Notification, subscriptionBuffer),
+		listener: d.listener,
+		ready:    make(chan struct{}),
+	}
+	if err := d.listener.add(ctx, sub); err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-sub.ready:
+		return sub, nil
+	case <-ctx.Done():
+		sub.Close()
+		return nil, fmt.Errorf("failed to listen on %s: %w", channel, ctx.Err())
+	}
+}
+
+// Notify sends a notification on a channel. Within a transaction it is
+// delivered when the transaction commits, and not at all if it rolls back.
+// Payloads are limited to 8000 bytes, so large changes should send an ID
+// for subscribers to look up.
+func (d *DB) Notify(ctx context.Context, channel, payload string) error {
+	if _, err := d.conn(ctx).Exec(ctx, "SELECT pg_notify($1, $2)", channel, payload); err != nil {
+		return fmt.Errorf("failed to notify %s: %w", channel, err)
+	}
+	return nil
+}
+
+// listener owns the dedicated LISTEN connection and fans notifications out to
+// subscriptions. Its loop starts with the first subscription.
+type listener struct {
+	connConfig *pgx.ConnConfig
+	logger     logger
+
+	mu   sync.Mutex
+	subs map[string]map[*Subscription]struct{}
+	// dirty is set when the channels to listen on have changed and interrupt
+	// wakes the loop from waiting for a notification to apply them
+	dirty     bool
+	interrupt context.CancelFunc
+	started   bool
+	closed    bool
+	stop      context.CancelFunc
+	done      chan struct{}
+}
+
+func newListener(connConfig *pgx.ConnConfig, logger logger) *listener {
+	return &listener{
+		connConfig: connConfig,
+		logger:     logger,
+		subs:       make(map[string]map[*Subscription]struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// add registers a subscription that ends when ctx is done, starting the loop if needed
+func (l *listener) add(ctx context.Context, sub *Subscription) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return fmt.Errorf("failed to subscribe to %s: database is closed", sub.channel)
+	}
+	if l.subs[sub.channel] == nil {
+		l.subs[sub.channel] = make(map[*Subscription]struct{})
+	}
+	l.subs[sub.channel][sub] = struct{}{}
+	sub.stop = context.AfterFunc(ctx, sub.Close)
+	l.changed()
+
+	if !l.started {
+		l.started = true
+		ctx, stop := context.WithCancel(context.Background())
+		l.stop = stop
+		go l.run(ctx)
+	}
+	return nil
+}
+
+// remove unregisters a subscription and closes its channel
+func (l *listener) remove(sub *Subscription) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.end(sub, nil)
+}
+
+// end unregisters a subscription, recording err, and closes its channel. The
+// caller must hold mu.
+func (l *listener) end(sub *Subscription, err error) {
+	if _, ok := l.subs[sub.channel][sub]; !ok {
+		return
+	}
+	sub.stop()
+	delete(l.subs[sub.channel], sub)
+	if len(l.subs[sub.channel]) == 0 {
+		delete(l.subs, sub.channel)
+	}
+	sub.err = err
+	close(sub.ch)
+	l.changed()
+}
+
+// disconnected ends the subscriptions that were being listened to on a
+// connection that was lost
+func (l *listener) disconnected() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, subs := range l.subs {
+		for sub := range subs {
+			if sub.isReady() {
+				l.end(sub, ErrListenerConnectionLost)
+			}
+		}
+	}
+}
+
+// changed wakes the loop to update the channels it listens on. The caller must hold mu.
+func (l *listener) changed() {
+	l.dirty = true
+	if l.interrupt != nil {
+		l.interrupt()
+	}
+}
+
+// close stops the loop and ends every subscription
+func (l *listener) close() {
+	l.mu.Lock()
+	l.closed = true
+	started := l.started
+	if started {
+		l.stop()
+	}
+	l.mu.Unlock()
+
+	if started {
+		<-l.done
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, subs := range l.subs {
+		for sub := range subs {
+			sub.stop()
+			close(sub.ch)
+		}
+	}
+	l.subs = make(map[string]map[*Subscription]struct{})
+}
+
+// run keeps the connection open and listening until ctx is cancelled
+func (l *listener) run(ctx context.Context) {
+	defer close(l.done)
+
+	const (
+		initialBackoff = 100 * time.Millisecond
+		maxBackoff     = 10 * time.Second
+		// pingInterval bounds how long a silently dropped connection goes unnoticed
+		pingInterval = 30 * time.Second
+	)
+
+	var conn *pgx.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close(context.Background()) //nolint:errcheck
+		}
+	}()
+
+	listening := make(map[string]bool)
+	backoff := initialBackoff
+	reconnecting := false
+
+	for {
+		if conn == nil {
+			var err error
+			conn, err = pgx.ConnectConfig(ctx, l.connConfig.Copy())
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				l.logger.Error("failed to connect listener", "retry_in", backoff, "error", err)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+				}
+				backoff = min(backoff*2, maxBackoff)
+				continue
+			}
+			if reconnecting {
+				l.logger.Info("Reconnected listener")
+			}
+			clear(listening)
+			backoff = initialBackoff
+		}
+
+		if err := l.sync(ctx, conn, listening); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			l.logger.Error("failed to update listened channels", "error", err)
+			conn.Close(context.Background()) //nolint:errcheck
+			conn, reconnecting = nil, true
+			l.disconnected()
+			continue
+		}
+
+		// Wait for a notification unless the channels changed during sync
+		waitCtx, cancel := context.WithTimeout(ctx, pingInterval)
+		l.mu.Lock()
+		if l.dirty {
+			l.mu.Unlock()
+			cancel()
+			continue
+		}
+		l.interrupt = cancel
+		l.mu.Unlock()
+
+		notification, err := conn.WaitForNotification(waitCtx)
+
+		l.mu.Lock()
+		l.interrupt = nil
+		l.mu.Unlock()
+		cancel()
+
+		if err == nil {
+			l.dispatch(Notification{Channel: notification.Channel, Payload: notification.Payload, PID: notification.PID})
+			continue
+		}
+
+		switch {
+		case ctx.Err() != nil:
+			return
+		case errors.Is(waitCtx.Err(), context.DeadlineExceeded):
+			if err = conn.Ping(ctx); err == nil {
+				continue
+			}
+		case waitCtx.Err() != nil:
+			// Interrupted to apply a subscription change
+			continue
+		}
+
+		l.logger.Error("Lost listener connection", "error", err)
+		conn.Close(context.Background()) //nolint:errcheck
+		conn, reconnecting = nil, true
+		l.disconnected()
+	}
+}
+
+// sync listens on channels that gained subscribers and stops listening on
+// those that lost them, then releases the subscribers waiting on them
+func (l *listener) sync(ctx context.Context, conn *pgx.Conn, listening map[string]bool) error {
+	l.mu.Lock()
+	l.dirty = false
+	wanted := make(map[string]bool, len(l.subs))
+	for channel := range l.subs {
+		wanted[channel] = true
+	}
+	l.mu.Unlock()
+
+	for channel := range wanted {
+		if listening[channel] {
+			continue
+		}
+		if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", channel, err)
+		}
+		listening[channel] = true
+	}
+	for channel := range listening {
+		if wanted[channel] {
+			continue
+		}
+		if _, err := conn.Exec(ctx, "UNLISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return fmt.Errorf("failed to stop listening on %s: %w", channel, err)
+		}
+		delete(listening, channel)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for channel, subs := range l.subs {
+		if !listening[channel] {
+			continue
+		}
+		for sub := range subs {
+			sub.markReady()
+		}
+	}
+	return nil
+}
+
+// dispatch delivers a notification to the channel's subscribers without
+// blocking, ending the subscription of any subscriber whose buffer is full
+func (l *listener) dispatch(notification Notification) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for sub := range l.subs[notification.Channel] {
+		select {
+		case sub.ch <- notification:
+		default:
+			l.logger.Error("Ending subscription of slow subscriber", "channel", notification.Channel)
+			l.end(sub, ErrSlowSubscriber)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package postgres_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/travisbale/go-template/internal/db/postgres"
+	"github.com/travisbale/go-template/internal/testutil"
+)
+
+func TestSlowSubscriberIsEnded(t *testing.T) {
+	db := testutil.NewDB(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	sub, err := db.Subscribe(ctx, "widgets_changed")
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer sub.Close()
+
+	// Send more notifications than the subscription buffers without reading
+	const sent = 100
+	for i := range sent {
+		if err := db.Notify(ctx, "widgets_changed", strconv.Itoa(i)); err != nil {
+			t.Fatalf("Notify failed: %v", err)
+		}
+	}
+
+	// The subscription ends once a notification does not fit, before any is read
+	for sub.Err() == nil {
+		select {
+		case <-ctx.Done():
+			t.Fatal("subscription did not end")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if !errors.Is(sub.Err(), postgres.ErrSlowSubscriber) {
+		t.Errorf("got error %v, want %v", sub.Err(), postgres.ErrSlowSubscriber)
+	}
+
+	// The buffered notifications are still delivered before the channel closes
+	received := 0
+	for range sub.Notifications() {
+		received++
+	}
+	if received == 0 || received >= sent {
+		t.Errorf("received %d notifications, want the buffered ones", received)
+	}
+}
@@ -0,0 +1,172 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/travisbale/go-template/internal/db/postgres/internal/sqlc"
+)
+
+// ErrLeaseLost means a claimed outbox event or job was claimed again after its
+// lease expired, so the caller no longer owns it and its update was skipped
+var ErrLeaseLost = errors.New("lease expired and another claim took over")
+
+// OutboxEvent is an event claimed from the outbox for delivery
+type OutboxEvent struct {
+	ID        uuid.UUID
+	Topic     string
+	Key       string
+	Payload   json.RawMessage
+	Attempts  int
+	CreatedAt time.Time
+}
+
+// EnqueueEvent writes an event to the outbox using the queries of the
+// enclosing transaction, so that it is only published if the transaction
+// commits. Use it inside WithTransaction and WithTenantContext:
+//
+//	err := db.WithTransaction(ctx, func(q *sqlc.Queries) error {
+//		if err := q.CreateOrder(ctx, params); err != nil {
+//			return err
+//		}
+//		return postgres.EnqueueEvent(ctx, q, "orders.created", orderID.String(), order)
+//	})
+//
+// The key identifies the entity the event is about, for publishers that
+// partition or order by key. The payload is encoded as JSON.
+func EnqueueEvent(ctx context.Context, q *sqlc.Queries, topic, key string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode event payload: %w", err)
+	}
+
+	err = q.EnqueueOutboxEvent(ctx, sqlc.EnqueueOutboxEventParams{
+		Topic:    topic,
+		EventKey: key,
+		Payload:  data,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enqueue event: %w", err)
+	}
+	return nil
+}
+
+// EnqueueEvent writes an event to the outbox in the transaction carried by
+// ctx, for use inside InTransaction and InTenantTransaction
+func (d *DB) EnqueueEvent(ctx context.Context, topic, key string, payload any) error {
+	return EnqueueEvent(ctx, d.queries(ctx), topic, key, payload)
+}
+
+// ClaimOutboxEvents leases up to limit pending events to the caller. Rows
+// locked by another relay are skipped, and an event whose lease expires
+// without being marked is claimed again, so delivery is at-least-once.
+func (d *DB) ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]OutboxEvent, error) {
+	rows, err := d.Queries().ClaimOutboxEvents(ctx, sqlc.ClaimOutboxEventsParams{
+		LeaseSeconds: lease.Seconds(),
+		BatchSize:    int32(limit),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+
+	events := make([]OutboxEvent, len(rows))
+	for i, row := range rows {
+		events[i] = OutboxEvent{
+			ID:        row.ID,
+			Topic:     row.Topic,
+			Key:       row.EventKey,
+			Payload:   row.Payload,
+			Attempts:  int(row.Attempts),
+			CreatedAt: row.CreatedAt,
+		}
+	}
+	return events, nil
+}
+
+// ExtendOutboxLease renews the lease on claimed events and returns the IDs of
+// those the caller still holds. Events claimed again by another relay since
+// are left alone.
+func (d *DB) ExtendOutboxLease(ctx context.Context, events []OutboxEvent, lease time.Duration) ([]uuid.UUID, error) {
+	params := sqlc.ExtendOutboxLeaseParams{
+		LeaseSeconds: lease.Seconds(),
+		Ids:          make([]uuid.UUID, len(events)),
+		Attempts:     make([]int32, len(events)),
+	}
+	for i, event := range events {
+		params.Ids[i] = event.ID
+		params.Attempts[i] = int32(event.Attempts)
+	}
+
+	held, err := d.Queries().ExtendOutboxLease(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extend outbox lease: %w", err)
+	}
+	return held, nil
+}
+
+// MarkOutboxEventDelivered records that an event was published. It returns
+// ErrLeaseLost if the event has been claimed again since.
+func (d *DB) MarkOutboxEventDelivered(ctx context.Context, event OutboxEvent) error {
+	updated, err := d.Queries().MarkOutboxEventDelivered(ctx, sqlc.MarkOutboxEventDeliveredParams{
+		ID:       event.ID,
+		Attempts: int32(event.Attempts),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox event delivered: %w", err)
+	}
+	return leaseHeld(updated)
+}
+
+// RetryOutboxEvent schedules another delivery attempt after a failure. It
+// returns ErrLeaseLost if the event has been claimed again since.
+func (d *DB) RetryOutboxEvent(ctx context.Context, event OutboxEvent, at time.Time, cause error) error {
+	message := cause.Error()
+	updated, err := d.Queries().RetryOutboxEvent(ctx, sqlc.RetryOutboxEventParams{
+		ID:          event.ID,
+		AvailableAt: at,
+		LastError:   &message,
+		Attempts:    int32(event.Attempts),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reschedule outbox event: %w", err)
+	}
+	return leaseHeld(updated)
+}
+
+// DeadLetterOutboxEvent stops delivery of an event that keeps failing. It
+// stays in the table with its last error for inspection and manual replay.
+// It returns ErrLeaseLost if the event has been claimed again since.
+func (d *DB) DeadLetterOutboxEvent(ctx context.Context, event OutboxEvent, cause error) error {
+	message := cause.Error()
+	updated, err := d.Queries().DeadLetterOutboxEvent(ctx, sqlc.DeadLetterOutboxEventParams{
+		ID:        event.ID,
+		LastError: &message,
+		Attempts:  int32(event.Attempts),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to dead-letter outbox event: %w", err)
+	}
+	return leaseHeld(updated)
+}
+
+// leaseHeld converts the row count of an update fenced on the claimed attempt
+// into ErrLeaseLost when the row had been claimed again
+func leaseHeld(updated int64) error {
+	if updated == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// PurgeDeliveredOutboxEvents deletes events delivered before the given time
+func (d *DB) PurgeDeliveredOutboxEvents(ctx context.Context, before time.Time) (int64, error) {
+	deleted, err := d.Queries().PurgeDeliveredOutboxEvents(ctx, &before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge outbox events: %w", err)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,158 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PoolConfig configures the connection pool and the session of every
+// connection in it. Zero values keep the value from the database URL
+// (pool_max_conns, pool_min_conns, ...) or fall back to the defaults below.
+type PoolConfig struct {
+	MaxConns          int32
+	MinConns          int32
+	MaxConnLifetime   time.Duration
+	MaxConnIdleTime   time.Duration
+	HealthCheckPeriod time.Duration
+
+	// ApplicationName identifies the service in pg_stat_activity and server logs
+	ApplicationName string
+	// StatementTimeout aborts statements that run longer than this
+	StatementTimeout time.Duration
+	// SearchPath sets the schemas searched for unqualified names
+	SearchPath string
+	// CustomTypes are loaded and registered on every connection so that
+	// enums, domains and composite types can be scanned, e.g. "user_status"
+	// and "_user_status" for an array of it
+	CustomTypes []string
+}
+
+// defaultPoolConfig applies when neither PoolConfig nor the URL sets a value
+var defaultPoolConfig = PoolConfig{
+	MaxConns:          25,
+	MinConns:          5,
+	MaxConnLifetime:   time.Hour,
+	MaxConnIdleTime:   30 * time.Minute,
+	HealthCheckPeriod: time.Minute,
+}
+
+// newPool creates a connection pool without waiting for a connection
+func newPool(ctx context.Context, databaseURL string, options *options) (*pgxpool.Pool, error) {
+	config, err := pgxpool.ParseConfig(databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database URL: %w", err)
+	}
+
+	urlParams, err := poolParams(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// Configure connection pool, letting explicit settings win over the URL
+	settings := options.pool
+	config.MaxConns = poolSetting(urlParams, "pool_max_conns", settings.MaxConns, defaultPoolConfig.MaxConns, config.MaxConns)
+	config.MinConns = poolSetting(urlParams, "pool_min_conns", settings.MinConns, defaultPoolConfig.MinConns, config.MinConns)
+	config.MaxConnLifetime = poolSetting(urlParams, "pool_max_conn_lifetime", settings.MaxConnLifetime, defaultPoolConfig.MaxConnLifetime, config.MaxConnLifetime)
+	config.MaxConnIdleTime = poolSetting(urlParams, "pool_max_conn_idle_time", settings.MaxConnIdleTime, defaultPoolConfig.MaxConnIdleTime, config.MaxConnIdleTime)
+	config.HealthCheckPeriod = poolSetting(urlParams, "pool_health_check_period", settings.HealthCheckPeriod, defaultPoolConfig.HealthCheckPeriod, config.HealthCheckPeriod)
+
+	if config.MinConns > config.MaxConns {
+		return nil, fmt.Errorf("minimum connections (%d) exceeds maximum connections (%d)", config.MinConns, config.MaxConns)
+	}
+
+	config.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		if err := configureSession(ctx, conn, settings); err != nil {
+			return err
+		}
+		for _, hook := range options.afterConnect {
+			if err := hook(ctx, conn); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Discard connections that are broken or were returned mid-transaction
+	// rather than handing them to the next caller
+	config.PrepareConn = func(ctx context.Context, conn *pgx.Conn) (bool, error) {
+		if conn.IsClosed() || conn.PgConn().TxStatus() != 'I' {
+			return false, nil
+		}
+		return true, nil
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connection pool: %w", err)
+	}
+
+	return pool, nil
+}
+
+// poolParams returns the pool_* parameters set by a database URL or
+// key=value DSN. pgxpool.ParseConfig consumes them, so the connection string
+// is parsed again with pgconn, which keeps them as runtime parameters.
+func poolParams(databaseURL string) (map[string]string, error) {
+	config, err := pgconn.ParseConfig(databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database URL: %w", err)
+	}
+
+	params := make(map[string]string)
+	for key, value := range config.RuntimeParams {
+		if strings.HasPrefix(key, "pool_") {
+			params[key] = value
+		}
+	}
+	return params, nil
+}
+
+// poolSetting resolves a pool setting: explicit configuration first, then the
+// database URL, then the template default
+func poolSetting[T comparable](urlParams map[string]string, param string, configured, fallback, parsed T) T {
+	var zero T
+	if configured != zero {
+		return configured
+	}
+	if _, ok := urlParams[param]; ok {
+		return parsed
+	}
+	return fallback
+}
+
+// configureSession applies session settings and registers custom types on a new connection
+func configureSession(ctx context.Context, conn *pgx.Conn, settings PoolConfig) error {
+	params := map[string]string{}
+	if settings.ApplicationName != "" {
+		params["application_name"] = settings.ApplicationName
+	}
+	if settings.StatementTimeout > 0 {
+		params["statement_timeout"] = fmt.Sprintf("%dms", settings.StatementTimeout.Milliseconds())
+	}
+	if settings.SearchPath != "" {
+		params["search_path"] = settings.SearchPath
+	}
+
+	for name, value := range params {
+		if _, err := conn.Exec(ctx, "SELECT set_config($1, $2, false)", name, value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", name, err)
+		}
+	}
+
+	// Types are loaded after search_path so that unqualified names resolve
+	if len(settings.CustomTypes) > 0 {
+		types, err := conn.LoadTypes(ctx, settings.CustomTypes)
+		if err != nil {
+			return fmt.Errorf("failed to load custom types: %w", err)
+		}
+		conn.TypeMap().RegisterTypes(types)
+	}
+
+	return nil
+}
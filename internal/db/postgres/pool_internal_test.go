@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"testing"
+)
+
+func TestPoolSettingFromConnectionString(t *testing.T) {
+	tests := []struct {
+		name        string
+		databaseURL string
+		want        int32
+	}{
+		{"url parameter", "postgres://localhost/app?pool_max_conns=7", 7},
+		{"dsn parameter", "host=localhost dbname=app pool_max_conns=7", 7},
+		{"unset", "postgres://localhost/app", defaultPoolConfig.MaxConns},
+		{"similar parameter", "postgres://localhost/app?x_pool_max_conns=7", defaultPoolConfig.MaxConns},
+		{"value of another parameter", "postgres://localhost/app?application_name=pool_max_conns=7", defaultPoolConfig.MaxConns},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, err := newPool(t.Context(), tt.databaseURL, &options{pool: PoolConfig{MinConns: 1}})
+			if err != nil {
+				t.Fatalf("newPool failed: %v", err)
+			}
+			defer pool.Close()
+
+			if got := pool.Config().MaxConns; got != tt.want {
+				t.Errorf("got MaxConns %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPoolSettingPrefersConfiguration(t *testing.T) {
+	pool, err := newPool(t.Context(), "postgres://localhost/app?pool_max_conns=7", &options{pool: PoolConfig{MaxConns: 3, MinConns: 1}})
+	if err != nil {
+		t.Fatalf("newPool failed: %v", err)
+	}
+	defer pool.Close()
+
+	if got := pool.Config().MaxConns; got != 3 {
+		t.Errorf("got MaxConns %d, want the configured 3", got)
+	}
+}
@@ -0,0 +1,18 @@
+-- name: InsertAuditEntry :one
+INSERT INTO audit_log (tenant_id, actor_id, actor_type, action, resource_type, resource_id, changes, request_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
+
+-- name: ListAuditEntries :many
+SELECT * FROM audit_log
+WHERE (sqlc.narg('actor_id')::text IS NULL OR actor_id = sqlc.narg('actor_id'))
+  AND (sqlc.narg('action')::text IS NULL OR action = sqlc.narg('action'))
+  AND (sqlc.narg('resource_type')::text IS NULL OR resource_type = sqlc.narg('resource_type'))
+  AND (sqlc.narg('resource_id')::text IS NULL OR resource_id = sqlc.narg('resource_id'))
+  AND (sqlc.narg('request_id')::text IS NULL OR request_id = sqlc.narg('request_id'))
+  AND (sqlc.narg('created_from')::timestamptz IS NULL OR created_at >= sqlc.narg('created_from'))
+  AND (sqlc.narg('created_until')::timestamptz IS NULL OR created_at < sqlc.narg('created_until'))
+  AND (sqlc.narg('after_created_at')::timestamptz IS NULL
+       OR (created_at, id) < (sqlc.narg('after_created_at'), sqlc.narg('after_id')::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
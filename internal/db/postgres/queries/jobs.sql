@@ -0,0 +1,56 @@
+-- name: EnqueueJob :one
+INSERT INTO jobs (kind, payload, run_at, unique_key, max_attempts)
+VALUES (
+    sqlc.arg('kind'),
+    sqlc.arg('payload'),
+    COALESCE(sqlc.narg('run_at')::timestamptz, NOW()),
+    sqlc.narg('unique_key'),
+    sqlc.arg('max_attempts')
+)
+ON CONFLICT (unique_key) WHERE unique_key IS NOT NULL AND status IN ('pending', 'running') DO NOTHING
+RETURNING id;
+
+-- name: ClaimJobs :many
+UPDATE jobs
+SET status = 'running',
+    attempts = attempts + 1,
+    locked_until = NOW() + make_interval(secs => sqlc.arg('lease_seconds')::float8)
+WHERE id IN (
+    SELECT id FROM jobs
+    WHERE kind = ANY(sqlc.arg('kinds')::text[])
+      AND ((status = 'pending' AND run_at <= NOW())
+        OR (status = 'running' AND locked_until < NOW() AND attempts < max_attempts))
+    ORDER BY run_at
+    LIMIT sqlc.arg('batch_size')::int
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: ExtendJobLease :execrows
+UPDATE jobs
+SET locked_until = NOW() + make_interval(secs => sqlc.arg('lease_seconds')::float8)
+WHERE id = sqlc.arg('id') AND status = 'running' AND attempts = sqlc.arg('attempts');
+
+-- name: CompleteJob :execrows
+UPDATE jobs
+SET status = 'completed', completed_at = NOW(), locked_until = NULL, last_error = NULL
+WHERE id = $1 AND status = 'running' AND attempts = $2;
+
+-- name: RetryJob :execrows
+UPDATE jobs
+SET status = 'pending', run_at = $2, last_error = $3, locked_until = NULL
+WHERE id = $1 AND status = 'running' AND attempts = $4;
+
+-- name: FailJob :execrows
+UPDATE jobs
+SET status = 'failed', last_error = $2, locked_until = NULL
+WHERE id = $1 AND status = 'running' AND attempts = $3;
+
+-- name: FailAbandonedJobs :execrows
+UPDATE jobs
+SET status = 'failed', last_error = 'lease expired on the final attempt', locked_until = NULL
+WHERE status = 'running' AND locked_until < NOW() AND attempts >= max_attempts;
+
+-- name: PurgeCompletedJobs :execrows
+DELETE FROM jobs
+WHERE status = 'completed' AND completed_at < $1;
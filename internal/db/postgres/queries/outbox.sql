@@ -0,0 +1,44 @@
+-- name: EnqueueOutboxEvent :exec
+INSERT INTO outbox_events (topic, event_key, payload)
+VALUES ($1, $2, $3);
+
+-- name: ClaimOutboxEvents :many
+UPDATE outbox_events
+SET attempts = attempts + 1,
+    available_at = NOW() + make_interval(secs => sqlc.arg('lease_seconds')::float8)
+WHERE id IN (
+    SELECT id FROM outbox_events
+    WHERE status = 'pending' AND available_at <= NOW()
+    ORDER BY created_at
+    LIMIT sqlc.arg('batch_size')::int
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: ExtendOutboxLease :many
+UPDATE outbox_events
+SET available_at = NOW() + make_interval(secs => sqlc.arg('lease_seconds')::float8)
+WHERE status = 'pending'
+  AND (id, attempts) IN (
+    SELECT unnest(sqlc.arg('ids')::uuid[]), unnest(sqlc.arg('attempts')::int[])
+  )
+RETURNING id;
+
+-- name: MarkOutboxEventDelivered :execrows
+UPDATE outbox_events
+SET status = 'delivered', delivered_at = NOW(), last_error = NULL
+WHERE id = $1 AND status = 'pending' AND attempts = $2;
+
+-- name: RetryOutboxEvent :execrows
+UPDATE outbox_events
+SET available_at = $2, last_error = $3
+WHERE id = $1 AND status = 'pending' AND attempts = $4;
+
+-- name: DeadLetterOutboxEvent :execrows
+UPDATE outbox_events
+SET status = 'dead', last_error = $2
+WHERE id = $1 AND status = 'pending' AND attempts = $3;
+
+-- name: PurgeDeliveredOutboxEvents :execrows
+DELETE FROM outbox_events
+WHERE status = 'delivered' AND delivered_at < $1;
@@ -0,0 +1,36 @@
+-- name: AcquireSchedulerLease :one
+INSERT INTO scheduler_leases (name, holder, expires_at)
+VALUES (sqlc.arg('name'), sqlc.arg('holder'), NOW() + make_interval(secs => sqlc.arg('lease_seconds')::float8))
+ON CONFLICT (name) DO UPDATE
+SET holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at
+WHERE scheduler_leases.holder = EXCLUDED.holder OR scheduler_leases.expires_at < NOW()
+RETURNING holder;
+
+-- name: ReleaseSchedulerLease :exec
+DELETE FROM scheduler_leases
+WHERE name = $1 AND holder = $2;
+
+-- name: LatestScheduledRuns :many
+SELECT DISTINCT ON (task) task, scheduled_at
+FROM scheduled_task_runs
+ORDER BY task, scheduled_at DESC;
+
+-- name: StartScheduledRun :one
+INSERT INTO scheduled_task_runs (task, scheduled_at, holder)
+VALUES ($1, $2, $3)
+ON CONFLICT (task, scheduled_at) DO NOTHING
+RETURNING id;
+
+-- name: FinishScheduledRun :exec
+UPDATE scheduled_task_runs
+SET status = $2, error = $3, finished_at = NOW()
+WHERE id = $1;
+
+-- name: AbandonScheduledRuns :execrows
+UPDATE scheduled_task_runs
+SET status = 'abandoned', finished_at = NOW()
+WHERE status = 'running' AND holder <> $1;
+
+-- name: PurgeScheduledRuns :execrows
+DELETE FROM scheduled_task_runs
+WHERE finished_at < $1;
@@ -0,0 +1,195 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// replicaCheckInterval is how often replica health and lag are sampled
+const replicaCheckInterval = 5 * time.Second
+
+// replicaLagQuery reports whether the server is a standby, whether its WAL
+// receiver is running, and the age in seconds of the last replayed
+// transaction. The age also grows while the primary is idle, which routes reads
+// to the primary until the next write is replayed; that is safe, whereas
+// comparing LSNs reports no lag when streaming has stopped.
+const replicaLagQuery = `
+SELECT pg_is_in_recovery(),
+	EXISTS (SELECT 1 FROM pg_stat_wal_receiver),
+	EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())::float8`
+
+// ReplicaStatus reports the health of a read replica
+type ReplicaStatus struct {
+	Host    string
+	Healthy bool
+	Lag     time.Duration
+	Error   string
+}
+
+type replica struct {
+	host string
+	pool *pgxpool.Pool
+
+	mu     sync.RWMutex
+	status ReplicaStatus
+}
+
+func (r *replica) healthy() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.status.Healthy
+}
+
+// replicaSet routes reads across replicas and tracks their health in the background
+type replicaSet struct {
+	replicas []*replica
+	counter  atomic.Uint64
+	maxLag   time.Duration
+	logger   logger
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func newReplicaSet(ctx context.Context, options *options, logger logger) (*replicaSet, error) {
+	set := &replicaSet{maxLag: options.replicaMaxLag, logger: logger, done: make(chan struct{})}
+
+	for _, url := range options.replicaURLs {
+		pool, err := newPool(ctx, url, options)
+		if err != nil {
+			set.closePools()
+			return nil, fmt.Errorf("failed to create replica pool: %w", err)
+		}
+
+		// Identify replicas by address so that credentials never reach logs
+		connConfig := pool.Config().ConnConfig
+		host := net.JoinHostPort(connConfig.Host, strconv.Itoa(int(connConfig.Port)))
+
+		set.replicas = append(set.replicas, &replica{
+			host:   host,
+			pool:   pool,
+			status: ReplicaStatus{Host: host, Error: "not yet checked"},
+		})
+	}
+
+	// Check once synchronously so that healthy replicas are used immediately
+	set.checkAll(ctx)
+
+	checkCtx, cancel := context.WithCancel(context.Background())
+	set.cancel = cancel
+	go set.run(checkCtx)
+
+	return set, nil
+}
+
+// next returns the pool of the next healthy replica, or nil if none are healthy
+func (s *replicaSet) next() *pgxpool.Pool {
+	n := len(s.replicas)
+	start := s.counter.Add(1)
+	for i := range n {
+		r := s.replicas[(start+uint64(i))%uint64(n)]
+		if r.healthy() {
+			return r.pool
+		}
+	}
+	return nil
+}
+
+// statuses returns a snapshot of every replica's health
+func (s *replicaSet) statuses() []ReplicaStatus {
+	statuses := make([]ReplicaStatus, len(s.replicas))
+	for i, r := range s.replicas {
+		r.mu.RLock()
+		statuses[i] = r.status
+		r.mu.RUnlock()
+	}
+	return statuses
+}
+
+func (s *replicaSet) run(ctx context.Context) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(replicaCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkAll(ctx)
+		}
+	}
+}
+
+func (s *replicaSet) checkAll(ctx context.Context) {
+	for _, r := range s.replicas {
+		s.check(ctx, r)
+	}
+}
+
+// check samples a replica's lag and excludes it from routing if it is
+// unreachable or too far behind
+func (s *replicaSet) check(ctx context.Context, r *replica) {
+	ctx, cancel := context.WithTimeout(ctx, replicaCheckInterval)
+	defer cancel()
+
+	status := ReplicaStatus{Host: r.host}
+
+	var inRecovery, receiving bool
+	var lagSeconds *float64
+	if err := r.pool.QueryRow(ctx, replicaLagQuery).Scan(&inRecovery, &receiving, &lagSeconds); err != nil {
+		status.Error = err.Error()
+	} else if lagSeconds != nil {
+		status.Lag = time.Duration(*lagSeconds * float64(time.Second))
+	}
+
+	switch {
+	case status.Error != "":
+	case !inRecovery:
+		// A promoted replica serves current data
+		status.Healthy = true
+	case !receiving:
+		// Replay stops at whatever was received before streaming broke
+		status.Error = "WAL receiver is not running"
+	case s.maxLag > 0 && lagSeconds == nil:
+		status.Error = "no transaction has been replayed yet"
+	case s.maxLag > 0 && status.Lag > s.maxLag:
+		status.Error = fmt.Sprintf("replication lag %s exceeds %s", status.Lag.Round(time.Millisecond), s.maxLag)
+	default:
+		status.Healthy = true
+	}
+
+	r.mu.Lock()
+	previous := r.status
+	r.status = status
+	r.mu.Unlock()
+
+	if previous.Healthy != status.Healthy {
+		if status.Healthy {
+			s.logger.Info("Read replica is healthy", "host", r.host, "lag", status.Lag)
+		} else {
+			s.logger.Error("Read replica excluded from routing", "host", r.host, "error", status.Error)
+		}
+	}
+}
+
+func (s *replicaSet) close() {
+	if s.cancel != nil {
+		s.cancel()
+		<-s.done
+	}
+	s.closePools()
+}
+
+func (s *replicaSet) closePools() {
+	for _, r := range s.replicas {
+		r.pool.Close()
+	}
+}
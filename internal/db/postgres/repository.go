@@ -0,0 +1,208 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/travisbale/go-template/internal/db/postgres/internal/sqlc"
+	"github.com/travisbale/go-template/internal/domain"
+)
+
+// SQLSTATEs of the integrity constraint violations mapped to domain errors
+const (
+	sqlStateUniqueViolation     = "23505"
+	sqlStateForeignKeyViolation = "23503"
+	sqlStateCheckViolation      = "23514"
+)
+
+// Repository is the plumbing shared by the database adapters in this
+// package. Adapters hold one per table, pass it their sqlc queries and get
+// domain types and domain errors back:
+//
+//	type WidgetRepository struct {
+//		rows Repository[sqlc.Widget, domain.Widget]
+//	}
+//
+//	func NewWidgetRepository(db *DB) *WidgetRepository {
+//		return &WidgetRepository{rows: NewRepository(db, "widget", db.setTenant, widgetFromRow)}
+//	}
+//
+//	func (r *WidgetRepository) Get(ctx context.Context, id uuid.UUID) (domain.Widget, error) {
+//		return r.rows.Get(ctx, func(ctx context.Context, q *sqlc.Queries) (sqlc.Widget, error) {
+//			return q.GetWidget(ctx, id)
+//		})
+//	}
+//
+// Each call runs in its own transaction, or in a savepoint of the one carried
+// by ctx, so adapters join the caller's unit of work and a failed statement
+// does not abort it.
+type Repository[R, T any] struct {
+	db       *DB
+	resource string
+	setup    func(context.Context, pgx.Tx) error
+	toDomain func(R) T
+}
+
+// NewRepository creates a repository for rows of type R describing resource.
+// setup is db.setTenant for tenant tables and nil for global ones.
+func NewRepository[R, T any](db *DB, resource string, setup func(context.Context, pgx.Tx) error, toDomain func(R) T) Repository[R, T] {
+	return Repository[R, T]{db: db, resource: resource, setup: setup, toDomain: toDomain}
+}
+
+// Get runs a query returning one row on the primary. No row is ErrNotFound.
+func (r Repository[R, T]) Get(ctx context.Context, query func(context.Context, *sqlc.Queries) (R, error)) (T, error) {
+	return r.one(ctx, query)
+}
+
+// Write runs an insert or update returning the changed row. No row, e.g.
+// when updating a missing resource, is ErrNotFound.
+func (r Repository[R, T]) Write(ctx context.Context, query func(context.Context, *sqlc.Queries) (R, error)) (T, error) {
+	return r.one(ctx, query)
+}
+
+// List runs a query returning many rows. Outside a transaction it may be
+// served by a read replica, so results can be slightly stale.
+func (r Repository[R, T]) List(ctx context.Context, query func(context.Context, *sqlc.Queries) ([]R, error)) ([]T, error) {
+	var items []T
+	err := r.db.runTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly}, r.setup, func(ctx context.Context, q *sqlc.Queries) error {
+		rows, err := query(ctx, q)
+		if err != nil {
+			return err
+		}
+
+		items = make([]T, len(rows))
+		for i, row := range rows {
+			items[i] = r.toDomain(row)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, r.mapError(err)
+	}
+	return items, nil
+}
+
+// Exec runs a statement reporting the rows it affected, such as a delete.
+// Affecting no rows is ErrNotFound.
+func (r Repository[R, T]) Exec(ctx context.Context, query func(context.Context, *sqlc.Queries) (int64, error)) error {
+	err := r.db.runTx(ctx, pgx.TxOptions{}, r.setup, func(ctx context.Context, q *sqlc.Queries) error {
+		affected, err := query(ctx, q)
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return pgx.ErrNoRows
+		}
+		return nil
+	})
+	if err != nil {
+		return r.mapError(err)
+	}
+	return nil
+}
+
+// WriteVersioned runs an update that only matches the expected version of a
+// live row, i.e. one filtered on version and deleted_at IS NULL. When it
+// matches no row, exists reports whether the resource is still there: if it
+// is, the write was stale and the error is ErrConflict, otherwise it is
+// ErrNotFound.
+func (r Repository[R, T]) WriteVersioned(ctx context.Context, query func(context.Context, *sqlc.Queries) (R, error), exists func(context.Context, *sqlc.Queries) (bool, error)) (T, error) {
+	var item T
+	err := r.db.runTx(ctx, pgx.TxOptions{}, r.setup, func(ctx context.Context, q *sqlc.Queries) error {
+		row, err := query(ctx, q)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return r.staleOrMissing(ctx, q, exists)
+		}
+		if err != nil {
+			return err
+		}
+		item = r.toDomain(row)
+		return nil
+	})
+	if err != nil {
+		var zero T
+		return zero, r.mapError(err)
+	}
+	return item, nil
+}
+
+// ExecVersioned is WriteVersioned for statements that report the rows they
+// affected, such as a soft delete that sets deleted_at
+func (r Repository[R, T]) ExecVersioned(ctx context.Context, query func(context.Context, *sqlc.Queries) (int64, error), exists func(context.Context, *sqlc.Queries) (bool, error)) error {
+	err := r.db.runTx(ctx, pgx.TxOptions{}, r.setup, func(ctx context.Context, q *sqlc.Queries) error {
+		affected, err := query(ctx, q)
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return r.staleOrMissing(ctx, q, exists)
+		}
+		return nil
+	})
+	if err != nil {
+		return r.mapError(err)
+	}
+	return nil
+}
+
+// staleOrMissing explains why a versioned statement matched no row
+func (r Repository[R, T]) staleOrMissing(ctx context.Context, q *sqlc.Queries, exists func(context.Context, *sqlc.Queries) (bool, error)) error {
+	found, err := exists(ctx, q)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return pgx.ErrNoRows
+	}
+	return &domain.Error{Kind: domain.ErrConflict, Resource: r.resource}
+}
+
+func (r Repository[R, T]) one(ctx context.Context, query func(context.Context, *sqlc.Queries) (R, error)) (T, error) {
+	var item T
+	err := r.db.runTx(ctx, pgx.TxOptions{}, r.setup, func(ctx context.Context, q *sqlc.Queries) error {
+		row, err := query(ctx, q)
+		if err != nil {
+			return err
+		}
+		item = r.toDomain(row)
+		return nil
+	})
+	if err != nil {
+		var zero T
+		return zero, r.mapError(err)
+	}
+	return item, nil
+}
+
+// mapError translates missing rows and integrity constraint violations into
+// domain errors, and wraps anything else
+func (r Repository[R, T]) mapError(err error) error {
+	var domainErr *domain.Error
+	if errors.As(err, &domainErr) {
+		return domainErr
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &domain.Error{Kind: domain.ErrNotFound, Resource: r.resource, Err: err}
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		var kind error
+		switch pgErr.Code {
+		case sqlStateUniqueViolation:
+			kind = domain.ErrAlreadyExists
+		case sqlStateForeignKeyViolation:
+			kind = domain.ErrReferenceViolation
+		case sqlStateCheckViolation:
+			kind = domain.ErrInvalid
+		}
+		if kind != nil {
+			return &domain.Error{Kind: kind, Resource: r.resource, Constraint: pgErr.ConstraintName, Err: err}
+		}
+	}
+
+	return fmt.Errorf("failed to access %s: %w", r.resource, err)
+}
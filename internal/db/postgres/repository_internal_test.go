@@ -0,0 +1,63 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/travisbale/go-template/internal/domain"
+)
+
+func TestMapError(t *testing.T) {
+	rows := Repository[struct{}, struct{}]{resource: "widget"}
+	existing := &domain.Error{Kind: domain.ErrConflict, Resource: "gadget"}
+
+	tests := []struct {
+		name       string
+		err        error
+		kind       error
+		constraint string
+	}{
+		{"no rows", pgx.ErrNoRows, domain.ErrNotFound, ""},
+		{"wrapped no rows", fmt.Errorf("failed to scan: %w", pgx.ErrNoRows), domain.ErrNotFound, ""},
+		{"unique violation", &pgconn.PgError{Code: "23505", ConstraintName: "widgets_name_key"}, domain.ErrAlreadyExists, "widgets_name_key"},
+		{"foreign key violation", &pgconn.PgError{Code: "23503", ConstraintName: "widgets_owner_id_fkey"}, domain.ErrReferenceViolation, "widgets_owner_id_fkey"},
+		{"check violation", &pgconn.PgError{Code: "23514", ConstraintName: "widgets_size_check"}, domain.ErrInvalid, "widgets_size_check"},
+		{"serialization failure", &pgconn.PgError{Code: "40001"}, nil, ""},
+		{"connection error", errors.New("connection refused"), nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := rows.mapError(tt.err)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("mapped error %v does not wrap the cause", err)
+			}
+
+			var domainErr *domain.Error
+			if tt.kind == nil {
+				if errors.As(err, &domainErr) {
+					t.Fatalf("got domain error %v, want an internal error", err)
+				}
+				return
+			}
+			if !errors.As(err, &domainErr) {
+				t.Fatalf("got %v, want a domain error", err)
+			}
+			if !errors.Is(err, tt.kind) {
+				t.Errorf("kind = %v, want %v", domainErr.Kind, tt.kind)
+			}
+			if domainErr.Resource != "widget" || domainErr.Constraint != tt.constraint {
+				t.Errorf("got resource %q constraint %q, want %q %q", domainErr.Resource, domainErr.Constraint, "widget", tt.constraint)
+			}
+		})
+	}
+
+	t.Run("domain error", func(t *testing.T) {
+		if err := rows.mapError(fmt.Errorf("failed to update: %w", existing)); err != existing {
+			t.Errorf("got %v, want the domain error unchanged", err)
+		}
+	})
+}
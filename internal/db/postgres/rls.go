@@ -0,0 +1,116 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/travisbale/go-template/internal/db/postgres/internal/sqlc"
+)
+
+// RLSTable reports the Row Level Security state of a table with a tenant_id column
+type RLSTable struct {
+	Schema           string
+	Name             string
+	RowSecurity      bool
+	ForceRowSecurity bool
+	Policies         int
+}
+
+// Problems lists what keeps the table from being isolated by tenant
+func (t RLSTable) Problems() []string {
+	var problems []string
+	if !t.RowSecurity {
+		problems = append(problems, "row level security is not enabled")
+	}
+	if !t.ForceRowSecurity {
+		problems = append(problems, "row level security is not forced for the table owner")
+	}
+	if t.Policies == 0 {
+		problems = append(problems, "no policies")
+	}
+	return problems
+}
+
+// RLSReport is the result of VerifyRLS
+type RLSReport struct {
+	Tables []RLSTable
+	// BypassRLS is set when the connected role is a superuser or has
+	// BYPASSRLS, in which case no policy applies to the application
+	BypassRLS bool
+}
+
+// OK reports whether every tenant table is protected
+func (r *RLSReport) OK() bool {
+	for _, table := range r.Tables {
+		if len(table.Problems()) > 0 {
+			return false
+		}
+	}
+	return !r.BypassRLS
+}
+
+// VerifyRLS inspects every table with a tenant_id column and reports whether
+// RLS is enabled, forced and has at least one policy
+func (d *DB) VerifyRLS(ctx context.Context) (*RLSReport, error) {
+	rows, err := d.pool.Query(ctx, `
+		SELECT n.nspname, c.relname, c.relrowsecurity, c.relforcerowsecurity,
+			(SELECT count(*) FROM pg_policies p WHERE p.schemaname = n.nspname AND p.tablename = c.relname)
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		JOIN pg_attribute a ON a.attrelid = c.oid AND a.attname = 'tenant_id' AND NOT a.attisdropped
+		WHERE c.relkind IN ('r', 'p')
+			AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+			AND n.nspname NOT LIKE 'pg_toast%'
+		ORDER BY n.nspname, c.relname`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect tables: %w", err)
+	}
+
+	tables, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (RLSTable, error) {
+		var table RLSTable
+		err := row.Scan(&table.Schema, &table.Name, &table.RowSecurity, &table.ForceRowSecurity, &table.Policies)
+		return table, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect tables: %w", err)
+	}
+
+	report := &RLSReport{Tables: tables}
+	err = d.pool.QueryRow(ctx, "SELECT rolsuper OR rolbypassrls FROM pg_roles WHERE rolname = current_user").Scan(&report.BypassRLS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect role: %w", err)
+	}
+	return report, nil
+}
+
+// WithSystemContext executes fn in a transaction that may read and write
+// across tenants, for admin operations such as migrations between tenants or
+// support tooling. The reason is written to app.system_access_log in the same
+// transaction, so the access is recorded if and only if it commits, and the
+// policies only honour app.system_context in a transaction with such a row. It
+// cannot be nested in another transaction, which would leak the bypass to the
+// caller.
+func (d *DB) WithSystemContext(ctx context.Context, reason string, fn func(context.Context, *sqlc.Queries) error) error {
+	if strings.TrimSpace(reason) == "" {
+		return errors.New("a reason is required for system context access")
+	}
+	if _, ok := txFromContext(ctx); ok {
+		return errors.New("system context cannot be nested in another transaction")
+	}
+
+	setup := func(ctx context.Context, tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "INSERT INTO app.system_access_log (reason) VALUES ($1)", reason); err != nil {
+			return fmt.Errorf("failed to record system access: %w", err)
+		}
+		if _, err := tx.Exec(ctx, "SELECT set_config('app.system_context', 'on', true)"); err != nil {
+			return fmt.Errorf("failed to set system context: %w", err)
+		}
+		return nil
+	}
+
+	d.logger.Info("Entering system context", "reason", reason)
+	return d.runTx(ctx, pgx.TxOptions{}, setup, fn)
+}
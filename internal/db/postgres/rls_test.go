@@ -0,0 +1,71 @@
+package postgres_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/travisbale/go-template/internal/db/postgres"
+	"github.com/travisbale/go-template/internal/db/postgres/internal/sqlc"
+	"github.com/travisbale/go-template/internal/domain"
+	"github.com/travisbale/go-template/internal/testutil"
+	"github.com/travisbale/heimdall/tenant"
+)
+
+func TestSystemContextRequiresAccessLog(t *testing.T) {
+	databaseURL := testutil.NewDatabase(t)
+	ctx := context.Background()
+
+	db, err := postgres.NewDB(ctx, databaseURL, testutil.Logger())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer db.Close()
+
+	tenantID := uuid.New()
+	entries := postgres.NewAuditRepository(db)
+	if _, err := entries.Insert(tenant.WithTenant(ctx, tenantID), domain.AuditEntry{TenantID: tenantID, ActorType: "system", Action: "create", ResourceType: "widget", ResourceID: "1"}); err != nil {
+		t.Fatalf("failed to insert entry: %v", err)
+	}
+
+	// Setting the flag by hand does not lift tenant filtering
+	conn, err := pgx.Connect(ctx, databaseURL)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close(ctx) //nolint:errcheck
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		t.Fatalf("failed to begin: %v", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	if _, err := tx.Exec(ctx, "SELECT set_config('app.system_context', 'on', true)"); err != nil {
+		t.Fatalf("failed to set system context: %v", err)
+	}
+	var count int
+	err = tx.QueryRow(ctx, "SELECT count(*) FROM audit_log").Scan(&count)
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "42501" {
+		t.Fatalf("forged system context read %d rows (error %v), want insufficient_privilege", count, err)
+	}
+
+	// WithSystemContext records the access and sees every tenant
+	err = db.WithSystemContext(ctx, "test cross-tenant read", func(ctx context.Context, q *sqlc.Queries) error {
+		rows, err := q.ListAuditEntries(ctx, sqlc.ListAuditEntriesParams{Limit: 10})
+		if err != nil {
+			return err
+		}
+		if len(rows) != 1 {
+			t.Errorf("system context sees %d entries, want 1", len(rows))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("system context failed: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/travisbale/go-template/internal/db/postgres/internal/sqlc"
+)
+
+// AcquireSchedulerLease takes the named lease for holder if it is free or
+// expired, or renews it if holder already has it, and reports whether holder
+// now holds it
+func (d *DB) AcquireSchedulerLease(ctx context.Context, name, holder string, lease time.Duration) (bool, error) {
+	_, err := d.Queries().AcquireSchedulerLease(ctx, sqlc.AcquireSchedulerLeaseParams{
+		Name:         name,
+		Holder:       holder,
+		LeaseSeconds: lease.Seconds(),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire scheduler lease: %w", err)
+	}
+	return true, nil
+}
+
+// ReleaseSchedulerLease gives up the named lease if holder has it, so that
+// another replica can take over without waiting for it to expire
+func (d *DB) ReleaseSchedulerLease(ctx context.Context, name, holder string) error {
+	if err := d.Queries().ReleaseSchedulerLease(ctx, sqlc.ReleaseSchedulerLeaseParams{Name: name, Holder: holder}); err != nil {
+		return fmt.Errorf("failed to release scheduler lease: %w", err)
+	}
+	return nil
+}
+
+// LatestScheduledRuns returns the most recent occurrence run for each task
+func (d *DB) LatestScheduledRuns(ctx context.Context) (map[string]time.Time, error) {
+	rows, err := d.Queries().LatestScheduledRuns(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scheduled runs: %w", err)
+	}
+
+	latest := make(map[string]time.Time, len(rows))
+	for _, row := range rows {
+		latest[row.Task] = row.ScheduledAt
+	}
+	return latest, nil
+}
+
+// StartScheduledRun records the start of a task's occurrence. It returns
+// false if the occurrence has already been run.
+func (d *DB) StartScheduledRun(ctx context.Context, task string, scheduledAt time.Time, holder string) (uuid.UUID, bool, error) {
+	id, err := d.Queries().StartScheduledRun(ctx, sqlc.StartScheduledRunParams{
+		Task:        task,
+		ScheduledAt: scheduledAt,
+		Holder:      holder,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, false, nil
+	}
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("failed to record scheduled run: %w", err)
+	}
+	return id, true, nil
+}
+
+// FinishScheduledRun records the outcome of a run. A nil cause means it succeeded.
+func (d *DB) FinishScheduledRun(ctx context.Context, id uuid.UUID, cause error) error {
+	params := sqlc.FinishScheduledRunParams{ID: id, Status: "succeeded"}
+	if cause != nil {
+		message := cause.Error()
+		params.Status, params.Error = "failed", &message
+	}
+	if err := d.Queries().FinishScheduledRun(ctx, params); err != nil {
+		return fmt.Errorf("failed to record scheduled run result: %w", err)
+	}
+	return nil
+}
+
+// AbandonScheduledRuns marks runs left running by other holders, such as a
+// leader that crashed, as abandoned
+func (d *DB) AbandonScheduledRuns(ctx context.Context, holder string) (int64, error) {
+	abandoned, err := d.Queries().AbandonScheduledRuns(ctx, holder)
+	if err != nil {
+		return 0, fmt.Errorf("failed to abandon scheduled runs: %w", err)
+	}
+	return abandoned, nil
+}
+
+// PurgeScheduledRuns deletes the history of runs that finished before the given time
+func (d *DB) PurgeScheduledRuns(ctx context.Context, before time.Time) (int64, error) {
+	deleted, err := d.Queries().PurgeScheduledRuns(ctx, &before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge scheduled runs: %w", err)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,251 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/travisbale/go-template/internal/db/postgres/internal/sqlc"
+)
+
+// SQLSTATEs for which the whole transaction can safely be retried
+const (
+	sqlStateSerializationFailure = "40001"
+	sqlStateDeadlockDetected     = "40P01"
+)
+
+// RetryPolicy controls how transactions are retried after serialization failures and deadlocks
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy is used by every transaction helper on DB
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 10 * time.Millisecond,
+	MaxBackoff:     time.Second,
+}
+
+// txContextKey is the context key for the active transaction
+type txContextKey struct{}
+
+// txState is the transaction or savepoint carried by a context
+type txState struct {
+	tx    pgx.Tx
+	hooks []func(context.Context)
+
+	// savepoint is set for a savepoint, whose release hooks run just before
+	// it is released to undo settings that would otherwise outlive it
+	savepoint bool
+	release   []func(context.Context) error
+}
+
+// txFromContext returns the transaction carried by ctx, if any
+func txFromContext(ctx context.Context) (*txState, bool) {
+	state, ok := ctx.Value(txContextKey{}).(*txState)
+	return state, ok
+}
+
+// queries returns queries bound to the transaction carried by ctx, or to the
+// pool when there is none. Repository methods use it so that they join the
+// caller's unit of work automatically.
+func (d *DB) queries(ctx context.Context) *sqlc.Queries {
+	return sqlc.New(d.conn(ctx))
+}
+
+// conn returns the transaction carried by ctx or the pool
+func (d *DB) conn(ctx context.Context) sqlc.DBTX {
+	if state, ok := txFromContext(ctx); ok {
+		return state.tx
+	}
+	return d.pool
+}
+
+// InTransaction runs fn as a unit of work. The transaction rides on the ctx
+// passed to fn, so repository calls made with it share the transaction, and an
+// application service that calls InTransaction from within another service's
+// unit of work joins it through a savepoint.
+func (d *DB) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return d.runTx(ctx, pgx.TxOptions{}, nil, func(ctx context.Context, _ *sqlc.Queries) error {
+		return fn(ctx)
+	})
+}
+
+// InTenantTransaction runs fn as a tenant-scoped unit of work, setting the
+// tenant for RLS like WithTenantContext
+func (d *DB) InTenantTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return d.runTx(ctx, pgx.TxOptions{}, d.setTenant, func(ctx context.Context, _ *sqlc.Queries) error {
+		return fn(ctx)
+	})
+}
+
+// AfterCommit registers a hook to run once the transaction carried by ctx has
+// committed, e.g. to publish an event. Hooks registered in a savepoint that is
+// rolled back, or in an attempt that is retried, are discarded. Without a
+// transaction the hook runs immediately.
+func AfterCommit(ctx context.Context, hook func(context.Context)) {
+	state, ok := txFromContext(ctx)
+	if !ok {
+		hook(ctx)
+		return
+	}
+	state.hooks = append(state.hooks, hook)
+}
+
+// WithTransactionOptions executes fn within a transaction started with the
+// given isolation level and access mode. Read-only transactions up to
+// REPEATABLE READ are routed to a healthy read replica when one is
+// configured; serializable and deferrable ones stay on the primary, since a
+// standby rejects them. The closure is retried with backoff
+// when the transaction fails with a serialization failure or deadlock, so it
+// must not have side effects outside the database.
+//
+// ctx passed to fn carries the transaction. Calling WithTransaction,
+// WithTransactionOptions or WithTenantContext with it runs the nested closure
+// in a savepoint of the same transaction, whose options are then ignored.
+func (d *DB) WithTransactionOptions(ctx context.Context, opts pgx.TxOptions, fn func(context.Context, *sqlc.Queries) error) error {
+	return d.runTx(ctx, opts, nil, fn)
+}
+
+// runTx executes fn in a new transaction, or in a savepoint if ctx already
+// carries one. setup runs before fn on every attempt.
+func (d *DB) runTx(ctx context.Context, opts pgx.TxOptions, setup func(context.Context, pgx.Tx) error, fn func(context.Context, *sqlc.Queries) error) error {
+	if parent, ok := txFromContext(ctx); ok {
+		return d.runSavepoint(ctx, parent, setup, fn)
+	}
+
+	policy := DefaultRetryPolicy
+	backoff := policy.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		state, err := d.attemptTx(ctx, opts, setup, fn)
+		if err == nil {
+			for _, hook := range state.hooks {
+				hook(ctx)
+			}
+			return nil
+		}
+		if !isRetryable(err) || attempt >= policy.MaxAttempts {
+			return err
+		}
+
+		d.logger.Info("Retrying transaction", "attempt", attempt, "error", err)
+
+		// Full jitter keeps competing transactions from retrying in lockstep
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(rand.N(backoff) + time.Millisecond):
+		}
+		backoff = min(backoff*2, policy.MaxBackoff)
+	}
+}
+
+// attemptTx runs a single attempt of a top-level transaction, returning its
+// state so that the after-commit hooks can be run
+func (d *DB) attemptTx(ctx context.Context, opts pgx.TxOptions, setup func(context.Context, pgx.Tx) error, fn func(context.Context, *sqlc.Queries) error) (*txState, error) {
+	// Read-only transactions can be served by a replica; writes stay on the primary
+	pool := d.pool
+	if replicaCanServe(opts) {
+		pool = d.readPool()
+	}
+
+	tx, err := pool.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer d.rollback(ctx, tx)
+
+	state := &txState{tx: tx}
+	txCtx := context.WithValue(ctx, txContextKey{}, state)
+
+	if setup != nil {
+		if err := setup(txCtx, tx); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := fn(txCtx, sqlc.New(tx)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// replicaCanServe reports whether a transaction can run on a hot standby,
+// which only allows read-only transactions up to REPEATABLE READ
+func replicaCanServe(opts pgx.TxOptions) bool {
+	if opts.AccessMode != pgx.ReadOnly || opts.DeferrableMode == pgx.Deferrable {
+		return false
+	}
+	switch opts.IsoLevel {
+	case "", pgx.ReadCommitted, pgx.RepeatableRead:
+		return true
+	default:
+		return false
+	}
+}
+
+// runSavepoint runs fn in a savepoint of an existing transaction. An error
+// rolls back to the savepoint and is returned to the enclosing closure.
+func (d *DB) runSavepoint(ctx context.Context, parent *txState, setup func(context.Context, pgx.Tx) error, fn func(context.Context, *sqlc.Queries) error) error {
+	savepoint, err := parent.tx.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create savepoint: %w", err)
+	}
+	defer d.rollback(ctx, savepoint)
+
+	state := &txState{tx: savepoint, savepoint: true}
+	spCtx := context.WithValue(ctx, txContextKey{}, state)
+
+	if setup != nil {
+		if err := setup(spCtx, savepoint); err != nil {
+			return err
+		}
+	}
+
+	if err := fn(spCtx, sqlc.New(savepoint)); err != nil {
+		return err
+	}
+
+	// Rolling back to a savepoint undoes set_config, but releasing it does not
+	for i := len(state.release) - 1; i >= 0; i-- {
+		if err := state.release[i](ctx); err != nil {
+			return err
+		}
+	}
+
+	if err := savepoint.Commit(ctx); err != nil {
+		return err
+	}
+
+	// Hooks only run if the enclosing transaction commits
+	parent.hooks = append(parent.hooks, state.hooks...)
+	return nil
+}
+
+// rollback aborts a transaction or savepoint that was not committed.
+// Rolling back after a successful commit returns ErrTxClosed, which is expected.
+func (d *DB) rollback(ctx context.Context, tx pgx.Tx) {
+	if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+		d.logger.Error("failed to rollback transaction", "error", err)
+	}
+}
+
+// isRetryable reports whether err is a serialization failure or deadlock
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == sqlStateSerializationFailure || pgErr.Code == sqlStateDeadlockDetected
+}
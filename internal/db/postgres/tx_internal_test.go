@@ -0,0 +1,30 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestReplicaCanServe(t *testing.T) {
+	tests := []struct {
+		name string
+		opts pgx.TxOptions
+		want bool
+	}{
+		{"read write", pgx.TxOptions{}, false},
+		{"read only", pgx.TxOptions{AccessMode: pgx.ReadOnly}, true},
+		{"read committed", pgx.TxOptions{AccessMode: pgx.ReadOnly, IsoLevel: pgx.ReadCommitted}, true},
+		{"repeatable read", pgx.TxOptions{AccessMode: pgx.ReadOnly, IsoLevel: pgx.RepeatableRead}, true},
+		{"serializable", pgx.TxOptions{AccessMode: pgx.ReadOnly, IsoLevel: pgx.Serializable}, false},
+		{"deferrable", pgx.TxOptions{AccessMode: pgx.ReadOnly, IsoLevel: pgx.Serializable, DeferrableMode: pgx.Deferrable}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := replicaCanServe(tt.opts); got != tt.want {
+				t.Errorf("replicaCanServe(%+v) = %v, want %v", tt.opts, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package postgres_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/travisbale/go-template/internal/db/postgres"
+	"github.com/travisbale/go-template/internal/db/postgres/internal/sqlc"
+	"github.com/travisbale/go-template/internal/domain"
+	"github.com/travisbale/go-template/internal/testutil"
+	"github.com/travisbale/heimdall/tenant"
+)
+
+func TestNestedTenantIsRestoredAfterSavepoint(t *testing.T) {
+	db := testutil.NewDB(t)
+	entries := postgres.NewAuditRepository(db)
+	tenantA, tenantB := uuid.New(), uuid.New()
+	ctxA := tenant.WithTenant(context.Background(), tenantA)
+
+	err := db.InTenantTransaction(ctxA, func(ctx context.Context) error {
+		// Record an entry for another tenant in a savepoint
+		ctxB := tenant.WithTenant(ctx, tenantB)
+		if _, err := entries.Insert(ctxB, domain.AuditEntry{TenantID: tenantB, ActorType: "system", Action: "create", ResourceType: "widget", ResourceID: "1"}); err != nil {
+			t.Fatalf("failed to insert entry for tenant B: %v", err)
+		}
+
+		// A savepoint that sets no tenant sees tenant A's rows again
+		return db.WithTransactionOptions(ctx, pgx.TxOptions{}, func(ctx context.Context, q *sqlc.Queries) error {
+			rows, err := q.ListAuditEntries(ctx, sqlc.ListAuditEntriesParams{Limit: 10})
+			if err != nil {
+				t.Fatalf("failed to list entries: %v", err)
+			}
+			if len(rows) != 0 {
+				t.Errorf("tenant A sees %d of tenant B's entries after the savepoint", len(rows))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("transaction failed: %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditEntry is a record in a tenant's audit log
+type AuditEntry struct {
+	ID           uuid.UUID
+	TenantID     uuid.UUID
+	ActorID      string
+	ActorType    string
+	Action       string
+	ResourceType string
+	ResourceID   string
+	// Changes maps each changed field to {"before": ..., "after": ...}
+	Changes   json.RawMessage
+	RequestID string
+	CreatedAt time.Time
+}
+
+// AuditFilter narrows a listing of the audit log. Nil fields match every entry.
+type AuditFilter struct {
+	ActorID      *string
+	Action       *string
+	ResourceType *string
+	ResourceID   *string
+	RequestID    *string
+	CreatedFrom  *time.Time
+	CreatedUntil *time.Time
+}
@@ -0,0 +1,50 @@
+// Package domain holds the types shared by the application services and
+// the adapters that store them, starting with the errors adapters return in
+// place of database errors.
+package domain
+
+import "errors"
+
+// Kinds of domain error. Test for them with errors.Is.
+var (
+	// ErrNotFound means the resource does not exist, or belongs to another tenant
+	ErrNotFound = errors.New("not found")
+	// ErrAlreadyExists means the change would duplicate a unique value
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrReferenceViolation means the change references a resource that does
+	// not exist, or removes one that is still referenced
+	ErrReferenceViolation = errors.New("conflicts with a related resource")
+	// ErrInvalid means the change breaks a rule enforced by the database
+	ErrInvalid = errors.New("is invalid")
+	// ErrConflict means the resource has changed since the version the
+	// change was based on, so the caller should reread it and try again
+	ErrConflict = errors.New("has changed since it was read")
+)
+
+// Error is a domain error about a kind of resource. Its message is safe to
+// show to clients; the underlying cause is only available through Unwrap.
+type Error struct {
+	// Kind is one of the Err sentinels
+	Kind error
+	// Resource names the kind of resource, e.g. "widget"
+	Resource string
+	// Constraint is the database constraint that was violated, if any
+	Constraint string
+	// Err is the underlying cause
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Resource == "" {
+		return e.Kind.Error()
+	}
+	return e.Resource + " " + e.Kind.Error()
+}
+
+// Unwrap exposes both the kind, for errors.Is, and the underlying cause
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
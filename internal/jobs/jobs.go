@@ -0,0 +1,80 @@
+// Package jobs runs background jobs stored in Postgres. Jobs are enqueued
+// with postgres.EnqueueJob, inside the transaction that makes them necessary,
+// and executed by Workers that claim them with FOR UPDATE SKIP LOCKED.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/travisbale/go-template/internal/db/postgres"
+)
+
+// Job is a job claimed from the queue
+type Job = postgres.Job
+
+// Handler runs a job. Returning an error retries the job with backoff
+// unless the error is wrapped with Permanent.
+type Handler func(ctx context.Context, job Job) error
+
+// Registry maps job kinds to their handlers
+type Registry struct {
+	handlers map[string]Handler
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{handlers: make(map[string]Handler)}
+}
+
+// Register adds the handler for a job kind. It panics if the kind is already
+// registered, as that is a programming error.
+func (r *Registry) Register(kind string, handler Handler) {
+	if _, ok := r.handlers[kind]; ok {
+		panic(fmt.Sprintf("job kind %q registered twice", kind))
+	}
+	r.handlers[kind] = handler
+}
+
+// Handle registers a handler that receives the job payload decoded into T
+func Handle[T any](r *Registry, kind string, fn func(ctx context.Context, payload T) error) {
+	r.Register(kind, func(ctx context.Context, job Job) error {
+		var payload T
+		if err := json.Unmarshal(job.Payload, &payload); err != nil {
+			return Permanent(fmt.Errorf("failed to decode %s payload: %w", kind, err))
+		}
+		return fn(ctx, payload)
+	})
+}
+
+// Kinds returns the registered job kinds
+func (r *Registry) Kinds() []string {
+	kinds := make([]string, 0, len(r.handlers))
+	for kind := range r.handlers {
+		kinds = append(kinds, kind)
+	}
+	slices.Sort(kinds)
+	return kinds
+}
+
+// permanentError marks a failure that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps an error so that the job is marked failed without retrying
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// isPermanent reports whether err was wrapped with Permanent
+func isPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
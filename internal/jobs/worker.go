@@ -0,0 +1,307 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/travisbale/go-template/internal/db/postgres"
+)
+
+type logger interface {
+	Info(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// store is the jobs table, implemented by postgres.DB
+type store interface {
+	ClaimJobs(ctx context.Context, kinds []string, limit int, lease time.Duration) ([]Job, error)
+	ExtendJobLease(ctx context.Context, job Job, lease time.Duration) error
+	CompleteJob(ctx context.Context, job Job) error
+	RetryJob(ctx context.Context, job Job, at time.Time, cause error) error
+	FailJob(ctx context.Context, job Job, cause error) error
+	FailAbandonedJobs(ctx context.Context) (int64, error)
+	PurgeCompletedJobs(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Config tunes a worker. Zero values use the defaults noted on each field.
+type Config struct {
+	// Concurrency is the number of jobs run at once (10)
+	Concurrency int
+	// PollInterval is how often the queue is checked when it is empty (1s)
+	PollInterval time.Duration
+	// Lease is how long a claimed job is hidden from other workers. It is
+	// renewed while the job runs, so it only bounds recovery after a crash (1m).
+	Lease time.Duration
+	// DrainTimeout is how long running jobs may finish after shutdown begins
+	// before their context is cancelled (30s)
+	DrainTimeout time.Duration
+	// InitialBackoff and MaxBackoff bound the delay between attempts (1s, 1h)
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Retention is how long completed jobs are kept (7 days)
+	Retention time.Duration
+}
+
+// stopMargin is the time allowed after the drain timeout for cancelled jobs
+// to return and for their outcomes to be recorded
+const stopMargin = 5 * time.Second
+
+// StopTimeout returns how long Run may take to return once its context is
+// cancelled. The database must stay open for at least this long, so that
+// draining jobs can record their outcomes.
+func (c Config) StopTimeout() time.Duration {
+	c.setDefaults()
+	return c.DrainTimeout + stopMargin
+}
+
+func (c *Config) setDefaults() {
+	if c.Concurrency <= 0 {
+		c.Concurrency = 10
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = time.Second
+	}
+	if c.Lease <= 0 {
+		c.Lease = time.Minute
+	}
+	if c.DrainTimeout <= 0 {
+		c.DrainTimeout = 30 * time.Second
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = time.Second
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = time.Hour
+	}
+	if c.Retention <= 0 {
+		c.Retention = 7 * 24 * time.Hour
+	}
+}
+
+// Worker claims and runs jobs of the kinds in its registry
+type Worker struct {
+	store    store
+	registry *Registry
+	logger   logger
+	config   Config
+
+	// slots limits concurrency and freed signals that a job finished
+	slots chan struct{}
+	freed chan struct{}
+}
+
+// NewWorker creates a worker. Any number of workers, in one process or
+// many, can share the queue.
+func NewWorker(store store, registry *Registry, logger logger, config Config) *Worker {
+	config.setDefaults()
+	return &Worker{
+		store:    store,
+		registry: registry,
+		logger:   logger,
+		config:   config,
+		slots:    make(chan struct{}, config.Concurrency),
+		freed:    make(chan struct{}, 1),
+	}
+}
+
+// Run claims and runs jobs until ctx is cancelled, then stops claiming and
+// waits up to DrainTimeout for running jobs before cancelling them. Jobs
+// cancelled this way are retried.
+func (w *Worker) Run(ctx context.Context) error {
+	kinds := w.registry.Kinds()
+	if len(kinds) == 0 {
+		w.logger.Info("No job handlers registered, worker idle")
+		<-ctx.Done()
+		return nil
+	}
+	w.logger.Info("Starting job worker", "kinds", kinds, "concurrency", w.config.Concurrency)
+
+	// Running jobs outlive ctx until the drain timeout
+	jobCtx, cancelJobs := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelJobs()
+
+	purge := time.NewTicker(time.Hour)
+	defer purge.Stop()
+	abandoned := time.NewTicker(w.config.Lease)
+	defer abandoned.Stop()
+
+	var wg sync.WaitGroup
+	for {
+		wait := w.config.PollInterval
+		if free := w.config.Concurrency - len(w.slots); free > 0 {
+			jobs, err := w.store.ClaimJobs(ctx, kinds, free, w.config.Lease)
+			if err != nil && ctx.Err() == nil {
+				w.logger.Error("failed to claim jobs", "error", err)
+			}
+			for _, job := range jobs {
+				w.slots <- struct{}{}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					w.run(jobCtx, job)
+				}()
+			}
+			// Claim again straight away while the queue has more work
+			if len(jobs) == free {
+				wait = 0
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			w.drain(&wg, cancelJobs)
+			return nil
+		case <-purge.C:
+			w.purge(ctx)
+		case <-abandoned.C:
+			w.failAbandoned(ctx)
+		case <-w.freed:
+		case <-time.After(wait):
+		}
+	}
+}
+
+// drain waits for running jobs, cancelling them after the drain timeout
+func (w *Worker) drain(wg *sync.WaitGroup, cancelJobs context.CancelFunc) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	w.logger.Info("Draining running jobs", "running", len(w.slots), "timeout", w.config.DrainTimeout)
+	select {
+	case <-done:
+	case <-time.After(w.config.DrainTimeout):
+		w.logger.Info("Drain timeout reached, cancelling running jobs", "running", len(w.slots))
+		cancelJobs()
+		<-done
+	}
+}
+
+// run executes a job, renewing its lease while it runs, and records the outcome
+func (w *Worker) run(ctx context.Context, job Job) {
+	defer func() {
+		<-w.slots
+		select {
+		case w.freed <- struct{}{}:
+		default:
+		}
+	}()
+
+	// The heartbeat cancels the job if another worker has claimed it
+	jobCtx, cancel := context.WithCancel(ctx)
+	heartbeatCtx, stopHeartbeat := context.WithCancel(jobCtx)
+	go w.heartbeat(heartbeatCtx, job, cancel)
+
+	start := time.Now()
+	err := w.execute(jobCtx, job)
+	stopHeartbeat()
+	cancel()
+
+	// Record the outcome even if the job was cancelled by the drain timeout
+	ctx = context.WithoutCancel(ctx)
+
+	switch {
+	case err == nil:
+		w.record(job, "complete job", w.store.CompleteJob(ctx, job))
+	case isPermanent(err) || job.Attempts >= job.MaxAttempts:
+		w.logger.Error("Job failed", "job_id", job.ID, "kind", job.Kind, "attempts", job.Attempts, "error", err)
+		w.record(job, "mark job failed", w.store.FailJob(ctx, job, err))
+	default:
+		retryAt := time.Now().Add(w.backoff(job.Attempts))
+		w.logger.Info("Job attempt failed, retrying", "job_id", job.ID, "kind", job.Kind, "attempt", job.Attempts, "retry_at", retryAt, "duration", time.Since(start), "error", err)
+		w.record(job, "reschedule job", w.store.RetryJob(ctx, job, retryAt, err))
+	}
+}
+
+// record logs the failure to store the outcome of a job. Losing the lease
+// means the job stalled past it and another worker claimed it, which now owns
+// the job's state.
+func (w *Worker) record(job Job, operation string, err error) {
+	switch {
+	case err == nil:
+	case errors.Is(err, postgres.ErrLeaseLost):
+		w.logger.Error("Job was claimed by another worker, discarding this attempt's outcome", "job_id", job.ID, "kind", job.Kind, "attempt", job.Attempts)
+	default:
+		w.logger.Error("failed to "+operation, "job_id", job.ID, "error", err)
+	}
+}
+
+// execute calls the handler, converting a panic into a failed attempt
+func (w *Worker) execute(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+
+	handler, ok := w.registry.handlers[job.Kind]
+	if !ok {
+		return Permanent(fmt.Errorf("no handler for job kind %q", job.Kind))
+	}
+	return handler(ctx, job)
+}
+
+// heartbeat renews the job's lease until ctx is cancelled. If the lease was
+// lost to another worker it cancels the job, which is running there too.
+func (w *Worker) heartbeat(ctx context.Context, job Job, cancelJob context.CancelFunc) {
+	ticker := time.NewTicker(w.config.Lease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := w.store.ExtendJobLease(ctx, job, w.config.Lease)
+			switch {
+			case err == nil || ctx.Err() != nil:
+			case errors.Is(err, postgres.ErrLeaseLost):
+				w.logger.Error("Job lease was lost to another worker, cancelling this attempt", "job_id", job.ID, "kind", job.Kind, "attempt", job.Attempts)
+				cancelJob()
+				return
+			default:
+				w.logger.Error("failed to extend job lease", "job_id", job.ID, "error", err)
+			}
+		}
+	}
+}
+
+// backoff doubles the delay with each attempt, with jitter so that jobs that
+// failed together are not retried together
+func (w *Worker) backoff(attempts int) time.Duration {
+	delay := w.config.InitialBackoff
+	for i := 1; i < attempts && delay < w.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, w.config.MaxBackoff)
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// failAbandoned fails jobs whose worker died on their final attempt
+func (w *Worker) failAbandoned(ctx context.Context) {
+	failed, err := w.store.FailAbandonedJobs(ctx)
+	if err != nil {
+		w.logger.Error("failed to mark abandoned jobs failed", "error", err)
+		return
+	}
+	if failed > 0 {
+		w.logger.Error("Jobs failed after their lease expired on the final attempt", "count", failed)
+	}
+}
+
+func (w *Worker) purge(ctx context.Context) {
+	deleted, err := w.store.PurgeCompletedJobs(ctx, time.Now().Add(-w.config.Retention))
+	if err != nil {
+		w.logger.Error("failed to purge completed jobs", "error", err)
+		return
+	}
+	if deleted > 0 {
+		w.logger.Info("Purged completed jobs", "count", deleted)
+	}
+}
@@ -0,0 +1,302 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/travisbale/go-template/internal/db/postgres"
+)
+
+// fakeStore records what the worker does with each job
+type fakeStore struct {
+	mu        sync.Mutex
+	pending   []Job
+	completed []uuid.UUID
+	retried   map[uuid.UUID]time.Time
+	failed    map[uuid.UUID]error
+	renewals  int
+	abandoned int
+	leaseLost bool
+}
+
+func newFakeStore(jobs ...Job) *fakeStore {
+	return &fakeStore{pending: jobs, retried: make(map[uuid.UUID]time.Time), failed: make(map[uuid.UUID]error)}
+}
+
+func (s *fakeStore) ClaimJobs(ctx context.Context, kinds []string, limit int, lease time.Duration) ([]Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	claimed := s.pending[:min(limit, len(s.pending))]
+	s.pending = s.pending[len(claimed):]
+	return claimed, nil
+}
+
+func (s *fakeStore) ExtendJobLease(ctx context.Context, job Job, lease time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.renewals++
+	if s.leaseLost {
+		return postgres.ErrLeaseLost
+	}
+	return nil
+}
+
+func (s *fakeStore) CompleteJob(ctx context.Context, job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.leaseLost {
+		return postgres.ErrLeaseLost
+	}
+	s.completed = append(s.completed, job.ID)
+	return nil
+}
+
+func (s *fakeStore) RetryJob(ctx context.Context, job Job, at time.Time, cause error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.leaseLost {
+		return postgres.ErrLeaseLost
+	}
+	s.retried[job.ID] = at
+	return nil
+}
+
+func (s *fakeStore) FailJob(ctx context.Context, job Job, cause error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.leaseLost {
+		return postgres.ErrLeaseLost
+	}
+	s.failed[job.ID] = cause
+	return nil
+}
+
+func (s *fakeStore) FailAbandonedJobs(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.abandoned++
+	return 0, nil
+}
+
+func (s *fakeStore) PurgeCompletedJobs(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func newTestWorker(store *fakeStore, registry *Registry, config Config) *Worker {
+	return NewWorker(store, registry, slog.New(slog.DiscardHandler), config)
+}
+
+func TestWorkerRecordsOutcomes(t *testing.T) {
+	errTransient := errors.New("service unavailable")
+	registry := NewRegistry()
+	registry.Register("ok", func(ctx context.Context, job Job) error { return nil })
+	registry.Register("transient", func(ctx context.Context, job Job) error { return errTransient })
+	registry.Register("permanent", func(ctx context.Context, job Job) error { return Permanent(errTransient) })
+	registry.Register("panic", func(ctx context.Context, job Job) error { panic("boom") })
+
+	tests := []struct {
+		name    string
+		job     Job
+		outcome string
+	}{
+		{"success", Job{Kind: "ok", Attempts: 1, MaxAttempts: 3}, "completed"},
+		{"transient error", Job{Kind: "transient", Attempts: 1, MaxAttempts: 3}, "retried"},
+		{"final attempt", Job{Kind: "transient", Attempts: 3, MaxAttempts: 3}, "failed"},
+		{"permanent error", Job{Kind: "permanent", Attempts: 1, MaxAttempts: 3}, "failed"},
+		{"panic", Job{Kind: "panic", Attempts: 1, MaxAttempts: 3}, "retried"},
+		{"unknown kind", Job{Kind: "unknown", Attempts: 1, MaxAttempts: 3}, "failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeStore()
+			worker := newTestWorker(store, registry, Config{InitialBackoff: time.Minute, MaxBackoff: time.Hour})
+			tt.job.ID = uuid.New()
+
+			worker.slots <- struct{}{}
+			before := time.Now()
+			worker.run(context.Background(), tt.job)
+
+			var outcome string
+			switch {
+			case len(store.completed) == 1:
+				outcome = "completed"
+			case len(store.retried) == 1:
+				outcome = "retried"
+				if delay := store.retried[tt.job.ID].Sub(before); delay < 30*time.Second || delay > time.Minute+time.Second {
+					t.Errorf("retry delay %s outside [30s, 1m]", delay)
+				}
+			case len(store.failed) == 1:
+				outcome = "failed"
+			}
+			if outcome != tt.outcome {
+				t.Errorf("job was %q, want %q", outcome, tt.outcome)
+			}
+			if len(worker.slots) != 0 {
+				t.Error("job did not release its slot")
+			}
+		})
+	}
+}
+
+func TestWorkerCancelsJobWhenLeaseIsLost(t *testing.T) {
+	store := newFakeStore()
+	store.leaseLost = true
+
+	cancelled := make(chan struct{})
+	registry := NewRegistry()
+	registry.Register("slow", func(ctx context.Context, job Job) error {
+		select {
+		case <-ctx.Done():
+			close(cancelled)
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			return nil
+		}
+	})
+	worker := newTestWorker(store, registry, Config{Lease: 30 * time.Millisecond})
+
+	worker.slots <- struct{}{}
+	worker.run(context.Background(), Job{ID: uuid.New(), Kind: "slow", Attempts: 1, MaxAttempts: 3})
+
+	select {
+	case <-cancelled:
+	default:
+		t.Fatal("job kept running after its lease was lost")
+	}
+	if len(store.completed)+len(store.retried)+len(store.failed) != 0 {
+		t.Error("outcome of an attempt that lost its lease was recorded")
+	}
+}
+
+func TestWorkerDrainsRunningJobs(t *testing.T) {
+	job := Job{ID: uuid.New(), Kind: "slow", Attempts: 1, MaxAttempts: 3}
+	store := newFakeStore(job)
+
+	started := make(chan struct{})
+	registry := NewRegistry()
+	registry.Register("slow", func(ctx context.Context, job Job) error {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		return ctx.Err()
+	})
+	config := Config{PollInterval: 10 * time.Millisecond, DrainTimeout: time.Second}
+	worker := newTestWorker(store, registry, config)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- worker.Run(ctx) }()
+
+	<-started
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+	case <-time.After(config.StopTimeout()):
+		t.Fatalf("Run did not return within the stop timeout %s", config.StopTimeout())
+	}
+
+	// The job finished within the drain timeout, so it completed rather than being cancelled
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if len(store.completed) != 1 || store.completed[0] != job.ID {
+		t.Errorf("completed %v, want %s recorded before Run returned", store.completed, job.ID)
+	}
+}
+
+func TestWorkerCancelsJobsAfterDrainTimeout(t *testing.T) {
+	job := Job{ID: uuid.New(), Kind: "stuck", Attempts: 1, MaxAttempts: 3}
+	store := newFakeStore(job)
+
+	started := make(chan struct{})
+	registry := NewRegistry()
+	registry.Register("stuck", func(ctx context.Context, job Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	worker := newTestWorker(store, registry, Config{PollInterval: 10 * time.Millisecond, DrainTimeout: 20 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- worker.Run(ctx) }()
+
+	<-started
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not cancel the job after the drain timeout")
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if _, retried := store.retried[job.ID]; !retried {
+		t.Error("job cancelled by the drain timeout was not rescheduled")
+	}
+}
+
+func TestWorkerFailsAbandonedJobs(t *testing.T) {
+	store := newFakeStore()
+	registry := NewRegistry()
+	registry.Register("noop", func(ctx context.Context, job Job) error { return nil })
+	worker := newTestWorker(store, registry, Config{PollInterval: 5 * time.Millisecond, Lease: 10 * time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := worker.Run(ctx); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.abandoned == 0 {
+		t.Error("abandoned jobs were never failed")
+	}
+}
+
+func TestStopTimeout(t *testing.T) {
+	tests := []struct {
+		config Config
+		want   time.Duration
+	}{
+		{Config{}, 30*time.Second + stopMargin},
+		{Config{DrainTimeout: time.Minute}, time.Minute + stopMargin},
+	}
+
+	for _, tt := range tests {
+		if got := tt.config.StopTimeout(); got != tt.want {
+			t.Errorf("StopTimeout with drain timeout %s = %s, want %s", tt.config.DrainTimeout, got, tt.want)
+		}
+	}
+}
+
+func TestWorkerBackoff(t *testing.T) {
+	worker := newTestWorker(newFakeStore(), NewRegistry(), Config{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second})
+
+	tests := []struct {
+		attempts int
+		max      time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{50, 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		for range 20 {
+			if delay := worker.backoff(tt.attempts); delay < tt.max/2 || delay > tt.max {
+				t.Fatalf("attempt %d: backoff %s outside [%s, %s]", tt.attempts, delay, tt.max/2, tt.max)
+			}
+		}
+	}
+}
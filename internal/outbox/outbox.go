@@ -0,0 +1,69 @@
+// Package outbox delivers events written to the transactional outbox with
+// postgres.EnqueueEvent. The Relay claims pending events and hands them to a
+// Publisher, retrying failures with backoff until they are dead-lettered.
+// Delivery is at-least-once, so consumers must tolerate duplicates, e.g. by
+// deduplicating on Event.ID.
+package outbox
+
+import (
+	"context"
+	"sync"
+
+	"github.com/travisbale/go-template/internal/db/postgres"
+)
+
+// Event is an event read from the outbox
+type Event = postgres.OutboxEvent
+
+// Publisher delivers events to a broker or to in-process consumers. An error
+// leaves the event in the outbox to be retried.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Handler consumes events published in-process
+type Handler func(ctx context.Context, event Event) error
+
+// InProcessPublisher dispatches events to handlers subscribed in the same
+// process and records everything it publishes. It suits services without a
+// broker, and tests that assert on emitted events.
+type InProcessPublisher struct {
+	mu        sync.RWMutex
+	handlers  map[string][]Handler
+	published []Event
+}
+
+// NewInProcessPublisher creates a publisher with no subscribers
+func NewInProcessPublisher() *InProcessPublisher {
+	return &InProcessPublisher{handlers: make(map[string][]Handler)}
+}
+
+// Subscribe registers a handler for a topic
+func (p *InProcessPublisher) Subscribe(topic string, handler Handler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.handlers[topic] = append(p.handlers[topic], handler)
+}
+
+// Publish calls every handler subscribed to the event's topic, stopping at
+// the first error so that the event is retried
+func (p *InProcessPublisher) Publish(ctx context.Context, event Event) error {
+	p.mu.Lock()
+	p.published = append(p.published, event)
+	handlers := p.handlers[event.Topic]
+	p.mu.Unlock()
+
+	for _, handler := range handlers {
+		if err := handler(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Published returns the events published so far, including failed attempts
+func (p *InProcessPublisher) Published() []Event {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append([]Event(nil), p.published...)
+}
@@ -0,0 +1,61 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestInProcessPublisher(t *testing.T) {
+	publisher := NewInProcessPublisher()
+
+	var calls []string
+	handlerErr := errors.New("handler failed")
+	publisher.Subscribe("orders", func(ctx context.Context, event Event) error {
+		calls = append(calls, "first")
+		if event.Key == "bad" {
+			return handlerErr
+		}
+		return nil
+	})
+	publisher.Subscribe("orders", func(ctx context.Context, event Event) error {
+		calls = append(calls, "second")
+		return nil
+	})
+	publisher.Subscribe("invoices", func(ctx context.Context, event Event) error {
+		calls = append(calls, "invoices")
+		return nil
+	})
+
+	if err := publisher.Publish(context.Background(), Event{ID: uuid.New(), Topic: "orders"}); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if len(calls) != 2 || calls[0] != "first" || calls[1] != "second" {
+		t.Errorf("got calls %v, want the orders handlers in order", calls)
+	}
+
+	// The first error stops dispatch and is returned so that the relay retries
+	calls = nil
+	if err := publisher.Publish(context.Background(), Event{ID: uuid.New(), Topic: "orders", Key: "bad"}); !errors.Is(err, handlerErr) {
+		t.Errorf("got error %v, want %v", err, handlerErr)
+	}
+	if len(calls) != 1 {
+		t.Errorf("got calls %v, want dispatch to stop at the failing handler", calls)
+	}
+
+	// Topics without subscribers are still recorded
+	if err := publisher.Publish(context.Background(), Event{ID: uuid.New(), Topic: "unknown"}); err != nil {
+		t.Errorf("Publish without subscribers failed: %v", err)
+	}
+
+	published := publisher.Published()
+	if len(published) != 3 {
+		t.Fatalf("recorded %d events, want 3 including the failed attempt", len(published))
+	}
+	published[0].Topic = "changed"
+	if publisher.Published()[0].Topic != "orders" {
+		t.Error("Published returned the publisher's own slice")
+	}
+}
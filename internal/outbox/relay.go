@@ -0,0 +1,241 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/travisbale/go-template/internal/db/postgres"
+)
+
+type logger interface {
+	Info(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// store is the outbox table, implemented by postgres.DB
+type store interface {
+	ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]Event, error)
+	ExtendOutboxLease(ctx context.Context, events []Event, lease time.Duration) ([]uuid.UUID, error)
+	MarkOutboxEventDelivered(ctx context.Context, event Event) error
+	RetryOutboxEvent(ctx context.Context, event Event, at time.Time, cause error) error
+	DeadLetterOutboxEvent(ctx context.Context, event Event, cause error) error
+	PurgeDeliveredOutboxEvents(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Config tunes the relay. Zero values use the defaults noted on each field.
+type Config struct {
+	// PollInterval is how often the outbox is checked when it is empty (1s)
+	PollInterval time.Duration
+	// BatchSize is the number of events claimed at once (100)
+	BatchSize int
+	// Lease is how long a claimed event is hidden from other relays. It is
+	// renewed while the batch is delivered, so it only bounds recovery after a
+	// crash, and is raised to twice PublishTimeout if shorter (30s).
+	Lease time.Duration
+	// PublishTimeout bounds a single delivery attempt (10s)
+	PublishTimeout time.Duration
+	// MaxAttempts is the number of attempts before an event is dead-lettered (10)
+	MaxAttempts int
+	// InitialBackoff and MaxBackoff bound the delay between attempts (1s, 1h)
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Retention is how long delivered events are kept (7 days)
+	Retention time.Duration
+}
+
+func (c *Config) setDefaults() {
+	defaults := Config{
+		PollInterval:   time.Second,
+		BatchSize:      100,
+		Lease:          30 * time.Second,
+		PublishTimeout: 10 * time.Second,
+		MaxAttempts:    10,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Hour,
+		Retention:      7 * 24 * time.Hour,
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = defaults.PollInterval
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaults.BatchSize
+	}
+	if c.Lease <= 0 {
+		c.Lease = defaults.Lease
+	}
+	if c.PublishTimeout <= 0 {
+		c.PublishTimeout = defaults.PublishTimeout
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = defaults.MaxAttempts
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = defaults.InitialBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = defaults.MaxBackoff
+	}
+	if c.Retention <= 0 {
+		c.Retention = defaults.Retention
+	}
+
+	// Renewing at half the lease must leave time for a whole delivery
+	c.Lease = max(c.Lease, 2*c.PublishTimeout)
+}
+
+// Relay moves events from the outbox to a Publisher
+type Relay struct {
+	store     store
+	publisher Publisher
+	logger    logger
+	config    Config
+}
+
+// NewRelay creates a relay. Several relays, in one process or many, can share
+// an outbox; each event is claimed by one of them at a time.
+func NewRelay(store store, publisher Publisher, logger logger, config Config) *Relay {
+	config.setDefaults()
+	return &Relay{store: store, publisher: publisher, logger: logger, config: config}
+}
+
+// Run delivers events until ctx is cancelled. Events claimed but not yet
+// delivered when it returns are picked up again once their lease expires.
+func (r *Relay) Run(ctx context.Context) error {
+	r.logger.Info("Starting outbox relay", "poll_interval", r.config.PollInterval, "batch_size", r.config.BatchSize)
+
+	purge := time.NewTicker(time.Hour)
+	defer purge.Stop()
+
+	for {
+		claimed, err := r.relayBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.logger.Error("failed to relay outbox events", "error", err)
+		}
+
+		// Keep draining while batches come back full
+		wait := r.config.PollInterval
+		if claimed == r.config.BatchSize {
+			wait = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-purge.C:
+			r.purge(ctx)
+		case <-time.After(wait):
+		}
+	}
+}
+
+// relayBatch claims and delivers one batch, returning how many events were
+// claimed. The lease on the undelivered events is renewed once half of it has
+// passed, so that a slow publisher does not let other relays claim them.
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	leaseEnds := time.Now().Add(r.config.Lease)
+	events, err := r.store.ClaimOutboxEvents(ctx, r.config.BatchSize, r.config.Lease)
+	if err != nil {
+		return 0, err
+	}
+
+	pending := events
+	for len(pending) > 0 && ctx.Err() == nil {
+		if time.Until(leaseEnds) < r.config.Lease/2 {
+			renewedAt := time.Now()
+			if pending, err = r.renew(ctx, pending); err != nil {
+				return len(events), err
+			}
+			leaseEnds = renewedAt.Add(r.config.Lease)
+			continue
+		}
+
+		r.deliver(ctx, pending[0])
+		pending = pending[1:]
+	}
+	return len(events), nil
+}
+
+// renew extends the lease on events not yet delivered and returns those the
+// relay still holds
+func (r *Relay) renew(ctx context.Context, events []Event) ([]Event, error) {
+	held, err := r.store.ExtendOutboxLease(ctx, events, r.config.Lease)
+	if err != nil {
+		return nil, err
+	}
+
+	holding := make(map[uuid.UUID]bool, len(held))
+	for _, id := range held {
+		holding[id] = true
+	}
+	kept := make([]Event, 0, len(held))
+	for _, event := range events {
+		if holding[event.ID] {
+			kept = append(kept, event)
+		}
+	}
+	if lost := len(events) - len(kept); lost > 0 {
+		r.logger.Info("Outbox events were claimed by another relay, skipping them", "count", lost)
+	}
+	return kept, nil
+}
+
+// deliver publishes a single event and records the outcome
+func (r *Relay) deliver(ctx context.Context, event Event) {
+	publishCtx, cancel := context.WithTimeout(ctx, r.config.PublishTimeout)
+	err := r.publisher.Publish(publishCtx, event)
+	cancel()
+
+	if err == nil {
+		r.record(event, "mark outbox event delivered", r.store.MarkOutboxEventDelivered(ctx, event))
+		return
+	}
+
+	if event.Attempts >= r.config.MaxAttempts {
+		r.logger.Error("Dead-lettering outbox event", "event_id", event.ID, "topic", event.Topic, "attempts", event.Attempts, "error", err)
+		r.record(event, "dead-letter outbox event", r.store.DeadLetterOutboxEvent(ctx, event, err))
+		return
+	}
+
+	retryAt := time.Now().Add(r.backoff(event.Attempts))
+	r.logger.Info("Outbox event delivery failed, retrying", "event_id", event.ID, "topic", event.Topic, "attempt", event.Attempts, "retry_at", retryAt, "error", err)
+	r.record(event, "reschedule outbox event", r.store.RetryOutboxEvent(ctx, event, retryAt, fmt.Errorf("attempt %d: %w", event.Attempts, err)))
+}
+
+// record logs the failure to store the outcome of a delivery. Losing the
+// lease is expected after a stall: the relay that claimed the event next owns
+// its state and will deliver it again.
+func (r *Relay) record(event Event, operation string, err error) {
+	switch {
+	case err == nil:
+	case errors.Is(err, postgres.ErrLeaseLost):
+		r.logger.Info("Outbox event was claimed by another relay, leaving its state alone", "event_id", event.ID, "attempt", event.Attempts)
+	default:
+		r.logger.Error("failed to "+operation, "event_id", event.ID, "error", err)
+	}
+}
+
+// backoff doubles the delay with each attempt, with jitter so that events
+// that failed together are not retried together
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay := r.config.InitialBackoff
+	for i := 1; i < attempts && delay < r.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, r.config.MaxBackoff)
+	return delay/2 + rand.N(delay/2+1)
+}
+
+func (r *Relay) purge(ctx context.Context) {
+	deleted, err := r.store.PurgeDeliveredOutboxEvents(ctx, time.Now().Add(-r.config.Retention))
+	if err != nil {
+		r.logger.Error("failed to purge delivered outbox events", "error", err)
+		return
+	}
+	if deleted > 0 {
+		r.logger.Info("Purged delivered outbox events", "count", deleted)
+	}
+}
@@ -0,0 +1,252 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/travisbale/go-template/internal/db/postgres"
+)
+
+// fakeStore records what the relay does with each event
+type fakeStore struct {
+	mu           sync.Mutex
+	pending      []Event
+	claimErr     error
+	delivered    []uuid.UUID
+	retried      map[uuid.UUID]time.Time
+	deadLettered map[uuid.UUID]error
+	renewals     int
+	stolen       map[uuid.UUID]bool
+	leaseLost    bool
+}
+
+func newFakeStore(events ...Event) *fakeStore {
+	return &fakeStore{
+		pending:      events,
+		retried:      make(map[uuid.UUID]time.Time),
+		deadLettered: make(map[uuid.UUID]error),
+		stolen:       make(map[uuid.UUID]bool),
+	}
+}
+
+func (s *fakeStore) ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.claimErr != nil {
+		return nil, s.claimErr
+	}
+	claimed := s.pending[:min(limit, len(s.pending))]
+	s.pending = s.pending[len(claimed):]
+	return claimed, nil
+}
+
+// ExtendOutboxLease holds every event except those another relay has stolen
+func (s *fakeStore) ExtendOutboxLease(ctx context.Context, events []Event, lease time.Duration) ([]uuid.UUID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.renewals++
+	var held []uuid.UUID
+	for _, event := range events {
+		if !s.stolen[event.ID] {
+			held = append(held, event.ID)
+		}
+	}
+	return held, nil
+}
+
+func (s *fakeStore) MarkOutboxEventDelivered(ctx context.Context, event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.leaseLost {
+		return postgres.ErrLeaseLost
+	}
+	s.delivered = append(s.delivered, event.ID)
+	return nil
+}
+
+func (s *fakeStore) RetryOutboxEvent(ctx context.Context, event Event, at time.Time, cause error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retried[event.ID] = at
+	return nil
+}
+
+func (s *fakeStore) DeadLetterOutboxEvent(ctx context.Context, event Event, cause error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deadLettered[event.ID] = cause
+	return nil
+}
+
+func (s *fakeStore) PurgeDeliveredOutboxEvents(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+// publisherFunc adapts a function to Publisher
+type publisherFunc func(ctx context.Context, event Event) error
+
+func (f publisherFunc) Publish(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+func newTestRelay(store *fakeStore, publisher Publisher, config Config) *Relay {
+	return NewRelay(store, publisher, slog.New(slog.DiscardHandler), config)
+}
+
+func TestRelayBatchRecordsOutcomes(t *testing.T) {
+	ok := Event{ID: uuid.New(), Topic: "ok", Attempts: 1}
+	failing := Event{ID: uuid.New(), Topic: "fail", Attempts: 2}
+	exhausted := Event{ID: uuid.New(), Topic: "fail", Attempts: 3}
+	store := newFakeStore(ok, failing, exhausted)
+
+	publishErr := errors.New("broker unavailable")
+	relay := newTestRelay(store, publisherFunc(func(ctx context.Context, event Event) error {
+		if event.Topic == "fail" {
+			return publishErr
+		}
+		return nil
+	}), Config{MaxAttempts: 3, InitialBackoff: time.Minute, MaxBackoff: time.Hour})
+
+	before := time.Now()
+	claimed, err := relay.relayBatch(context.Background())
+	if err != nil {
+		t.Fatalf("relayBatch failed: %v", err)
+	}
+	if claimed != 3 {
+		t.Errorf("claimed %d events, want 3", claimed)
+	}
+
+	if len(store.delivered) != 1 || store.delivered[0] != ok.ID {
+		t.Errorf("delivered %v, want only %s", store.delivered, ok.ID)
+	}
+
+	// The second attempt waits between half and all of twice the initial backoff
+	retryAt, retried := store.retried[failing.ID]
+	if !retried {
+		t.Fatal("failed event was not rescheduled")
+	}
+	if delay := retryAt.Sub(before); delay < time.Minute || delay > 2*time.Minute+time.Second {
+		t.Errorf("retry delay %s outside [1m, 2m]", delay)
+	}
+
+	cause, deadLettered := store.deadLettered[exhausted.ID]
+	if !deadLettered || !errors.Is(cause, publishErr) {
+		t.Errorf("exhausted event dead-lettered with %v, want %v", cause, publishErr)
+	}
+	if _, retried := store.retried[exhausted.ID]; retried {
+		t.Error("exhausted event was rescheduled")
+	}
+}
+
+func TestRelayBatchReturnsClaimError(t *testing.T) {
+	store := newFakeStore()
+	store.claimErr = errors.New("connection refused")
+	relay := newTestRelay(store, publisherFunc(func(ctx context.Context, event Event) error { return nil }), Config{})
+
+	if _, err := relay.relayBatch(context.Background()); !errors.Is(err, store.claimErr) {
+		t.Errorf("got error %v, want %v", err, store.claimErr)
+	}
+}
+
+func TestRelayBatchStopsWhenCancelled(t *testing.T) {
+	store := newFakeStore(Event{ID: uuid.New()}, Event{ID: uuid.New()})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	relay := newTestRelay(store, publisherFunc(func(ctx context.Context, event Event) error {
+		cancel()
+		return nil
+	}), Config{})
+
+	if _, err := relay.relayBatch(ctx); err != nil {
+		t.Fatalf("relayBatch failed: %v", err)
+	}
+	if len(store.delivered) != 1 {
+		t.Errorf("delivered %d events after cancellation, want 1", len(store.delivered))
+	}
+}
+
+func TestRelayBatchRenewsLease(t *testing.T) {
+	first, stolen, last := Event{ID: uuid.New()}, Event{ID: uuid.New()}, Event{ID: uuid.New()}
+	store := newFakeStore(first, stolen, last)
+	store.stolen[stolen.ID] = true
+
+	// Each delivery takes most of the lease, so it must be renewed between them
+	var published []uuid.UUID
+	relay := newTestRelay(store, publisherFunc(func(ctx context.Context, event Event) error {
+		published = append(published, event.ID)
+		time.Sleep(15 * time.Millisecond)
+		return nil
+	}), Config{Lease: 20 * time.Millisecond, PublishTimeout: 10 * time.Millisecond})
+
+	if _, err := relay.relayBatch(context.Background()); err != nil {
+		t.Fatalf("relayBatch failed: %v", err)
+	}
+	if store.renewals == 0 {
+		t.Error("lease was not renewed during the batch")
+	}
+	if len(published) != 2 || published[0] != first.ID || published[1] != last.ID {
+		t.Errorf("published %v, want %s and %s but not the stolen event", published, first.ID, last.ID)
+	}
+}
+
+func TestRelayBatchLeavesReclaimedEvents(t *testing.T) {
+	store := newFakeStore(Event{ID: uuid.New(), Attempts: 1})
+	store.leaseLost = true
+	relay := newTestRelay(store, publisherFunc(func(ctx context.Context, event Event) error { return nil }), Config{})
+
+	if _, err := relay.relayBatch(context.Background()); err != nil {
+		t.Fatalf("relayBatch failed: %v", err)
+	}
+	if len(store.delivered) != 0 {
+		t.Errorf("recorded %v as delivered after losing the lease", store.delivered)
+	}
+}
+
+func TestLeaseCoversPublishTimeout(t *testing.T) {
+	tests := []struct {
+		config Config
+		want   time.Duration
+	}{
+		{Config{}, 30 * time.Second},
+		{Config{Lease: time.Second, PublishTimeout: 10 * time.Second}, 20 * time.Second},
+		{Config{PublishTimeout: time.Minute}, 2 * time.Minute},
+		{Config{Lease: time.Hour}, time.Hour},
+	}
+
+	for _, tt := range tests {
+		tt.config.setDefaults()
+		if tt.config.Lease != tt.want {
+			t.Errorf("lease = %s with publish timeout %s, want %s", tt.config.Lease, tt.config.PublishTimeout, tt.want)
+		}
+	}
+}
+
+func TestRelayBackoff(t *testing.T) {
+	relay := newTestRelay(newFakeStore(), nil, Config{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second})
+
+	tests := []struct {
+		attempts int
+		max      time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{50, 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		for range 20 {
+			if delay := relay.backoff(tt.attempts); delay < tt.max/2 || delay > tt.max {
+				t.Fatalf("attempt %d: backoff %s outside [%s, %s]", tt.attempts, delay, tt.max/2, tt.max)
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/travisbale/go-template/internal/db/postgres"
+)
+
+// Channel is the Postgres notification channel that carries hub messages
+const Channel = "pubsub"
+
+type logger interface {
+	Info(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+type notifier interface {
+	Notify(ctx context.Context, channel, payload string) error
+}
+
+type listener interface {
+	Subscribe(ctx context.Context, channel string) (*postgres.Subscription, error)
+}
+
+// Notify publishes a message to the hubs of every replica. Inside a
+// transaction it is sent when the transaction commits. Messages are limited
+// to the 8000 byte notification payload, so send IDs rather than records.
+// Messages with neither a tenant nor Broadcast set are rejected.
+func Notify(ctx context.Context, db notifier, msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+	return db.Notify(ctx, Channel, string(payload))
+}
+
+// Bridge publishes the messages sent with Notify to a hub
+type Bridge struct {
+	db     listener
+	hub    *Hub
+	logger logger
+}
+
+// NewBridge creates a bridge from Postgres notifications to the hub
+func NewBridge(db listener, hub *Hub, logger logger) *Bridge {
+	return &Bridge{db: db, hub: hub, logger: logger}
+}
+
+// Run relays notifications until ctx is cancelled or the database is closed.
+// The listener reconnects on its own, but a subscription that lost
+// notifications ends, so Run then disconnects the hub's subscribers, whose
+// clients reconnect and reload, and subscribes again.
+func (b *Bridge) Run(ctx context.Context) error {
+	for {
+		sub, err := b.db.Subscribe(ctx, Channel)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		b.relay(sub)
+		err = sub.Err()
+		sub.Close()
+		if err == nil || ctx.Err() != nil {
+			return nil
+		}
+
+		b.logger.Error("Pubsub notifications were lost, disconnecting subscribers", "error", err)
+		b.hub.Disconnect(ErrMessagesLost)
+	}
+}
+
+// relay publishes notifications to the hub until the subscription ends
+func (b *Bridge) relay(sub *postgres.Subscription) {
+	for notification := range sub.Notifications() {
+		var msg Message
+		if err := json.Unmarshal([]byte(notification.Payload), &msg); err != nil {
+			b.logger.Error("failed to decode pubsub notification", "error", err)
+			continue
+		}
+		if err := b.hub.Publish(msg); err != nil {
+			b.logger.Error("Dropped pubsub notification", "topic", msg.Topic, "error", err)
+		}
+	}
+}
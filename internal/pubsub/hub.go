@@ -0,0 +1,192 @@
+// Package pubsub fans messages out to in-process subscribers, such as the
+// HTTP event streams. Messages reach the hubs of every replica by being sent
+// through Postgres with Notify and relayed into each hub by a Bridge.
+package pubsub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrSlowConsumer ends a subscription whose buffer filled up, so that a
+	// stalled client cannot hold up delivery to everyone else
+	ErrSlowConsumer = errors.New("subscriber fell behind")
+	// ErrMessagesLost ends subscriptions when messages may not have reached
+	// the hub, so that clients reconnect and reload instead of missing them
+	ErrMessagesLost = errors.New("messages were lost")
+	// ErrClosed ends subscriptions when the hub shuts down
+	ErrClosed = errors.New("hub closed")
+)
+
+// subscriptionBuffer is the number of messages held for a subscriber before
+// it is disconnected as a slow consumer
+const subscriptionBuffer = 64
+
+// Message is published to every subscriber of its topic in its tenant
+type Message struct {
+	Topic string `json:"topic"`
+	// TenantID restricts delivery to subscribers of the tenant. Messages
+	// without a tenant are dropped unless Broadcast is set, so that a
+	// forgotten tenant cannot leak a message to every tenant.
+	TenantID uuid.UUID `json:"tenant_id"`
+	// Broadcast delivers the message to subscribers of every tenant. It
+	// cannot be combined with TenantID.
+	Broadcast bool            `json:"broadcast,omitempty"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// validate checks that the message names exactly one audience
+func (m Message) validate() error {
+	switch {
+	case m.Broadcast && m.TenantID != uuid.Nil:
+		return fmt.Errorf("broadcast message must not have a tenant")
+	case !m.Broadcast && m.TenantID == uuid.Nil:
+		return fmt.Errorf("message must have a tenant or be a broadcast")
+	}
+	return nil
+}
+
+// Subscription receives the messages published to its topics
+type Subscription struct {
+	hub      *Hub
+	tenantID uuid.UUID
+	topics   []string
+	ch       chan Message
+
+	// err is set, under the hub's mu, before ch is closed
+	err error
+}
+
+// Messages returns the channel messages are delivered on. It is closed when
+// the subscription ends, after which Err reports why.
+func (s *Subscription) Messages() <-chan Message {
+	return s.ch
+}
+
+// Err returns ErrSlowConsumer, ErrMessagesLost or ErrClosed once the hub has ended the
+// subscription, and nil while it is open or if it was closed by the subscriber
+func (s *Subscription) Err() error {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.err
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s, nil)
+}
+
+// Hub routes published messages to subscriptions by topic and tenant
+type Hub struct {
+	mu     sync.Mutex
+	topics map[string]map[*Subscription]struct{}
+	closed bool
+}
+
+// NewHub creates a hub with no subscribers
+func NewHub() *Hub {
+	return &Hub{topics: make(map[string]map[*Subscription]struct{})}
+}
+
+// Subscribe creates a subscription to the given topics for a tenant
+func (h *Hub) Subscribe(tenantID uuid.UUID, topics ...string) (*Subscription, error) {
+	if len(topics) == 0 {
+		return nil, fmt.Errorf("at least one topic is required")
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, ErrClosed
+	}
+
+	sub := &Subscription{
+		hub:      h,
+		tenantID: tenantID,
+		topics:   topics,
+		ch:       make(chan Message, subscriptionBuffer),
+	}
+	for _, topic := range topics {
+		if h.topics[topic] == nil {
+			h.topics[topic] = make(map[*Subscription]struct{})
+		}
+		h.topics[topic][sub] = struct{}{}
+	}
+	return sub, nil
+}
+
+// Publish delivers a message to the topic's subscribers without blocking.
+// Subscribers whose buffer is full are disconnected with ErrSlowConsumer.
+// Messages with neither a tenant nor Broadcast set are rejected.
+func (h *Hub) Publish(msg Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.topics[msg.Topic] {
+		if !msg.Broadcast && msg.TenantID != sub.tenantID {
+			continue
+		}
+		select {
+		case sub.ch <- msg:
+		default:
+			h.remove(sub, ErrSlowConsumer)
+		}
+	}
+	return nil
+}
+
+// Disconnect ends every subscription with err but keeps accepting new ones
+func (h *Hub) Disconnect(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, subs := range h.topics {
+		for sub := range subs {
+			h.remove(sub, err)
+		}
+	}
+}
+
+// Close ends every subscription with ErrClosed and rejects new ones
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for _, subs := range h.topics {
+		for sub := range subs {
+			h.remove(sub, ErrClosed)
+		}
+	}
+}
+
+// remove unsubscribes sub and closes its channel. The caller must hold mu.
+func (h *Hub) remove(sub *Subscription, err error) {
+	removed := false
+	for _, topic := range sub.topics {
+		if _, ok := h.topics[topic][sub]; !ok {
+			continue
+		}
+		removed = true
+		delete(h.topics[topic], sub)
+		if len(h.topics[topic]) == 0 {
+			delete(h.topics, topic)
+		}
+	}
+	if removed {
+		sub.err = err
+		close(sub.ch)
+	}
+}
@@ -0,0 +1,126 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// receive returns the messages buffered for a subscription
+func receive(sub *Subscription) []Message {
+	var messages []Message
+	for {
+		select {
+		case msg, ok := <-sub.Messages():
+			if !ok {
+				return messages
+			}
+			messages = append(messages, msg)
+		default:
+			return messages
+		}
+	}
+}
+
+func TestHubPublishAudience(t *testing.T) {
+	hub := NewHub()
+	tenantA, tenantB := uuid.New(), uuid.New()
+
+	subA, err := hub.Subscribe(tenantA, "orders")
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	subB, err := hub.Subscribe(tenantB, "orders")
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	if err := hub.Publish(Message{Topic: "orders", TenantID: tenantA}); err != nil {
+		t.Fatalf("Publish to tenant failed: %v", err)
+	}
+	if err := hub.Publish(Message{Topic: "orders", Broadcast: true}); err != nil {
+		t.Fatalf("Publish broadcast failed: %v", err)
+	}
+	if err := hub.Publish(Message{Topic: "orders"}); err == nil {
+		t.Error("Publish without a tenant or broadcast succeeded")
+	}
+	if err := hub.Publish(Message{Topic: "orders", TenantID: tenantA, Broadcast: true}); err == nil {
+		t.Error("Publish of a broadcast with a tenant succeeded")
+	}
+
+	if got := len(receive(subA)); got != 2 {
+		t.Errorf("tenant A received %d messages, want its own and the broadcast", got)
+	}
+	if got := len(receive(subB)); got != 1 {
+		t.Errorf("tenant B received %d messages, want only the broadcast", got)
+	}
+}
+
+func TestHubEndsSubscriptions(t *testing.T) {
+	hub := NewHub()
+	tenantID := uuid.New()
+
+	slow, err := hub.Subscribe(tenantID, "orders")
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	for range subscriptionBuffer + 1 {
+		if err := hub.Publish(Message{Topic: "orders", TenantID: tenantID}); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+	receive(slow)
+	if !errors.Is(slow.Err(), ErrSlowConsumer) {
+		t.Errorf("slow subscriber ended with %v, want %v", slow.Err(), ErrSlowConsumer)
+	}
+
+	sub, err := hub.Subscribe(tenantID, "orders")
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	hub.Disconnect(ErrMessagesLost)
+	if _, ok := <-sub.Messages(); ok || !errors.Is(sub.Err(), ErrMessagesLost) {
+		t.Errorf("disconnected subscriber ended with %v, want %v", sub.Err(), ErrMessagesLost)
+	}
+	if _, err := hub.Subscribe(tenantID, "orders"); err != nil {
+		t.Errorf("Subscribe after Disconnect failed: %v", err)
+	}
+
+	hub.Close()
+	if _, err := hub.Subscribe(tenantID, "orders"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Subscribe after Close returned %v, want %v", err, ErrClosed)
+	}
+}
+
+// notifierFunc adapts a function to notifier
+type notifierFunc func(ctx context.Context, channel, payload string) error
+
+func (f notifierFunc) Notify(ctx context.Context, channel, payload string) error {
+	return f(ctx, channel, payload)
+}
+
+func TestNotifyRejectsMessageWithoutAudience(t *testing.T) {
+	var sent []string
+	db := notifierFunc(func(ctx context.Context, channel, payload string) error {
+		sent = append(sent, payload)
+		return nil
+	})
+
+	if err := Notify(context.Background(), db, Message{Topic: "orders"}); err == nil {
+		t.Error("Notify without a tenant or broadcast succeeded")
+	}
+	if err := Notify(context.Background(), db, Message{Topic: "orders", Broadcast: true}); err != nil {
+		t.Fatalf("Notify broadcast failed: %v", err)
+	}
+
+	if len(sent) != 1 {
+		t.Fatalf("sent %d notifications, want 1", len(sent))
+	}
+	var msg Message
+	if err := json.Unmarshal([]byte(sent[0]), &msg); err != nil || !msg.Broadcast {
+		t.Errorf("sent %s, want a broadcast message", sent[0])
+	}
+}
@@ -0,0 +1,400 @@
+// Package scheduler runs tasks on cron schedules. Every replica runs a
+// Scheduler, but only the one holding the lease in Postgres fires tasks, and
+// each occurrence of a schedule is recorded so that it runs at most once.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+)
+
+type logger interface {
+	Info(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// store holds the leader lease and run history, implemented by postgres.DB
+type store interface {
+	AcquireSchedulerLease(ctx context.Context, name, holder string, lease time.Duration) (bool, error)
+	ReleaseSchedulerLease(ctx context.Context, name, holder string) error
+	LatestScheduledRuns(ctx context.Context) (map[string]time.Time, error)
+	StartScheduledRun(ctx context.Context, task string, scheduledAt time.Time, holder string) (uuid.UUID, bool, error)
+	FinishScheduledRun(ctx context.Context, id uuid.UUID, cause error) error
+	AbandonScheduledRuns(ctx context.Context, holder string) (int64, error)
+	PurgeScheduledRuns(ctx context.Context, before time.Time) (int64, error)
+}
+
+// CatchUpPolicy decides what happens to occurrences that were missed because
+// no replica was running or the task was still busy
+type CatchUpPolicy int
+
+const (
+	// CatchUpOnce runs the task once for any number of missed occurrences
+	CatchUpOnce CatchUpPolicy = iota
+	// CatchUpSkip drops missed occurrences and waits for the next one
+	CatchUpSkip
+	// CatchUpAll runs every missed occurrence in order, up to Config.MaxCatchUp
+	CatchUpAll
+)
+
+// Task is a function run on a cron schedule
+type Task struct {
+	// Name identifies the task in the run history and must be unique
+	Name string
+	// Schedule is a standard five-field cron expression or a descriptor such
+	// as @hourly or @every 10m. It is evaluated in UTC unless prefixed with
+	// CRON_TZ=<zone>.
+	Schedule string
+	// CatchUp is the policy for missed occurrences (CatchUpOnce)
+	CatchUp CatchUpPolicy
+	// Timeout bounds each run; zero means no limit
+	Timeout time.Duration
+	// Run performs the task. Its ctx is cancelled when the scheduler stops or
+	// loses leadership. An occurrence is never retried, so work that must
+	// succeed should enqueue a job instead.
+	Run func(ctx context.Context) error
+}
+
+// Config tunes a scheduler. Zero values use the defaults noted on each field.
+type Config struct {
+	// LeaseName identifies the leader lease, so that separate deployments can
+	// share a database ("default")
+	LeaseName string
+	// PollInterval is how often schedules are checked (1s)
+	PollInterval time.Duration
+	// Lease is how long leadership lasts without renewal, and so how long
+	// schedules pause when the leader dies (30s)
+	Lease time.Duration
+	// Grace is how late an occurrence may fire before it counts as missed (1m)
+	Grace time.Duration
+	// MaxCatchUp limits the runs made by CatchUpAll after an outage (100)
+	MaxCatchUp int
+	// Retention is how long run history is kept (30 days)
+	Retention time.Duration
+}
+
+func (c *Config) setDefaults() {
+	if c.LeaseName == "" {
+		c.LeaseName = "default"
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = time.Second
+	}
+	if c.Lease <= 0 {
+		c.Lease = 30 * time.Second
+	}
+	if c.Grace <= 0 {
+		c.Grace = time.Minute
+	}
+	if c.MaxCatchUp <= 0 {
+		c.MaxCatchUp = 100
+	}
+	if c.Retention <= 0 {
+		c.Retention = 30 * 24 * time.Hour
+	}
+}
+
+// scheduledTask is a task with its parsed schedule and the state of its runs
+type scheduledTask struct {
+	Task
+	schedule cron.Schedule
+
+	// last is the latest occurrence that has been handled
+	last    time.Time
+	running atomic.Bool
+}
+
+// Scheduler fires tasks on their schedules while it holds the leader lease
+type Scheduler struct {
+	store  store
+	logger logger
+	config Config
+	holder string
+	tasks  []*scheduledTask
+}
+
+// NewScheduler creates a scheduler with no tasks
+func NewScheduler(store store, logger logger, config Config) *Scheduler {
+	config.setDefaults()
+
+	hostname, _ := os.Hostname()
+	return &Scheduler{
+		store:  store,
+		logger: logger,
+		config: config,
+		holder: fmt.Sprintf("%s-%s", hostname, uuid.NewString()[:8]),
+	}
+}
+
+// Add registers a task. It must be called before Run.
+func (s *Scheduler) Add(task Task) error {
+	if task.Name == "" || task.Run == nil {
+		return fmt.Errorf("scheduled task needs a name and a run function")
+	}
+	for _, existing := range s.tasks {
+		if existing.Name == task.Name {
+			return fmt.Errorf("scheduled task %q added twice", task.Name)
+		}
+	}
+
+	schedule, err := cron.ParseStandard(task.Schedule)
+	if err != nil {
+		return fmt.Errorf("failed to parse schedule of task %s: %w", task.Name, err)
+	}
+
+	s.tasks = append(s.tasks, &scheduledTask{Task: task, schedule: schedule})
+	return nil
+}
+
+// Run competes for leadership and fires due tasks while leader, until ctx is
+// cancelled. Runs get a context that lasts as long as the leadership, so that
+// a replica that loses the lease stops its tasks before the next leader
+// starts them again. On return it waits for running tasks, whose context is
+// cancelled too, and releases the lease so another replica takes over
+// immediately.
+func (s *Scheduler) Run(ctx context.Context) error {
+	if len(s.tasks) == 0 {
+		s.logger.Info("No scheduled tasks registered, scheduler idle")
+		<-ctx.Done()
+		return nil
+	}
+
+	ticker := time.NewTicker(s.config.PollInterval)
+	defer ticker.Stop()
+
+	var wg sync.WaitGroup
+	var renewAt, purgeAt time.Time
+
+	// term is nil while following
+	var term *leadership
+	defer func() {
+		if term != nil {
+			term.end()
+		}
+	}()
+
+	for {
+		now := time.Now().UTC()
+
+		if !now.Before(renewAt) {
+			leader := term != nil
+			switch held := s.renew(ctx, leader, now); {
+			case held && !leader:
+				term = newLeadership(ctx)
+			case !held && leader:
+				term.end()
+				term = nil
+			}
+			renewAt = now.Add(s.config.Lease / 3)
+		}
+
+		if term != nil {
+			s.fire(term.ctx, now, &wg)
+			if !now.Before(purgeAt) {
+				s.purge(term.ctx, now)
+				purgeAt = now.Add(time.Hour)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			if term != nil {
+				if err := s.store.ReleaseSchedulerLease(context.WithoutCancel(ctx), s.config.LeaseName, s.holder); err != nil {
+					s.logger.Error("failed to release scheduler lease", "error", err)
+				}
+			}
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// leadership is a term as leader, whose context is cancelled when it ends
+type leadership struct {
+	ctx context.Context
+	end context.CancelFunc
+}
+
+func newLeadership(ctx context.Context) *leadership {
+	ctx, cancel := context.WithCancel(ctx)
+	return &leadership{ctx: ctx, end: cancel}
+}
+
+// renew takes or renews the lease and reports whether this scheduler leads
+func (s *Scheduler) renew(ctx context.Context, leader bool, now time.Time) bool {
+	held, err := s.store.AcquireSchedulerLease(ctx, s.config.LeaseName, s.holder, s.config.Lease)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Error("failed to renew scheduler lease", "error", err)
+		}
+		// Stop firing rather than risk a second leader once the lease expires
+		held = false
+	}
+
+	switch {
+	case held && !leader:
+		if err := s.becomeLeader(ctx, now); err != nil {
+			s.logger.Error("failed to take over scheduling", "error", err)
+			return false
+		}
+		s.logger.Info("Became scheduler leader", "holder", s.holder, "tasks", len(s.tasks))
+	case !held && leader:
+		s.logger.Info("Lost scheduler leadership, cancelling running tasks", "holder", s.holder)
+	}
+	return held
+}
+
+// becomeLeader closes out runs left by a previous leader and resumes each
+// task's schedule from its latest recorded run. Tasks that have never run
+// start from now rather than catching up on their whole history.
+func (s *Scheduler) becomeLeader(ctx context.Context, now time.Time) error {
+	abandoned, err := s.store.AbandonScheduledRuns(ctx, s.holder)
+	if err != nil {
+		return err
+	}
+	if abandoned > 0 {
+		s.logger.Info("Marked interrupted scheduled runs as abandoned", "count", abandoned)
+	}
+
+	latest, err := s.store.LatestScheduledRuns(ctx)
+	if err != nil {
+		return err
+	}
+	for _, task := range s.tasks {
+		if task.running.Load() {
+			continue
+		}
+		if last, ok := latest[task.Name]; ok {
+			task.last = last.UTC()
+		} else {
+			task.last = now
+		}
+	}
+	return nil
+}
+
+// fire starts every task that has due occurrences and is not already running
+func (s *Scheduler) fire(ctx context.Context, now time.Time, wg *sync.WaitGroup) {
+	for _, task := range s.tasks {
+		if task.running.Load() {
+			continue
+		}
+
+		due, dropped := s.due(task, now)
+		if len(due) == 0 {
+			continue
+		}
+		task.last = due[len(due)-1]
+
+		runs := s.catchUp(task, due, now)
+		if skipped := dropped + len(due) - len(runs); skipped > 0 {
+			s.logger.Info("Skipping missed scheduled runs", "task", task.Name, "count", skipped)
+		}
+		if len(runs) == 0 {
+			continue
+		}
+
+		task.running.Store(true)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer task.running.Store(false)
+
+			for _, at := range runs {
+				if ctx.Err() != nil {
+					return
+				}
+				s.run(ctx, task, at)
+			}
+		}()
+	}
+}
+
+// due returns the occurrences after the task's last one up to now, keeping
+// only the most recent MaxCatchUp, and the number it dropped
+func (s *Scheduler) due(task *scheduledTask, now time.Time) ([]time.Time, int) {
+	var due []time.Time
+	dropped := 0
+	for at := task.schedule.Next(task.last); !at.IsZero() && !at.After(now); at = task.schedule.Next(at) {
+		if len(due) == s.config.MaxCatchUp {
+			due = due[1:]
+			dropped++
+		}
+		due = append(due, at)
+	}
+	return due, dropped
+}
+
+// catchUp applies the task's policy to its due occurrences
+func (s *Scheduler) catchUp(task *scheduledTask, due []time.Time, now time.Time) []time.Time {
+	switch task.CatchUp {
+	case CatchUpAll:
+		return due
+	case CatchUpSkip:
+		if now.Sub(due[len(due)-1]) > s.config.Grace {
+			return nil
+		}
+	}
+	return due[len(due)-1:]
+}
+
+// run executes one occurrence of a task and records it in the run history
+func (s *Scheduler) run(ctx context.Context, task *scheduledTask, at time.Time) {
+	id, started, err := s.store.StartScheduledRun(ctx, task.Name, at, s.holder)
+	if err != nil {
+		s.logger.Error("failed to start scheduled run", "task", task.Name, "scheduled_at", at, "error", err)
+		return
+	}
+	if !started {
+		// A previous leader already ran this occurrence
+		return
+	}
+
+	runCtx := ctx
+	if task.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, task.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err = s.execute(runCtx, task)
+	if err != nil {
+		s.logger.Error("Scheduled task failed", "task", task.Name, "scheduled_at", at, "duration", time.Since(start), "error", err)
+	} else {
+		s.logger.Info("Scheduled task completed", "task", task.Name, "scheduled_at", at, "duration", time.Since(start))
+	}
+
+	if err := s.store.FinishScheduledRun(context.WithoutCancel(ctx), id, err); err != nil {
+		s.logger.Error("failed to record scheduled run", "task", task.Name, "error", err)
+	}
+}
+
+// execute calls the task, converting a panic into a failed run
+func (s *Scheduler) execute(ctx context.Context, task *scheduledTask) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("scheduled task panicked: %v", r)
+		}
+	}()
+	return task.Run(ctx)
+}
+
+func (s *Scheduler) purge(ctx context.Context, now time.Time) {
+	deleted, err := s.store.PurgeScheduledRuns(ctx, now.Add(-s.config.Retention))
+	if err != nil {
+		s.logger.Error("failed to purge scheduled run history", "error", err)
+		return
+	}
+	if deleted > 0 {
+		s.logger.Info("Purged scheduled run history", "count", deleted)
+	}
+}
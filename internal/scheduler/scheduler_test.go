@@ -0,0 +1,97 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fakeStore grants the lease while held is set and accepts every run
+type fakeStore struct {
+	held atomic.Bool
+
+	mu       sync.Mutex
+	finished []error
+}
+
+func (s *fakeStore) AcquireSchedulerLease(ctx context.Context, name, holder string, lease time.Duration) (bool, error) {
+	return s.held.Load(), nil
+}
+
+func (s *fakeStore) ReleaseSchedulerLease(ctx context.Context, name, holder string) error {
+	return nil
+}
+
+func (s *fakeStore) LatestScheduledRuns(ctx context.Context) (map[string]time.Time, error) {
+	return nil, nil
+}
+
+func (s *fakeStore) StartScheduledRun(ctx context.Context, task string, scheduledAt time.Time, holder string) (uuid.UUID, bool, error) {
+	return uuid.New(), true, nil
+}
+
+func (s *fakeStore) FinishScheduledRun(ctx context.Context, id uuid.UUID, cause error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finished = append(s.finished, cause)
+	return nil
+}
+
+func (s *fakeStore) AbandonScheduledRuns(ctx context.Context, holder string) (int64, error) {
+	return 0, nil
+}
+
+func (s *fakeStore) PurgeScheduledRuns(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func TestLosingLeadershipCancelsRunningTasks(t *testing.T) {
+	store := &fakeStore{}
+	store.held.Store(true)
+
+	scheduler := NewScheduler(store, slog.New(slog.DiscardHandler), Config{PollInterval: 10 * time.Millisecond, Lease: 30 * time.Millisecond})
+
+	started, cancelled := make(chan struct{}), make(chan struct{})
+	err := scheduler.Add(Task{
+		Name:     "long",
+		Schedule: "@every 1s",
+		Run: func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			close(cancelled)
+			return ctx.Err()
+		},
+	})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- scheduler.Run(ctx)
+	}()
+	defer func() {
+		stop()
+		<-done
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("task did not start")
+	}
+
+	// The scheduler keeps running, but another replica now holds the lease
+	store.held.Store(false)
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("task was not cancelled after leadership was lost")
+	}
+}
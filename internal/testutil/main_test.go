@@ -0,0 +1,11 @@
+package testutil_test
+
+import (
+	"testing"
+
+	"github.com/travisbale/go-template/internal/testutil"
+)
+
+func TestMain(m *testing.M) {
+	testutil.Main(m)
+}
@@ -0,0 +1,371 @@
+// Package testutil provides the harness for integration tests: a throwaway
+// Postgres server, a fresh migrated database per test and an app.Server on
+// random ports with connected SDK clients.
+//
+// Tests use the server named by TEST_DATABASE_URL, or launch one from the
+// PostgreSQL binaries on PATH (or in PG_BIN) and skip when there are none.
+// Packages that may launch a server should stop it from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		testutil.Main(m)
+//	}
+package testutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/travisbale/go-template/internal/db/postgres"
+)
+
+const (
+	// DatabaseURLEnv names the environment variable holding the URL of an
+	// existing server to create test databases on. The role in the URL needs
+	// CREATEDB, and CREATEROLE if it bypasses RLS.
+	DatabaseURLEnv = "TEST_DATABASE_URL"
+	// BinDirEnv names the environment variable holding the directory of the
+	// initdb and postgres binaries used to launch a server
+	BinDirEnv = "PG_BIN"
+
+	// appRole owns the test databases when the admin role is a superuser or
+	// bypasses RLS, so that tests run under the tenant policies like the service
+	appRole     = "app_test"
+	appPassword = "app_test"
+
+	startTimeout = 30 * time.Second
+)
+
+// SQLSTATEs returned when a concurrent test process created appRole first
+const (
+	sqlStateDuplicateObject = "42710"
+	sqlStateUniqueViolation = "23505"
+)
+
+// errNoPostgres means neither TEST_DATABASE_URL nor local binaries are available
+var errNoPostgres = errors.New("no Postgres available")
+
+// cluster is the Postgres server shared by every test in the process
+type cluster struct {
+	// adminURL connects with the role that creates and drops databases
+	adminURL string
+	// owner is the role that owns test databases, or empty to use the admin role
+	owner string
+	// stop shuts down a launched server; it is nil for TEST_DATABASE_URL
+	stop func()
+}
+
+var (
+	clusterOnce sync.Once
+	shared      *cluster
+	clusterErr  error
+)
+
+// Main runs the package's tests, stops the Postgres server if one was
+// launched for them and exits with the tests' result
+func Main(m *testing.M) {
+	code := m.Run()
+	if shared != nil && shared.stop != nil {
+		shared.stop()
+	}
+	os.Exit(code)
+}
+
+// Logger returns the logger used by the harness and the servers it starts.
+// It only writes warnings and errors, to stderr, so that it can outlive the
+// test that created it.
+func Logger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+}
+
+// NewDatabase creates an empty database with every embedded migration
+// applied and returns its URL. The database is dropped when the test ends.
+func NewDatabase(t testing.TB) string {
+	t.Helper()
+	c := getCluster(t)
+	ctx := context.Background()
+
+	name := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	ident := pgx.Identifier{name}.Sanitize()
+
+	create := "CREATE DATABASE " + ident
+	if c.owner != "" {
+		create += " OWNER " + pgx.Identifier{c.owner}.Sanitize()
+	}
+	if err := c.exec(ctx, create); err != nil {
+		t.Fatalf("failed to create test database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := c.exec(context.Background(), "DROP DATABASE IF EXISTS "+ident+" WITH (FORCE)"); err != nil {
+			t.Errorf("failed to drop test database %s: %v", name, err)
+		}
+	})
+
+	databaseURL, err := c.databaseURL(name)
+	if err != nil {
+		t.Fatalf("failed to build test database URL: %v", err)
+	}
+	if _, err := postgres.NewMigrator(databaseURL, Logger()).Up(ctx); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	return databaseURL
+}
+
+// NewDB connects to a fresh database from NewDatabase. The connection is
+// closed when the test ends.
+func NewDB(t testing.TB) *postgres.DB {
+	t.Helper()
+	databaseURL := NewDatabase(t)
+
+	db, err := postgres.NewDB(context.Background(), databaseURL, Logger())
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	t.Cleanup(db.Close)
+	return db
+}
+
+// getCluster returns the shared server, starting it on first use, and skips
+// the test when no Postgres is available
+func getCluster(t testing.TB) *cluster {
+	t.Helper()
+	clusterOnce.Do(func() {
+		shared, clusterErr = openCluster()
+	})
+	if errors.Is(clusterErr, errNoPostgres) {
+		t.Skipf("skipping integration test: %v", clusterErr)
+	}
+	if clusterErr != nil {
+		t.Fatalf("failed to start test Postgres: %v", clusterErr)
+	}
+	return shared
+}
+
+func openCluster() (*cluster, error) {
+	c := &cluster{adminURL: os.Getenv(DatabaseURLEnv)}
+	if c.adminURL == "" {
+		var err error
+		if c.adminURL, c.stop, err = launch(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.ensureOwner(context.Background()); err != nil {
+		if c.stop != nil {
+			c.stop()
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// ensureOwner creates an unprivileged role to own the test databases when
+// the admin role would bypass RLS
+func (c *cluster) ensureOwner(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, c.adminURL)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", DatabaseURLEnv, err)
+	}
+	defer conn.Close(ctx) //nolint:errcheck
+
+	var privileged bool
+	err = conn.QueryRow(ctx, "SELECT rolsuper OR rolbypassrls FROM pg_roles WHERE rolname = current_user").Scan(&privileged)
+	if err != nil {
+		return fmt.Errorf("failed to check admin role: %w", err)
+	}
+	if !privileged {
+		return nil
+	}
+
+	_, err = conn.Exec(ctx, fmt.Sprintf("CREATE ROLE %s LOGIN PASSWORD '%s'", pgx.Identifier{appRole}.Sanitize(), appPassword))
+	var pgErr *pgconn.PgError
+	if err != nil && !(errors.As(err, &pgErr) && (pgErr.Code == sqlStateDuplicateObject || pgErr.Code == sqlStateUniqueViolation)) {
+		return fmt.Errorf("failed to create role %s: %w", appRole, err)
+	}
+	c.owner = appRole
+	return nil
+}
+
+// exec runs a statement on the admin connection
+func (c *cluster) exec(ctx context.Context, sql string) error {
+	conn, err := pgx.Connect(ctx, c.adminURL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx) //nolint:errcheck
+
+	_, err = conn.Exec(ctx, sql)
+	return err
+}
+
+// databaseURL points the admin URL at a test database, as its owner
+func (c *cluster) databaseURL(name string) (string, error) {
+	u, err := url.Parse(c.adminURL)
+	if err != nil {
+		return "", fmt.Errorf("%s must be a postgres:// URL: %w", DatabaseURLEnv, err)
+	}
+	u.Path = "/" + name
+	if c.owner != "" {
+		u.User = url.UserPassword(c.owner, appPassword)
+	}
+	return u.String(), nil
+}
+
+// launch starts a throwaway server in a temporary directory with durability
+// turned off, and returns its admin URL and a function that stops it
+func launch() (string, func(), error) {
+	if os.Geteuid() == 0 {
+		return "", nil, fmt.Errorf("%w: postgres refuses to run as root, set %s", errNoPostgres, DatabaseURLEnv)
+	}
+
+	initdb, server, err := findBinaries()
+	if err != nil {
+		return "", nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "testutil-postgres-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+	dataDir := filepath.Join(dir, "data")
+
+	output, err := exec.Command(initdb, "-D", dataDir, "-U", "postgres", "-A", "trust", "-E", "UTF8", "--no-sync").CombinedOutput()
+	if err != nil {
+		os.RemoveAll(dir) //nolint:errcheck
+		return "", nil, fmt.Errorf("initdb failed: %w: %s", err, output)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		os.RemoveAll(dir) //nolint:errcheck
+		return "", nil, err
+	}
+
+	logFile, err := os.Create(filepath.Join(dir, "postgres.log"))
+	if err != nil {
+		os.RemoveAll(dir) //nolint:errcheck
+		return "", nil, fmt.Errorf("failed to create server log: %w", err)
+	}
+
+	cmd := exec.Command(server,
+		"-D", dataDir,
+		"-p", strconv.Itoa(port),
+		"-k", dir,
+		"-c", "listen_addresses=127.0.0.1",
+		"-c", "fsync=off",
+		"-c", "synchronous_commit=off",
+		"-c", "full_page_writes=off",
+	)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	if err := cmd.Start(); err != nil {
+		logFile.Close()   //nolint:errcheck
+		os.RemoveAll(dir) //nolint:errcheck
+		return "", nil, fmt.Errorf("failed to start postgres: %w", err)
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait() //nolint:errcheck
+		close(exited)
+	}()
+
+	stop := func() {
+		// SIGINT is a fast shutdown, which disconnects clients
+		cmd.Process.Signal(os.Interrupt) //nolint:errcheck
+		select {
+		case <-exited:
+		case <-time.After(10 * time.Second):
+			cmd.Process.Kill() //nolint:errcheck
+			<-exited
+		}
+		logFile.Close()   //nolint:errcheck
+		os.RemoveAll(dir) //nolint:errcheck
+	}
+
+	adminURL := fmt.Sprintf("postgres://postgres@127.0.0.1:%d/postgres?sslmode=disable", port)
+	if err := waitForServer(adminURL, exited); err != nil {
+		log, _ := os.ReadFile(logFile.Name())
+		stop()
+		return "", nil, fmt.Errorf("%w: %s", err, log)
+	}
+	return adminURL, stop, nil
+}
+
+// waitForServer polls until the server accepts connections or exits
+func waitForServer(adminURL string, exited <-chan struct{}) error {
+	deadline := time.Now().Add(startTimeout)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		conn, err := pgx.Connect(ctx, adminURL)
+		cancel()
+		if err == nil {
+			return conn.Close(context.Background())
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("postgres did not accept connections within %s: %w", startTimeout, err)
+		}
+		select {
+		case <-exited:
+			return errors.New("postgres exited during startup")
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// findBinaries locates initdb and postgres in PG_BIN, on PATH, through
+// pg_config or in the Debian and Ubuntu versioned directories
+func findBinaries() (initdb, server string, err error) {
+	var dirs []string
+	if dir := os.Getenv(BinDirEnv); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	if path, err := exec.LookPath("initdb"); err == nil {
+		dirs = append(dirs, filepath.Dir(path))
+	}
+	if output, err := exec.Command("pg_config", "--bindir").Output(); err == nil {
+		dirs = append(dirs, strings.TrimSpace(string(output)))
+	}
+	versioned, _ := filepath.Glob("/usr/lib/postgresql/*/bin")
+	sort.Sort(sort.Reverse(sort.StringSlice(versioned)))
+	dirs = append(dirs, versioned...)
+
+	for _, dir := range dirs {
+		initdb, server = filepath.Join(dir, "initdb"), filepath.Join(dir, "postgres")
+		if isExecutable(initdb) && isExecutable(server) {
+			return initdb, server, nil
+		}
+	}
+	return "", "", fmt.Errorf("%w: set %s, or install PostgreSQL or set %s", errNoPostgres, DatabaseURLEnv, BinDirEnv)
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir() && info.Mode()&0o111 != 0
+}
+
+// freePort asks the kernel for an unused local port
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find a free port: %w", err)
+	}
+	defer listener.Close() //nolint:errcheck
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
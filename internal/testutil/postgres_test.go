@@ -0,0 +1,106 @@
+package testutil_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/travisbale/go-template/internal/db/postgres"
+	"github.com/travisbale/go-template/internal/domain"
+	"github.com/travisbale/go-template/internal/testutil"
+	"github.com/travisbale/heimdall/tenant"
+)
+
+func TestMigrationsRoundTrip(t *testing.T) {
+	databaseURL := testutil.NewDatabase(t)
+	ctx := context.Background()
+	migrator := postgres.NewMigrator(databaseURL, testutil.Logger())
+
+	latest, err := postgres.LatestVersion()
+	if err != nil {
+		t.Fatalf("failed to read latest version: %v", err)
+	}
+	assertVersion(t, migrator, latest)
+
+	if _, err := migrator.DownAll(ctx); err != nil {
+		t.Fatalf("failed to roll back migrations: %v", err)
+	}
+	assertVersion(t, migrator, 0)
+	if exists := tableExists(t, databaseURL, "audit_log"); exists {
+		t.Error("audit_log exists after rolling back every migration")
+	}
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("failed to reapply migrations: %v", err)
+	}
+	assertVersion(t, migrator, latest)
+	if exists := tableExists(t, databaseURL, "audit_log"); !exists {
+		t.Error("audit_log is missing after reapplying migrations")
+	}
+}
+
+func TestTenantsAreIsolated(t *testing.T) {
+	db := testutil.NewDB(t)
+	entries := postgres.NewAuditRepository(db)
+	tenantA, tenantB := uuid.New(), uuid.New()
+
+	for _, tenantID := range []uuid.UUID{tenantA, tenantB} {
+		ctx := tenant.WithTenant(context.Background(), tenantID)
+		entry := domain.AuditEntry{TenantID: tenantID, ActorType: "system", Action: "create", ResourceType: "widget", ResourceID: tenantID.String()}
+		if _, err := entries.Insert(ctx, entry); err != nil {
+			t.Fatalf("failed to insert entry for tenant %s: %v", tenantID, err)
+		}
+	}
+
+	for _, tenantID := range []uuid.UUID{tenantA, tenantB} {
+		ctx := tenant.WithTenant(context.Background(), tenantID)
+		err := db.InTenantTransaction(ctx, func(ctx context.Context) error {
+			listed, err := entries.List(ctx, domain.AuditFilter{}, nil, nil, 10)
+			if err != nil {
+				return err
+			}
+			if len(listed) != 1 || listed[0].TenantID != tenantID {
+				t.Errorf("tenant %s sees %+v, want only its own entry", tenantID, listed)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("failed to list entries for tenant %s: %v", tenantID, err)
+		}
+	}
+
+	// A tenant cannot write rows for another tenant
+	ctx := tenant.WithTenant(context.Background(), tenantA)
+	forged := domain.AuditEntry{TenantID: tenantB, ActorType: "system", Action: "create", ResourceType: "widget", ResourceID: "forged"}
+	if _, err := entries.Insert(ctx, forged); err == nil {
+		t.Error("tenant A inserted an entry for tenant B")
+	}
+}
+
+func assertVersion(t *testing.T, migrator *postgres.Migrator, want uint) {
+	t.Helper()
+	version, dirty, err := migrator.Version(context.Background())
+	if err != nil {
+		t.Fatalf("failed to read migration version: %v", err)
+	}
+	if version != want || dirty {
+		t.Errorf("migration version = %d (dirty %t), want %d", version, dirty, want)
+	}
+}
+
+func tableExists(t *testing.T, databaseURL, table string) bool {
+	t.Helper()
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, databaseURL)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer conn.Close(ctx) //nolint:errcheck
+
+	var exists bool
+	if err := conn.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists); err != nil {
+		t.Fatalf("failed to look up table %s: %v", table, err)
+	}
+	return exists
+}
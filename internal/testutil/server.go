@@ -0,0 +1,245 @@
+package testutil
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/travisbale/go-template/internal/app"
+	"github.com/travisbale/go-template/sdk"
+	"github.com/travisbale/heimdall/jwt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// shutdownTimeout bounds how long a test server takes to stop at cleanup
+const shutdownTimeout = 10 * time.Second
+
+// Server is an app.Server listening on random local ports against a fresh
+// database. It is shut down when the test ends.
+type Server struct {
+	// HTTPURL is the base URL of the HTTP API, e.g. http://127.0.0.1:54321
+	HTTPURL string
+	// GRPCAddress is the host:port of the gRPC API
+	GRPCAddress string
+	// DatabaseURL is the server's database, for arranging data and checking results
+	DatabaseURL string
+
+	issuer *jwt.Issuer
+}
+
+// StartServer starts an app.Server on a fresh database. configure can adjust
+// the configuration before the server is created, e.g. to set an outbox
+// publisher or disable the in-process worker.
+func StartServer(t testing.TB, configure ...func(*app.Config)) *Server {
+	t.Helper()
+
+	databaseURL := NewDatabase(t)
+	privateKeyPath, publicKeyPath := writeKeys(t)
+
+	httpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to create HTTP listener: %v", err)
+	}
+	grpcListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		httpListener.Close() //nolint:errcheck
+		t.Fatalf("failed to create gRPC listener: %v", err)
+	}
+
+	config := &app.Config{
+		HTTPAddress:          httpListener.Addr().String(),
+		GRPCAddress:          grpcListener.Addr().String(),
+		DatabaseURL:          databaseURL,
+		MigrationWaitTimeout: shutdownTimeout,
+		JWTPublicKeyPath:     publicKeyPath,
+		PageTokenSecret:      "testutil",
+		Environment:          "test",
+		Version:              "test",
+		Logger:               Logger(),
+		RunWorkers:           true,
+	}
+	for _, fn := range configure {
+		fn(config)
+	}
+
+	server, err := app.NewServer(config)
+	if err != nil {
+		httpListener.Close() //nolint:errcheck
+		grpcListener.Close() //nolint:errcheck
+		t.Fatalf("failed to create server: %v", err)
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(httpListener, grpcListener)
+	}()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			t.Errorf("failed to shut down server: %v", err)
+		}
+		if err := <-served; err != nil && !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("server failed: %v", err)
+		}
+	})
+
+	issuer, err := jwt.NewIssuer("testutil", privateKeyPath, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create token issuer: %v", err)
+	}
+
+	s := &Server{
+		HTTPURL:     "http://" + config.HTTPAddress,
+		GRPCAddress: config.GRPCAddress,
+		DatabaseURL: databaseURL,
+		issuer:      issuer,
+	}
+	s.waitReady(t, served)
+	return s
+}
+
+// waitReady polls /readyz until the server has connected to its database,
+// failing the test if it stops instead
+func (s *Server) waitReady(t testing.TB, served chan error) {
+	t.Helper()
+	client := &http.Client{Timeout: time.Second}
+	deadline := time.Now().Add(startTimeout)
+
+	for {
+		response, err := client.Get(s.HTTPURL + "/readyz")
+		if err == nil {
+			response.Body.Close() //nolint:errcheck
+			if response.StatusCode == http.StatusOK {
+				return
+			}
+		}
+
+		select {
+		case err := <-served:
+			served <- err
+			t.Fatalf("server failed to start: %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server was not ready within %s", startTimeout)
+		}
+	}
+}
+
+// Token issues an access token for a user of a tenant, accepted by the server
+func (s *Server) Token(t testing.TB, tenantID, userID uuid.UUID, permissions ...string) string {
+	t.Helper()
+	token, err := s.issuer.IssueAccessToken(userID, tenantID, permissions)
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+	return token
+}
+
+// HTTPClient returns a client for the HTTP API that sends token as a bearer
+// token, or no credentials when token is empty
+func (s *Server) HTTPClient(token string) *sdk.HTTPClient {
+	return sdk.NewHTTPClient(s.HTTPURL, Logger(), sdk.WithHTTPClient(&http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &bearerTransport{token: token},
+	}))
+}
+
+// GRPCClient returns a client for the gRPC API that sends token as a bearer
+// token, or no credentials when token is empty. It is closed when the test ends.
+func (s *Server) GRPCClient(t testing.TB, token string) *sdk.GRPCClient {
+	t.Helper()
+
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	if token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(bearerCredentials(token)))
+	}
+
+	client, err := sdk.NewGRPCClient(s.GRPCAddress, sdk.WithDialOptions(opts...))
+	if err != nil {
+		t.Fatalf("failed to create gRPC client: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := client.Close(); err != nil {
+			t.Errorf("failed to close gRPC client: %v", err)
+		}
+	})
+	return client
+}
+
+// bearerTransport adds an Authorization header to every request
+type bearerTransport struct {
+	token string
+}
+
+func (b *bearerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if b.token == "" {
+		return http.DefaultTransport.RoundTrip(r)
+	}
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+b.token)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// bearerCredentials sends a bearer token with every RPC over plaintext
+type bearerCredentials string
+
+func (b bearerCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(b)}, nil
+}
+
+func (b bearerCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+var (
+	keyOnce sync.Once
+	keyPEM  []byte
+	pubPEM  []byte
+	keyErr  error
+)
+
+// writeKeys writes the signing key pair shared by the process's test servers
+// to the test's temporary directory, generating it on first use
+func writeKeys(t testing.TB) (privateKeyPath, publicKeyPath string) {
+	t.Helper()
+	keyOnce.Do(func() {
+		var key *rsa.PrivateKey
+		if key, keyErr = rsa.GenerateKey(rand.Reader, 2048); keyErr != nil {
+			return
+		}
+		var public []byte
+		if public, keyErr = x509.MarshalPKIXPublicKey(&key.PublicKey); keyErr != nil {
+			return
+		}
+		keyPEM = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+		pubPEM = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public})
+	})
+	if keyErr != nil {
+		t.Fatalf("failed to generate signing key: %v", keyErr)
+	}
+
+	dir := t.TempDir()
+	privateKeyPath = filepath.Join(dir, "jwt.key")
+	publicKeyPath = filepath.Join(dir, "jwt.pub")
+	if err := os.WriteFile(privateKeyPath, keyPEM, 0o600); err != nil {
+		t.Fatalf("failed to write signing key: %v", err)
+	}
+	if err := os.WriteFile(publicKeyPath, pubPEM, 0o644); err != nil {
+		t.Fatalf("failed to write public key: %v", err)
+	}
+	return privateKeyPath, publicKeyPath
+}
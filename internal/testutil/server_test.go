@@ -0,0 +1,63 @@
+package testutil_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/travisbale/go-template/internal/db/postgres"
+	"github.com/travisbale/go-template/internal/domain"
+	"github.com/travisbale/go-template/internal/testutil"
+	"github.com/travisbale/go-template/sdk"
+	"github.com/travisbale/heimdall/tenant"
+)
+
+func TestStartServer(t *testing.T) {
+	server := testutil.StartServer(t)
+	ctx := context.Background()
+
+	db, err := postgres.NewDB(ctx, server.DatabaseURL, testutil.Logger())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer db.Close()
+
+	tenantID, otherTenantID := uuid.New(), uuid.New()
+	entry := domain.AuditEntry{TenantID: tenantID, ActorType: "system", Action: "create", ResourceType: "widget", ResourceID: "1"}
+	if _, err := postgres.NewAuditRepository(db).Insert(tenant.WithTenant(ctx, tenantID), entry); err != nil {
+		t.Fatalf("failed to insert entry: %v", err)
+	}
+
+	client := server.HTTPClient(server.Token(t, tenantID, uuid.New(), "audit:read"))
+	records, err := client.ListAuditRecords(ctx, sdk.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list audit records: %v", err)
+	}
+	if len(records.Items) != 1 || records.Items[0].ResourceID != "1" {
+		t.Errorf("listed %+v, want the tenant's entry", records.Items)
+	}
+
+	// Another tenant's token does not see the entry
+	other := server.HTTPClient(server.Token(t, otherTenantID, uuid.New(), "audit:read"))
+	records, err = other.ListAuditRecords(ctx, sdk.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list audit records: %v", err)
+	}
+	if len(records.Items) != 0 {
+		t.Errorf("other tenant listed %+v, want nothing", records.Items)
+	}
+
+	// Listing requires the audit:read permission
+	_, err = server.HTTPClient(server.Token(t, tenantID, uuid.New())).ListAuditRecords(ctx, sdk.ListOptions{})
+	var problem *sdk.Problem
+	if !errors.As(err, &problem) || problem.Status != http.StatusForbidden {
+		t.Errorf("list without permission error = %v, want 403", err)
+	}
+
+	// Requests without a token are rejected
+	if _, err := server.HTTPClient("").ListAuditRecords(ctx, sdk.ListOptions{}); err == nil {
+		t.Error("anonymous list succeeded")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -60,6 +61,26 @@ func (c *HTTPClient) Health(ctx context.Context) (*HealthResponse, error) {
 	return &health, nil
 }
 
+// ListAuditRecords lists the caller's tenant audit log, newest first by default
+func (c *HTTPClient) ListAuditRecords(ctx context.Context, opts ListOptions) (*ListResponse[AuditRecord], error) {
+	endpoint := fmt.Sprintf("%s/v1/audit", c.baseURL)
+	if query := opts.Values().Encode(); query != "" {
+		endpoint += "?" + query
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	var records ListResponse[AuditRecord]
+	if err := c.doRequest(req, &records); err != nil {
+		return nil, err
+	}
+
+	return &records, nil
+}
+
 // Add your HTTP client methods here
 // Example:
 // func (c *HTTPClient) GetUser(ctx context.Context, id string) (*User, error) {
@@ -89,6 +110,13 @@ func (c *HTTPClient) doRequest(req *http.Request, result any) error {
 
 	// Check for error responses
 	if resp.StatusCode >= 400 {
+		if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/problem+json") {
+			var problem Problem
+			if err := json.Unmarshal(body, &problem); err == nil {
+				return &problem
+			}
+		}
+
 		var errResp map[string]string
 		if err := json.Unmarshal(body, &errResp); err != nil {
 			return fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
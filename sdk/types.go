@@ -1,5 +1,14 @@
 package sdk
 
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
 type logger interface {
 	Info(msg string, args ...any)
 	Warn(msg string, args ...any)
@@ -11,6 +20,123 @@ type HealthResponse struct {
 	Status string `json:"status"`
 }
 
+// ReadinessResponse represents the readiness check response
+type ReadinessResponse struct {
+	Status string           `json:"status"`
+	Checks []ReadinessCheck `json:"checks"`
+}
+
+// ReadinessCheck reports the state of a single dependency
+type ReadinessCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Problem is an RFC 9457 problem details response
+type Problem struct {
+	Type          string         `json:"type,omitempty"`
+	Title         string         `json:"title"`
+	Status        int            `json:"status"`
+	Detail        string         `json:"detail,omitempty"`
+	InvalidParams []InvalidParam `json:"invalid_params,omitempty"`
+}
+
+func (p *Problem) Error() string {
+	message := fmt.Sprintf("API error (%d): %s", p.Status, p.Title)
+	if p.Detail != "" {
+		message += ": " + p.Detail
+	}
+	if len(p.InvalidParams) > 0 {
+		message += " (" + ValidationErrors(p.InvalidParams).Error() + ")"
+	}
+	return message
+}
+
+// InvalidParam identifies a request field by its JSON path and explains why it was rejected
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// ValidationErrors collects every invalid field of a request.
+// Request types return it from their Validate method.
+type ValidationErrors []InvalidParam
+
+// Add records an invalid field
+func (v *ValidationErrors) Add(name, reason string) {
+	*v = append(*v, InvalidParam{Name: name, Reason: reason})
+}
+
+// Err returns nil when no fields were recorded so it can be returned directly from Validate
+func (v ValidationErrors) Err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return v
+}
+
+func (v ValidationErrors) Error() string {
+	parts := make([]string, len(v))
+	for i, param := range v {
+		parts[i] = param.Name + ": " + param.Reason
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+// ListResponse is the envelope returned by every list endpoint
+type ListResponse[T any] struct {
+	Items         []T    `json:"items"`
+	NextPageToken string `json:"next_page_token,omitempty"`
+}
+
+// ListOptions holds the paging, sorting and filtering parameters for list requests
+type ListOptions struct {
+	PageSize  int32
+	PageToken string
+	OrderBy   string
+	Filter    string
+}
+
+// Values encodes the options as URL query parameters
+func (o ListOptions) Values() url.Values {
+	values := url.Values{}
+	if o.PageSize > 0 {
+		values.Set("page_size", strconv.Itoa(int(o.PageSize)))
+	}
+	if o.PageToken != "" {
+		values.Set("page_token", o.PageToken)
+	}
+	if o.OrderBy != "" {
+		values.Set("order_by", o.OrderBy)
+	}
+	if o.Filter != "" {
+		values.Set("filter", o.Filter)
+	}
+	return values
+}
+
+// StreamMessage is an event delivered by the /v1/events streams. Over
+// server-sent events the topic is the event name and Data the event data.
+type StreamMessage struct {
+	Topic string          `json:"topic"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// AuditRecord is an entry in the audit log returned by /v1/audit
+type AuditRecord struct {
+	ID           string `json:"id"`
+	ActorID      string `json:"actor_id"`
+	ActorType    string `json:"actor_type"`
+	Action       string `json:"action"`
+	ResourceType string `json:"resource_type"`
+	ResourceID   string `json:"resource_id"`
+	// Changes maps each changed field to {"before": ..., "after": ...}
+	Changes   json.RawMessage `json:"changes"`
+	RequestID string          `json:"request_id,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
 // Add your shared types here
 // Example:
 // type User struct {